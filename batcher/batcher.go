@@ -17,7 +17,9 @@ limitations under the License.
 package batcher
 
 import (
+	"context"
 	"errors"
+	"sync/atomic"
 	"time"
 )
 
@@ -42,6 +44,21 @@ func (m *mutex) Unlock() {
 	<-m.lock
 }
 
+// LockContext acquires the lock unless the context is done first, in
+// which case the context's error is returned.
+func (m *mutex) LockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case m.lock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (m *mutex) TryLock() bool {
 	select {
 	case m.lock <- struct{}{}:
@@ -56,10 +73,21 @@ type Batcher interface {
 	// Put adds items to the batcher.
 	Put(interface{}) error
 
+	// PutContext adds items to the batcher. If the context is done
+	// before the item could be added, ctx.Err() is returned and the
+	// item is not added.
+	PutContext(context.Context, interface{}) error
+
 	// Get retrieves a batch from the batcher. This call will block until
 	// one of the conditions for a "complete" batch is reached.
 	Get() ([]interface{}, error)
 
+	// GetContext retrieves a batch from the batcher. This call will block
+	// until one of the conditions for a "complete" batch is reached or
+	// the context is done, in which case ctx.Err() is returned and no
+	// items are taken from the batcher.
+	GetContext(context.Context) ([]interface{}, error)
+
 	// Flush forcibly completes the batch currently being built
 	Flush() error
 
@@ -70,6 +98,9 @@ type Batcher interface {
 
 	// IsDisposed will determine if the batcher is disposed
 	IsDisposed() bool
+
+	// Stats returns the counters of queued, dropped and flushed items.
+	Stats() Stats
 }
 
 // ErrDisposed is the error returned for a disposed Batcher
@@ -79,6 +110,11 @@ var ErrDisposed = errors.New("batcher: disposed")
 type CalculateBytes func(interface{}) uint
 
 type basicBatcher struct {
+	// counters are accessed atomically and kept first for alignment.
+	queued  uint64
+	dropped uint64
+	flushed uint64
+
 	maxTime        time.Duration
 	maxItems       uint
 	maxBytes       uint
@@ -88,6 +124,12 @@ type basicBatcher struct {
 	batchChan      chan []interface{}
 	availableBytes uint
 	lock           *mutex
+	overflow       OverflowPolicy
+	onDrop         DropHandler
+	observer       Observer
+	// started is when the first item of the batch being built was
+	// added, only tracked with an observer.
+	started time.Time
 }
 
 // New creates a new Batcher using the provided arguments.
@@ -101,12 +143,16 @@ type basicBatcher struct {
 // thread places two items in the batcher, Get will guarantee the first
 // item is returned before the second, whether before the second in the same
 // batch, or in an earlier batch.
-func New(maxTime time.Duration, maxItems, maxBytes, queueLen uint, calculate CalculateBytes) (Batcher, error) {
+// By default Put and Flush block while the queue of completed batches is
+// full, the Overflow option selects another policy.
+func New(maxTime time.Duration, maxItems, maxBytes, queueLen uint, calculate CalculateBytes,
+	options ...Option) (Batcher, error) {
+
 	if maxBytes > 0 && calculate == nil {
 		return nil, errors.New("batcher: must provide CalculateBytes function")
 	}
 
-	return &basicBatcher{
+	b := &basicBatcher{
 		maxTime:        maxTime,
 		maxItems:       maxItems,
 		maxBytes:       maxBytes,
@@ -114,29 +160,62 @@ func New(maxTime time.Duration, maxItems, maxBytes, queueLen uint, calculate Cal
 		items:          make([]interface{}, 0, maxItems),
 		batchChan:      make(chan []interface{}, queueLen),
 		lock:           newMutex(),
-	}, nil
+	}
+	for _, option := range options {
+		option(b)
+	}
+
+	return b, nil
 }
 
 // Put adds items to the batcher.
 func (b *basicBatcher) Put(item interface{}) error {
-	b.lock.Lock()
+	return b.PutContext(context.Background(), item)
+}
+
+// PutContext adds items to the batcher. If the context is done before
+// the item could be added, ctx.Err() is returned and the item is not
+// added.
+func (b *basicBatcher) PutContext(ctx context.Context, item interface{}) error {
+	if err := b.lock.LockContext(ctx); err != nil {
+		return err
+	}
 	if b.disposed {
 		b.lock.Unlock()
 		return ErrDisposed
 	}
 
-	b.items = append(b.items, item)
+	var bytes uint
 	if b.calculateBytes != nil {
-		b.availableBytes += b.calculateBytes(item)
+		bytes = b.calculateBytes(item)
+	}
+	if b.observer != nil && len(b.items) == 0 {
+		b.started = time.Now()
 	}
-	if b.ready() {
+	b.items = append(b.items, item)
+	b.availableBytes += bytes
+	if reason, ok := b.ready(); ok {
 		// To guarantee ordering this MUST be in the lock, otherwise multiple
 		// flush calls could be blocked at the same time, in which case
 		// there's no guarantee each batch is placed into the channel in
 		// the proper order
-		b.flush()
+		if err := b.flushContext(ctx, reason); err != nil {
+			// The batch channel stayed full, take the item back out so
+			// the caller can retry. The batch can't have been ready
+			// before this item was added, so it isn't ready now.
+			b.items[len(b.items)-1] = nil
+			b.items = b.items[:len(b.items)-1]
+			b.availableBytes -= bytes
+			if err == ErrFull && b.dropsOnOverflow() {
+				b.drop(item)
+				err = nil
+			}
+			b.lock.Unlock()
+			return err
+		}
 	}
 
+	atomic.AddUint64(&b.queued, 1)
 	b.lock.Unlock()
 	return nil
 }
@@ -144,8 +223,19 @@ func (b *basicBatcher) Put(item interface{}) error {
 // Get retrieves a batch from the batcher. This call will block until
 // one of the conditions for a "complete" batch is reached.
 func (b *basicBatcher) Get() ([]interface{}, error) {
+	return b.GetContext(context.Background())
+}
+
+// GetContext retrieves a batch from the batcher. This call will block
+// until one of the conditions for a "complete" batch is reached or the
+// context is done, in which case ctx.Err() is returned and no items are
+// taken from the batcher.
+func (b *basicBatcher) GetContext(ctx context.Context) ([]interface{}, error) {
 	// Don't check disposed yet so any items remaining in the queue
 	// will be returned properly.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	var timeout <-chan time.Time
 	if b.maxTime > 0 {
@@ -158,7 +248,10 @@ func (b *basicBatcher) Get() ([]interface{}, error) {
 		if !ok {
 			return nil, ErrDisposed
 		}
+		b.observeDepth()
 		return items, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	case <-timeout:
 		// It's possible something was added to the channel after something
 		// was received on the timeout channel, in which case that must
@@ -177,6 +270,7 @@ func (b *basicBatcher) Get() ([]interface{}, error) {
 					if !ok {
 						return nil, ErrDisposed
 					}
+					b.observeDepth()
 					return items, nil
 				default:
 				}
@@ -185,8 +279,10 @@ func (b *basicBatcher) Get() ([]interface{}, error) {
 				// and the temp buffer can't have changed because of the lock,
 				// so grab that
 				items := b.items
+				b.observeBatch(MaxTimeReached)
 				b.items = make([]interface{}, 0, b.maxItems)
 				b.availableBytes = 0
+				atomic.AddUint64(&b.flushed, uint64(len(items)))
 				b.lock.Unlock()
 				return items, nil
 			} else {
@@ -195,13 +291,17 @@ func (b *basicBatcher) Get() ([]interface{}, error) {
 				// 2) A Put or Flush temporarily has the lock.
 				// In either case, trying to read something off the batch chan,
 				// and going back to trying to get a lock if unsuccessful
-				// works.
+				// works. Nothing has been taken yet, so giving up here
+				// if the context is done can't lose any items.
 				select {
 				case items, ok := <-b.batchChan:
 					if !ok {
 						return nil, ErrDisposed
 					}
+					b.observeDepth()
 					return items, nil
+				case <-ctx.Done():
+					return nil, ctx.Err()
 				default:
 				}
 			}
@@ -217,9 +317,15 @@ func (b *basicBatcher) Flush() error {
 		b.lock.Unlock()
 		return ErrDisposed
 	}
-	b.flush()
+	err := b.flushContext(context.Background(), FlushCalled)
+	if err == ErrFull && b.dropsOnOverflow() {
+		b.drop(b.items...)
+		b.items = make([]interface{}, 0, b.maxItems)
+		b.availableBytes = 0
+		err = nil
+	}
 	b.lock.Unlock()
-	return nil
+	return err
 }
 
 // Dispose will dispose of the batcher. Any calls to Put or Flush
@@ -253,6 +359,30 @@ func (b *basicBatcher) Dispose() {
 	}
 }
 
+// close disposes of the batcher without dropping any items. The batch
+// currently being built is flushed and the queue of completed batches is
+// closed once it has been added, so Get keeps returning batches until
+// the queue is empty. The queue must be consumed for close to return.
+func (b *basicBatcher) close() {
+	b.lock.Lock()
+	if b.disposed {
+		b.lock.Unlock()
+		return
+	}
+
+	b.disposed = true
+	if len(b.items) > 0 {
+		// Nothing is dropped on close, whatever the overflow policy.
+		b.batchChan <- b.items
+		atomic.AddUint64(&b.flushed, uint64(len(b.items)))
+		b.observeBatch(Closed)
+		b.observeDepth()
+	}
+	b.items = nil
+	close(b.batchChan)
+	b.lock.Unlock()
+}
+
 // IsDisposed will determine if the batcher is disposed
 func (b *basicBatcher) IsDisposed() bool {
 	b.lock.Lock()
@@ -261,22 +391,116 @@ func (b *basicBatcher) IsDisposed() bool {
 	return disposed
 }
 
-// flush adds the batch currently being built to the queue of completed batches.
-// flush is not threadsafe, so should be synchronized externally.
-func (b *basicBatcher) flush() {
-	b.batchChan <- b.items
+// Stats returns the counters of queued, dropped and flushed items.
+func (b *basicBatcher) Stats() Stats {
+	return Stats{
+		Queued:  atomic.LoadUint64(&b.queued),
+		Dropped: atomic.LoadUint64(&b.dropped),
+		Flushed: atomic.LoadUint64(&b.flushed),
+	}
+}
+
+// flushContext adds the batch currently being built to the queue of
+// completed batches, applying the overflow policy if the queue is full.
+// If the batch can't be added, either because the context is done or
+// because ErrFull is returned, the batch being built is left untouched.
+// flushContext is not threadsafe, so should be synchronized externally.
+func (b *basicBatcher) flushContext(ctx context.Context, reason FlushReason) error {
+	switch b.overflow {
+	case FailFast, DropNewest:
+		select {
+		case b.batchChan <- b.items:
+		default:
+			return ErrFull
+		}
+	case DropOldest:
+		if cap(b.batchChan) == 0 {
+			select {
+			case b.batchChan <- b.items:
+			default:
+				return ErrFull
+			}
+			break
+		}
+
+		for sent := false; !sent; {
+			select {
+			case b.batchChan <- b.items:
+				sent = true
+			default:
+				// A Get may beat us to the oldest batch, in which
+				// case there's room now.
+				select {
+				case batch := <-b.batchChan:
+					b.drop(batch...)
+				default:
+				}
+			}
+		}
+	default:
+		select {
+		case b.batchChan <- b.items:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	atomic.AddUint64(&b.flushed, uint64(len(b.items)))
+	b.observeBatch(reason)
+	b.observeDepth()
 	b.items = make([]interface{}, 0, b.maxItems)
 	b.availableBytes = 0
+	return nil
+}
+
+// dropsOnOverflow returns true if ErrFull from flushContext should drop
+// the newest items rather than be returned.
+func (b *basicBatcher) dropsOnOverflow() bool {
+	return b.overflow == DropNewest || b.overflow == DropOldest
+}
+
+// drop reports the items as dropped.
+func (b *basicBatcher) drop(items ...interface{}) {
+	atomic.AddUint64(&b.dropped, uint64(len(items)))
+	if b.onDrop != nil {
+		for _, item := range items {
+			b.onDrop(item)
+		}
+	}
 }
 
-func (b *basicBatcher) ready() bool {
+// ready returns true along with the reason if the batch being built is
+// complete.
+func (b *basicBatcher) ready() (FlushReason, bool) {
 	if b.maxItems != 0 && uint(len(b.items)) >= b.maxItems {
-		return true
+		return MaxItemsReached, true
 	}
 	if b.maxBytes != 0 && b.availableBytes >= b.maxBytes {
-		return true
+		return MaxBytesReached, true
+	}
+	return 0, false
+}
+
+// observeBatch reports the batch being built as completed for the given
+// reason.
+func (b *basicBatcher) observeBatch(reason FlushReason) {
+	if b.observer == nil || len(b.items) == 0 {
+		return
+	}
+
+	b.observer.BatchCompleted(BatchEvent{
+		Items:  len(b.items),
+		Bytes:  b.availableBytes,
+		Age:    time.Since(b.started),
+		Reason: reason,
+	})
+}
+
+// observeDepth reports the number of batches in the queue.
+func (b *basicBatcher) observeDepth() {
+	if b.observer != nil {
+		b.observer.QueueDepth(len(b.batchChan))
 	}
-	return false
 }
 
 func (b *basicBatcher) drainBatchChan() {
@@ -17,6 +17,7 @@ limitations under the License.
 package batcher
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
@@ -177,3 +178,99 @@ func TestIsDisposed(t *testing.T) {
 	b.Dispose()
 	assert.True(b.IsDisposed())
 }
+
+func TestGetContextCanceled(t *testing.T) {
+	assert := assert.New(t)
+	b, err := New(0, 2, 0, 10, nil)
+	assert.Nil(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	batch, err := b.GetContext(ctx)
+	assert.Nil(batch)
+	assert.Equal(context.DeadlineExceeded, err)
+
+	// nothing was lost and the lock is free
+	assert.Nil(b.Put("a"))
+	assert.Nil(b.Put("b"))
+	batch, err = b.GetContext(context.Background())
+	assert.Nil(err)
+	assert.Equal([]interface{}{"a", "b"}, batch)
+}
+
+func TestGetContextMaxTimeCanceled(t *testing.T) {
+	assert := assert.New(t)
+	b, err := New(time.Hour, 10, 0, 10, nil)
+	assert.Nil(err)
+	assert.Nil(b.Put("a"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = b.GetContext(ctx)
+	assert.Equal(context.Canceled, err)
+
+	// the partial batch is still there to be flushed
+	assert.Nil(b.Flush())
+	batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal([]interface{}{"a"}, batch)
+}
+
+func TestPutContextQueueFull(t *testing.T) {
+	assert := assert.New(t)
+	b, err := New(0, 1, 0, 1, nil)
+	assert.Nil(err)
+
+	// fills the queue of completed batches
+	assert.Nil(b.Put("a"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, b.PutContext(ctx, "b"))
+
+	// the canceled item wasn't added and the lock was released
+	batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal([]interface{}{"a"}, batch)
+
+	assert.Nil(b.PutContext(context.Background(), "c"))
+	batch, err = b.Get()
+	assert.Nil(err)
+	assert.Equal([]interface{}{"c"}, batch)
+}
+
+func TestPutContextLockHeld(t *testing.T) {
+	assert := assert.New(t)
+	b, err := New(0, 1, 0, 1, nil)
+	assert.Nil(err)
+	assert.Nil(b.Put("a"))
+
+	// blocks holding the lock until the queue has room
+	done := make(chan error)
+	go func() {
+		done <- b.Put("b")
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, b.PutContext(ctx, "c"))
+
+	for _, expected := range []string{"a", "b"} {
+		batch, err := b.Get()
+		assert.Nil(err)
+		assert.Equal([]interface{}{expected}, batch)
+	}
+	assert.Nil(<-done)
+}
+
+func TestContextDisposed(t *testing.T) {
+	assert := assert.New(t)
+	b, err := New(0, 10, 0, 10, nil)
+	assert.Nil(err)
+	b.Dispose()
+
+	assert.Equal(ErrDisposed, b.PutContext(context.Background(), "a"))
+	_, err = b.GetContext(context.Background())
+	assert.Equal(ErrDisposed, err)
+}
@@ -0,0 +1,526 @@
+/*
+Copyright 2015 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultSegmentSize is the size in bytes at which a new segment of the
+// write-ahead log is started if no SegmentSize option is provided.
+const DefaultSegmentSize = 64 << 20
+
+// ErrUnknownBatch is returned by Ack for IDs of batches that aren't
+// waiting to be acknowledged.
+var ErrUnknownBatch = errors.New("batcher: unknown batch")
+
+// Codec converts the items of a DurableBatcher to and from the bytes
+// stored in its write-ahead log.
+type Codec interface {
+	Encode(interface{}) ([]byte, error)
+	Decode([]byte) (interface{}, error)
+}
+
+// BytesCodec is a Codec for items that are byte slices.
+type BytesCodec struct{}
+
+// Encode returns the item, which must be a []byte.
+func (BytesCodec) Encode(item interface{}) ([]byte, error) {
+	data, ok := item.([]byte)
+	if !ok {
+		return nil, errors.New("batcher: item is not a []byte")
+	}
+	return data, nil
+}
+
+// Decode returns a copy of the data.
+func (BytesCodec) Decode(data []byte) (interface{}, error) {
+	return append([]byte(nil), data...), nil
+}
+
+// Batch is a batch of items along with the ID it is acknowledged by.
+type Batch struct {
+	// ID is zero for a batch without items, which doesn't need to be
+	// acknowledged.
+	ID    uint64
+	Items []interface{}
+}
+
+// DurableBatcher is a batcher that writes every item to a write-ahead
+// log before accepting it. Batches are kept in the log until they are
+// acknowledged, and a DurableBatcher opened on the same directory after
+// a crash returns the unacknowledged batches first, followed by the
+// items of the batch that was being built.
+type DurableBatcher interface {
+	// Put adds items to the batcher once they are written to the log.
+	Put(interface{}) error
+
+	// Get retrieves a batch from the batcher. This call will block until
+	// one of the conditions for a "complete" batch is reached.
+	Get() (Batch, error)
+
+	// GetContext retrieves a batch from the batcher. This call will block
+	// until one of the conditions for a "complete" batch is reached or
+	// the context is done, in which case ctx.Err() is returned.
+	GetContext(context.Context) (Batch, error)
+
+	// Ack acknowledges that the batch with the given ID was processed,
+	// allowing the log to drop it.
+	Ack(uint64) error
+
+	// Flush forcibly completes the batch currently being built
+	Flush() error
+
+	// Close closes the batcher and its log. Batches that weren't
+	// acknowledged are kept in the log. Any calls to Put, Flush or Ack
+	// will return ErrDisposed, calls to Get will return an error iff
+	// there are no more ready batches.
+	Close() error
+
+	// IsClosed will determine if the batcher is closed
+	IsClosed() bool
+}
+
+// DurableOption configures a DurableBatcher.
+type DurableOption func(*durableBatcher)
+
+// SegmentSize sets the size in bytes at which a new segment of the log
+// is started. Only whole segments are removed from the log, so smaller
+// segments free space sooner at the cost of more files.
+func SegmentSize(n int64) DurableOption {
+	return func(b *durableBatcher) {
+		b.segmentSize = n
+	}
+}
+
+// SyncWrites syncs the log to disk after every write, so items survive
+// a crash of the machine and not just of the process.
+func SyncWrites() DurableOption {
+	return func(b *durableBatcher) {
+		b.sync = true
+	}
+}
+
+type durableBatcher struct {
+	maxTime        time.Duration
+	maxItems       uint
+	maxBytes       uint
+	calculateBytes CalculateBytes
+	codec          Codec
+	segmentSize    int64
+	sync           bool
+	disposed       bool
+	items          []interface{}
+	availableBytes uint
+	batchChan      chan Batch
+	// lock orders Puts and the batches they complete, like the lock of
+	// basicBatcher, and is held while blocked on a full queue.
+	lock *mutex
+
+	// walLock guards the log and the batches waiting to be
+	// acknowledged. It is only held briefly, so Ack never waits on a
+	// full queue.
+	walLock     sync.Mutex
+	wal         *wal
+	nextID      uint64
+	outstanding map[uint64]struct{}
+
+	// replayed holds the unacknowledged batches found in the log on
+	// open, which are returned before any other batch.
+	replayLock sync.Mutex
+	replayed   []Batch
+}
+
+// NewDurable opens a DurableBatcher that keeps its write-ahead log in
+// dir, creating the directory if needed. Batch readiness is determined
+// the same way as for a Batcher created by New. Any batches in the log
+// that weren't acknowledged are returned by Get before new batches, and
+// items that weren't part of a completed batch are added to the batch
+// being built.
+func NewDurable(dir string, codec Codec, maxTime time.Duration, maxItems, maxBytes, queueLen uint,
+	calculate CalculateBytes, options ...DurableOption) (DurableBatcher, error) {
+
+	if codec == nil {
+		return nil, errors.New("batcher: must provide Codec")
+	}
+	if maxBytes > 0 && calculate == nil {
+		return nil, errors.New("batcher: must provide CalculateBytes function")
+	}
+
+	b := &durableBatcher{
+		maxTime:        maxTime,
+		maxItems:       maxItems,
+		maxBytes:       maxBytes,
+		calculateBytes: calculate,
+		codec:          codec,
+		segmentSize:    DefaultSegmentSize,
+		batchChan:      make(chan Batch, queueLen),
+		lock:           newMutex(),
+		outstanding:    make(map[uint64]struct{}),
+	}
+	for _, option := range options {
+		option(b)
+	}
+
+	if err := b.open(dir); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// open replays the log in dir.
+func (b *durableBatcher) open(dir string) error {
+	var (
+		batches  []Batch
+		acked    = make(map[uint64]bool)
+		pending  []interface{}
+		segments []*segment
+		maxID    uint64
+	)
+
+	w, err := openWAL(dir, b.segmentSize, b.sync, func(s *segment, kind byte, payload []byte) error {
+		switch kind {
+		case recordItem:
+			item, err := b.codec.Decode(payload)
+			if err != nil {
+				return err
+			}
+			pending = append(pending, item)
+			if len(segments) == 0 || segments[len(segments)-1] != s {
+				segments = append(segments, s)
+			}
+		case recordBatch, recordAck:
+			if len(payload) != 8 {
+				return nil
+			}
+			id := binary.LittleEndian.Uint64(payload)
+			if id > maxID {
+				maxID = id
+			}
+			if kind == recordAck {
+				acked[id] = true
+				return nil
+			}
+
+			batches = append(batches, Batch{ID: id, Items: pending})
+			for _, s := range segments {
+				s.holds(id)
+			}
+			s.holds(id)
+			pending, segments = nil, nil
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b.wal = w
+	b.nextID = maxID + 1
+	for _, s := range segments {
+		s.holds(b.nextID)
+	}
+	for _, batch := range batches {
+		if !acked[batch.ID] {
+			b.replayed = append(b.replayed, batch)
+			b.outstanding[batch.ID] = struct{}{}
+		}
+	}
+
+	b.items = make([]interface{}, 0, b.maxItems)
+	for _, item := range pending {
+		b.items = append(b.items, item)
+		if b.calculateBytes != nil {
+			b.availableBytes += b.calculateBytes(item)
+		}
+	}
+
+	return b.wal.truncate(b.lowest())
+}
+
+// Put adds items to the batcher once they are written to the log. If the
+// item can't be written it isn't added. If the batch it completes can't
+// be written the item is added, the error is returned and the batch
+// completes with a later Put or Flush.
+func (b *durableBatcher) Put(item interface{}) error {
+	data, err := b.codec.Encode(item)
+	if err != nil {
+		return err
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.disposed {
+		return ErrDisposed
+	}
+
+	b.walLock.Lock()
+	s, err := b.wal.append(recordItem, data)
+	if err == nil {
+		s.holds(b.nextID)
+	}
+	b.walLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	b.items = append(b.items, item)
+	if b.calculateBytes != nil {
+		b.availableBytes += b.calculateBytes(item)
+	}
+	if b.ready() {
+		batch, err := b.complete()
+		if err != nil {
+			return err
+		}
+		b.batchChan <- batch
+	}
+
+	return nil
+}
+
+// Get retrieves a batch from the batcher. This call will block until
+// one of the conditions for a "complete" batch is reached.
+func (b *durableBatcher) Get() (Batch, error) {
+	return b.GetContext(context.Background())
+}
+
+// GetContext retrieves a batch from the batcher. This call will block
+// until one of the conditions for a "complete" batch is reached or the
+// context is done, in which case ctx.Err() is returned.
+func (b *durableBatcher) GetContext(ctx context.Context) (Batch, error) {
+	if err := ctx.Err(); err != nil {
+		return Batch{}, err
+	}
+
+	if batch, ok := b.popReplayed(); ok {
+		return batch, nil
+	}
+
+	var timeout <-chan time.Time
+	if b.maxTime > 0 {
+		timeout = time.After(b.maxTime)
+	}
+
+	select {
+	case batch, ok := <-b.batchChan:
+		if !ok {
+			return Batch{}, ErrDisposed
+		}
+		return batch, nil
+	case <-ctx.Done():
+		return Batch{}, ctx.Err()
+	case <-timeout:
+		// This follows the same pattern as basicBatcher's Get.
+		for {
+			if b.lock.TryLock() {
+				select {
+				case batch, ok := <-b.batchChan:
+					b.lock.Unlock()
+					if !ok {
+						return Batch{}, ErrDisposed
+					}
+					return batch, nil
+				default:
+				}
+
+				if b.disposed {
+					b.lock.Unlock()
+					return Batch{}, ErrDisposed
+				}
+				batch, err := b.complete()
+				b.lock.Unlock()
+				return batch, err
+			}
+
+			select {
+			case batch, ok := <-b.batchChan:
+				if !ok {
+					return Batch{}, ErrDisposed
+				}
+				return batch, nil
+			case <-ctx.Done():
+				return Batch{}, ctx.Err()
+			default:
+			}
+		}
+	}
+}
+
+// Ack acknowledges that the batch with the given ID was processed. Once
+// every batch with records in a segment of the log is acknowledged, the
+// segment is removed.
+func (b *durableBatcher) Ack(id uint64) error {
+	b.walLock.Lock()
+	defer b.walLock.Unlock()
+	if b.wal == nil {
+		return ErrDisposed
+	}
+	if _, ok := b.outstanding[id]; !ok {
+		return ErrUnknownBatch
+	}
+
+	payload := make([]byte, 8)
+	binary.LittleEndian.PutUint64(payload, id)
+	if _, err := b.wal.append(recordAck, payload); err != nil {
+		return err
+	}
+
+	delete(b.outstanding, id)
+	return b.wal.truncate(b.lowest())
+}
+
+// Flush forcibly completes the batch currently being built
+func (b *durableBatcher) Flush() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.disposed {
+		return ErrDisposed
+	}
+
+	if len(b.items) == 0 {
+		return nil
+	}
+
+	batch, err := b.complete()
+	if err != nil {
+		return err
+	}
+	b.batchChan <- batch
+	return nil
+}
+
+// Close closes the batcher and its log. Batches that weren't
+// acknowledged, including those still queued, are kept in the log and
+// returned again when the log is reopened.
+func (b *durableBatcher) Close() error {
+	for {
+		if b.lock.TryLock() {
+			if b.disposed {
+				b.lock.Unlock()
+				return nil
+			}
+
+			b.disposed = true
+			b.items = nil
+			b.drainBatchChan()
+			close(b.batchChan)
+
+			b.replayLock.Lock()
+			b.replayed = nil
+			b.replayLock.Unlock()
+
+			b.walLock.Lock()
+			err := b.wal.close()
+			b.wal = nil
+			b.walLock.Unlock()
+
+			b.lock.Unlock()
+			return err
+		}
+
+		// Make room for a Put or Flush blocked on a full queue.
+		b.drainBatchChan()
+	}
+}
+
+// IsClosed will determine if the batcher is closed
+func (b *durableBatcher) IsClosed() bool {
+	b.lock.Lock()
+	disposed := b.disposed
+	b.lock.Unlock()
+	return disposed
+}
+
+// complete writes the end of the batch currently being built to the log
+// and returns it. A batch without items isn't written and has ID zero.
+// complete is not threadsafe, so should be synchronized externally.
+func (b *durableBatcher) complete() (Batch, error) {
+	if len(b.items) == 0 {
+		return Batch{}, nil
+	}
+
+	b.walLock.Lock()
+	id := b.nextID
+	payload := make([]byte, 8)
+	binary.LittleEndian.PutUint64(payload, id)
+	s, err := b.wal.append(recordBatch, payload)
+	if err != nil {
+		b.walLock.Unlock()
+		return Batch{}, err
+	}
+	s.holds(id)
+	b.nextID++
+	b.outstanding[id] = struct{}{}
+	b.walLock.Unlock()
+
+	batch := Batch{ID: id, Items: b.items}
+	b.items = make([]interface{}, 0, b.maxItems)
+	b.availableBytes = 0
+	return batch, nil
+}
+
+// lowest returns the lowest ID of the batches the log must keep. It
+// must be called with walLock held.
+func (b *durableBatcher) lowest() uint64 {
+	lowest := b.nextID
+	for id := range b.outstanding {
+		if id < lowest {
+			lowest = id
+		}
+	}
+
+	return lowest
+}
+
+func (b *durableBatcher) popReplayed() (Batch, bool) {
+	b.replayLock.Lock()
+	defer b.replayLock.Unlock()
+	if len(b.replayed) == 0 {
+		return Batch{}, false
+	}
+
+	batch := b.replayed[0]
+	b.replayed[0] = Batch{}
+	b.replayed = b.replayed[1:]
+	return batch, true
+}
+
+func (b *durableBatcher) ready() bool {
+	if b.maxItems != 0 && uint(len(b.items)) >= b.maxItems {
+		return true
+	}
+	if b.maxBytes != 0 && b.availableBytes >= b.maxBytes {
+		return true
+	}
+	return false
+}
+
+func (b *durableBatcher) drainBatchChan() {
+	for {
+		select {
+		case <-b.batchChan:
+		default:
+			return
+		}
+	}
+}
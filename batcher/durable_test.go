@@ -0,0 +1,228 @@
+/*
+Copyright 2015 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package batcher
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stringCodec struct{}
+
+func (stringCodec) Encode(item interface{}) ([]byte, error) {
+	return []byte(item.(string)), nil
+}
+
+func (stringCodec) Decode(data []byte) (interface{}, error) {
+	return string(data), nil
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "batcher")
+	require.Nil(t, err)
+	return dir
+}
+
+func segments(t *testing.T, dir string) []string {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.Nil(t, err)
+	return paths
+}
+
+func TestDurableNoCodec(t *testing.T) {
+	_, err := NewDurable(os.TempDir(), nil, 0, 10, 0, 10, nil)
+	assert.Error(t, err)
+}
+
+func TestDurableMaxItems(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	b, err := NewDurable(dir, stringCodec{}, 0, 2, 0, 10, nil)
+	require.Nil(t, err)
+	defer b.Close()
+
+	for _, item := range []string{"a", "b", "c", "d"} {
+		assert.Nil(b.Put(item))
+	}
+
+	batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal(Batch{ID: 1, Items: []interface{}{"a", "b"}}, batch)
+	batch, err = b.Get()
+	assert.Nil(err)
+	assert.Equal(Batch{ID: 2, Items: []interface{}{"c", "d"}}, batch)
+
+	assert.Nil(b.Ack(2))
+	assert.Nil(b.Ack(1))
+	assert.Equal(ErrUnknownBatch, b.Ack(1))
+}
+
+func TestDurableMaxTime(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	b, err := NewDurable(dir, stringCodec{}, 10*time.Millisecond, 10, 0, 10, nil)
+	require.Nil(t, err)
+	defer b.Close()
+
+	batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal(Batch{}, batch)
+
+	assert.Nil(b.Put("a"))
+	batch, err = b.Get()
+	assert.Nil(err)
+	assert.Equal(Batch{ID: 1, Items: []interface{}{"a"}}, batch)
+}
+
+func TestDurableReplay(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	b, err := NewDurable(dir, stringCodec{}, 0, 2, 0, 10, nil)
+	require.Nil(t, err)
+	for _, item := range []string{"a", "b", "c", "d", "e"} {
+		assert.Nil(b.Put(item))
+	}
+	batch, err := b.Get()
+	assert.Nil(err)
+	assert.Nil(b.Ack(batch.ID))
+	assert.Nil(b.Close())
+	assert.True(b.IsClosed())
+
+	b, err = NewDurable(dir, stringCodec{}, 0, 2, 0, 1, nil)
+	require.Nil(t, err)
+	defer b.Close()
+
+	batch, err = b.Get()
+	assert.Nil(err)
+	assert.Equal(Batch{ID: 2, Items: []interface{}{"c", "d"}}, batch)
+
+	// the pending item is completed by the next Put
+	assert.Nil(b.Put("f"))
+	batch, err = b.Get()
+	assert.Nil(err)
+	assert.Equal(Batch{ID: 3, Items: []interface{}{"e", "f"}}, batch)
+}
+
+func TestDurableTornRecord(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	b, err := NewDurable(dir, stringCodec{}, 0, 2, 0, 10, nil)
+	require.Nil(t, err)
+	assert.Nil(b.Put("a"))
+	assert.Nil(b.Put("b"))
+	assert.Nil(b.Close())
+
+	paths := segments(t, dir)
+	f, err := os.OpenFile(paths[len(paths)-1], os.O_APPEND|os.O_WRONLY, 0644)
+	require.Nil(t, err)
+	f.Write([]byte{10, 0, 0, 0, 1, 2, 3, 4, recordItem, 'x'})
+	f.Close()
+
+	b, err = NewDurable(dir, stringCodec{}, 0, 2, 0, 10, nil)
+	require.Nil(t, err)
+	defer b.Close()
+
+	batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal(Batch{ID: 1, Items: []interface{}{"a", "b"}}, batch)
+	assert.Nil(b.Flush())
+	assert.Len(b.(*durableBatcher).items, 0)
+}
+
+func TestDurableTruncate(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	b, err := NewDurable(dir, stringCodec{}, 0, 2, 0, 100, nil, SegmentSize(32))
+	require.Nil(t, err)
+	defer b.Close()
+
+	for i := 0; i < 20; i++ {
+		assert.Nil(b.Put("item"))
+	}
+	assert.True(len(segments(t, dir)) > 5)
+
+	var batches []Batch
+	for i := 0; i < 10; i++ {
+		batch, err := b.Get()
+		assert.Nil(err)
+		batches = append(batches, batch)
+	}
+
+	// the oldest segment is kept until the first batch is acknowledged
+	oldest := segments(t, dir)[0]
+	assert.Nil(b.Ack(batches[9].ID))
+	assert.Nil(b.Ack(batches[5].ID))
+	assert.Equal(oldest, segments(t, dir)[0])
+
+	for _, batch := range batches[:9] {
+		if batch.ID != batches[5].ID {
+			assert.Nil(b.Ack(batch.ID))
+		}
+	}
+	assert.Len(segments(t, dir), 1)
+}
+
+func TestDurableClose(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	b, err := NewDurable(dir, stringCodec{}, 0, 1, 0, 1, nil)
+	require.Nil(t, err)
+
+	assert.Nil(b.Put("a"))
+	done := make(chan struct{})
+	go func() {
+		b.Put("b")
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	assert.Nil(b.Close())
+	<-done
+	assert.Nil(b.Close())
+	assert.Equal(ErrDisposed, b.Put("c"))
+	assert.Equal(ErrDisposed, b.Flush())
+	assert.Equal(ErrDisposed, b.Ack(1))
+	_, err = b.Get()
+	assert.Equal(ErrDisposed, err)
+
+	// nothing was acknowledged, so everything comes back
+	b, err = NewDurable(dir, BytesCodec{}, 0, 1, 0, 10, nil)
+	require.Nil(t, err)
+	defer b.Close()
+	for _, item := range []string{"a", "b"} {
+		batch, err := b.Get()
+		assert.Nil(err)
+		assert.Equal([]interface{}{[]byte(item)}, batch.Items)
+	}
+}
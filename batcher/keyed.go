@@ -0,0 +1,483 @@
+/*
+Copyright 2015 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"time"
+)
+
+// KeyFunc returns the key of the partition an item is batched in.
+type KeyFunc func(interface{}) string
+
+// LimitsFunc returns the maximum time, number of items and number of
+// bytes of the batches of the partition with the given key.
+type LimitsFunc func(key string) (maxTime time.Duration, maxItems, maxBytes uint)
+
+// KeyedBatcher accumulates items into batches that only hold items of
+// the same key. Every key is batched in its own partition with its own
+// readiness thresholds.
+type KeyedBatcher interface {
+	// Put adds items to the batcher.
+	Put(interface{}) error
+
+	// PutContext adds items to the batcher. If the context is done
+	// before the item could be added, ctx.Err() is returned and the
+	// item is not added.
+	PutContext(context.Context, interface{}) error
+
+	// Get retrieves a batch and its key from the batcher. This call
+	// will block until a batch of any partition is complete.
+	Get() (string, []interface{}, error)
+
+	// GetContext retrieves a batch and its key from the batcher. This
+	// call will block until a batch of any partition is complete or the
+	// context is done, in which case ctx.Err() is returned.
+	GetContext(context.Context) (string, []interface{}, error)
+
+	// Flush forcibly completes the batches of all partitions
+	Flush() error
+
+	// Dispose will dispose of the batcher. Any calls to Put or Flush
+	// will return ErrDisposed, calls to Get will return an error iff
+	// there are no more ready batches.
+	Dispose()
+
+	// IsDisposed will determine if the batcher is disposed
+	IsDisposed() bool
+}
+
+// KeyedOption configures a KeyedBatcher.
+type KeyedOption func(*keyedBatcher)
+
+// PartitionLimits sets a function that returns the thresholds of the
+// partition with the given key, overriding the thresholds passed to
+// NewKeyed for that partition. It is called once per partition when
+// the partition is opened.
+func PartitionLimits(limits LimitsFunc) KeyedOption {
+	return func(b *keyedBatcher) {
+		b.limits = limits
+	}
+}
+
+// MaxPendingBytes caps the number of bytes held across all partitions in
+// batches that are still being built. Adding an item that would exceed
+// the cap first completes the batches of the largest partitions until
+// the item fits. If not provided, pending bytes are not capped.
+func MaxPendingBytes(n uint) KeyedOption {
+	return func(b *keyedBatcher) {
+		b.maxPendingBytes = n
+	}
+}
+
+// MaxPartitions caps the number of open partitions. Adding an item with
+// a new key while at the cap evicts the least recently used partition,
+// completing its batch. If not provided, partitions are not capped.
+func MaxPartitions(n uint) KeyedOption {
+	return func(b *keyedBatcher) {
+		b.maxPartitions = n
+	}
+}
+
+// IdleTimeout evicts partitions that haven't been added to for the
+// given duration, completing their batch. If not provided, idle
+// partitions are only evicted to respect MaxPartitions.
+func IdleTimeout(d time.Duration) KeyedOption {
+	return func(b *keyedBatcher) {
+		b.idleTimeout = d
+	}
+}
+
+type keyedBatch struct {
+	key   string
+	items []interface{}
+}
+
+type partition struct {
+	key      string
+	maxTime  time.Duration
+	maxItems uint
+	maxBytes uint
+	items    []interface{}
+	bytes    uint
+	// started is when the first item of the batch being built was
+	// added, lastUsed is when any item was last added.
+	started  time.Time
+	lastUsed time.Time
+	// element is the partition's node in the list of partitions in
+	// order of last use.
+	element *list.Element
+}
+
+func (p *partition) ready() bool {
+	if p.maxItems != 0 && uint(len(p.items)) >= p.maxItems {
+		return true
+	}
+	if p.maxBytes != 0 && p.bytes >= p.maxBytes {
+		return true
+	}
+	return false
+}
+
+type keyedBatcher struct {
+	key             KeyFunc
+	maxTime         time.Duration
+	maxItems        uint
+	maxBytes        uint
+	calculateBytes  CalculateBytes
+	limits          LimitsFunc
+	maxPendingBytes uint
+	maxPartitions   uint
+	idleTimeout     time.Duration
+	disposed        bool
+	partitions      map[string]*partition
+	lru             *list.List
+	pendingBytes    uint
+	batchChan       chan keyedBatch
+	lock            *mutex
+	// wake is signaled when a deadline may have moved closer, done is
+	// closed on dispose.
+	wake chan struct{}
+	done chan struct{}
+}
+
+// NewKeyed creates a new KeyedBatcher that batches items by the key
+// returned by key. Batch readiness of every partition is determined the
+// same way as for a Batcher created by New, unless PartitionLimits is
+// provided. A batch that reached maxTime is completed even if no Get is
+// waiting. Completed batches of all partitions share one queue of
+// length queueLen.
+func NewKeyed(key KeyFunc, maxTime time.Duration, maxItems, maxBytes, queueLen uint,
+	calculate CalculateBytes, options ...KeyedOption) (KeyedBatcher, error) {
+
+	if key == nil {
+		return nil, errors.New("batcher: must provide KeyFunc function")
+	}
+
+	b := &keyedBatcher{
+		key:            key,
+		maxTime:        maxTime,
+		maxItems:       maxItems,
+		maxBytes:       maxBytes,
+		calculateBytes: calculate,
+		partitions:     make(map[string]*partition),
+		lru:            list.New(),
+		batchChan:      make(chan keyedBatch, queueLen),
+		lock:           newMutex(),
+		wake:           make(chan struct{}, 1),
+		done:           make(chan struct{}),
+	}
+	for _, option := range options {
+		option(b)
+	}
+
+	if (b.maxBytes > 0 || b.maxPendingBytes > 0 || b.limits != nil) && calculate == nil {
+		return nil, errors.New("batcher: must provide CalculateBytes function")
+	}
+
+	go b.expire()
+	return b, nil
+}
+
+// Put adds items to the batcher.
+func (b *keyedBatcher) Put(item interface{}) error {
+	return b.PutContext(context.Background(), item)
+}
+
+// PutContext adds items to the batcher. If the context is done before
+// the item could be added, ctx.Err() is returned and the item is not
+// added.
+func (b *keyedBatcher) PutContext(ctx context.Context, item interface{}) error {
+	if err := b.lock.LockContext(ctx); err != nil {
+		return err
+	}
+	defer b.lock.Unlock()
+	if b.disposed {
+		return ErrDisposed
+	}
+
+	var bytes uint
+	if b.calculateBytes != nil {
+		bytes = b.calculateBytes(item)
+	}
+
+	// Make room before touching the partition, so a cancellation
+	// leaves the item out entirely.
+	if b.maxPendingBytes > 0 {
+		for b.pendingBytes > 0 && b.pendingBytes+bytes > b.maxPendingBytes {
+			if err := b.flush(ctx, b.largest()); err != nil {
+				return err
+			}
+		}
+	}
+
+	key := b.key(item)
+	p, ok := b.partitions[key]
+	if !ok && b.maxPartitions > 0 && uint(len(b.partitions)) >= b.maxPartitions {
+		if err := b.evict(ctx, b.lru.Front().Value.(*partition)); err != nil {
+			return err
+		}
+	}
+	if !ok {
+		p = b.open(key)
+	}
+
+	now := time.Now()
+	if len(p.items) == 0 {
+		p.started = now
+		b.signal()
+	}
+	p.items = append(p.items, item)
+	p.bytes += bytes
+	b.pendingBytes += bytes
+	if p.ready() {
+		if err := b.flush(ctx, p); err != nil {
+			// The queue stayed full, take the item back out so the
+			// caller can retry. The batch can't have been ready before
+			// this item was added, so it isn't ready now.
+			p.items[len(p.items)-1] = nil
+			p.items = p.items[:len(p.items)-1]
+			p.bytes -= bytes
+			b.pendingBytes -= bytes
+			return err
+		}
+	}
+
+	p.lastUsed = now
+	b.lru.MoveToBack(p.element)
+	return nil
+}
+
+// Get retrieves a batch and its key from the batcher.
+func (b *keyedBatcher) Get() (string, []interface{}, error) {
+	return b.GetContext(context.Background())
+}
+
+// GetContext retrieves a batch and its key from the batcher, giving up
+// if the context is done first.
+func (b *keyedBatcher) GetContext(ctx context.Context) (string, []interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+
+	select {
+	case batch, ok := <-b.batchChan:
+		if !ok {
+			return "", nil, ErrDisposed
+		}
+		return batch.key, batch.items, nil
+	case <-ctx.Done():
+		return "", nil, ctx.Err()
+	}
+}
+
+// Flush forcibly completes the batches of all partitions
+func (b *keyedBatcher) Flush() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.disposed {
+		return ErrDisposed
+	}
+
+	for e := b.lru.Front(); e != nil; e = e.Next() {
+		b.flush(context.Background(), e.Value.(*partition))
+	}
+	return nil
+}
+
+// Dispose will dispose of the batcher. Any calls to Put or Flush
+// will return ErrDisposed, calls to Get will return an error iff
+// there are no more ready batches. Any items not flushed and retrieved
+// by a Get may or may not be retrievable after calling this.
+func (b *keyedBatcher) Dispose() {
+	for {
+		if b.lock.TryLock() {
+			if b.disposed {
+				b.lock.Unlock()
+				return
+			}
+
+			b.disposed = true
+			b.partitions = nil
+			b.lru.Init()
+			b.pendingBytes = 0
+			close(b.done)
+			b.drainBatchChan()
+			close(b.batchChan)
+			b.lock.Unlock()
+		} else {
+			// Something may be blocked on a full queue while holding
+			// the lock, make room so it can release it.
+			b.drainBatchChan()
+		}
+	}
+}
+
+// IsDisposed will determine if the batcher is disposed
+func (b *keyedBatcher) IsDisposed() bool {
+	b.lock.Lock()
+	disposed := b.disposed
+	b.lock.Unlock()
+	return disposed
+}
+
+// open adds a new partition for the given key.
+func (b *keyedBatcher) open(key string) *partition {
+	p := &partition{
+		key:      key,
+		maxTime:  b.maxTime,
+		maxItems: b.maxItems,
+		maxBytes: b.maxBytes,
+		// Counts as used from the start, so a partition whose first
+		// put fails isn't evicted as idle right away.
+		lastUsed: time.Now(),
+	}
+	if b.limits != nil {
+		p.maxTime, p.maxItems, p.maxBytes = b.limits(key)
+	}
+
+	p.element = b.lru.PushBack(p)
+	b.partitions[key] = p
+	return p
+}
+
+// flush adds the batch being built by the partition to the queue of
+// completed batches, leaving the partition untouched if the context is
+// done while the queue is full. flush is not threadsafe, so should be
+// synchronized externally.
+func (b *keyedBatcher) flush(ctx context.Context, p *partition) error {
+	if len(p.items) == 0 {
+		return nil
+	}
+
+	select {
+	case b.batchChan <- keyedBatch{key: p.key, items: p.items}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	b.pendingBytes -= p.bytes
+	p.items = make([]interface{}, 0, p.maxItems)
+	p.bytes = 0
+	return nil
+}
+
+// evict flushes and removes the partition.
+func (b *keyedBatcher) evict(ctx context.Context, p *partition) error {
+	if err := b.flush(ctx, p); err != nil {
+		return err
+	}
+
+	b.lru.Remove(p.element)
+	delete(b.partitions, p.key)
+	return nil
+}
+
+// largest returns the partition with the most pending bytes.
+func (b *keyedBatcher) largest() *partition {
+	var largest *partition
+	for _, p := range b.partitions {
+		if largest == nil || p.bytes > largest.bytes {
+			largest = p
+		}
+	}
+
+	return largest
+}
+
+// signal wakes the expiry goroutine so it picks up a new deadline.
+func (b *keyedBatcher) signal() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// expire completes batches that reached their partition's maxTime and
+// evicts idle partitions, sleeping until the next deadline in between.
+func (b *keyedBatcher) expire() {
+	timer := time.NewTimer(0)
+	for {
+		select {
+		case <-timer.C:
+		case <-b.wake:
+		case <-b.done:
+			timer.Stop()
+			return
+		}
+
+		b.lock.Lock()
+		if b.disposed {
+			b.lock.Unlock()
+			return
+		}
+		next := b.expireAt(time.Now())
+		b.lock.Unlock()
+
+		timer.Stop()
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// expireAt flushes and evicts the partitions whose deadlines have
+// passed at now and returns the earliest deadline still pending, or
+// the zero time if there is none.
+func (b *keyedBatcher) expireAt(now time.Time) time.Time {
+	var next time.Time
+	earliest := func(deadline time.Time) {
+		if next.IsZero() || deadline.Before(next) {
+			next = deadline
+		}
+	}
+
+	for e := b.lru.Front(); e != nil; {
+		p := e.Value.(*partition)
+		e = e.Next()
+
+		if len(p.items) > 0 && p.maxTime > 0 {
+			if deadline := p.started.Add(p.maxTime); !deadline.After(now) {
+				b.flush(context.Background(), p)
+			} else {
+				earliest(deadline)
+			}
+		}
+
+		if b.idleTimeout > 0 {
+			if deadline := p.lastUsed.Add(b.idleTimeout); !deadline.After(now) {
+				b.evict(context.Background(), p)
+			} else {
+				earliest(deadline)
+			}
+		}
+	}
+
+	return next
+}
+
+func (b *keyedBatcher) drainBatchChan() {
+	for {
+		select {
+		case <-b.batchChan:
+		default:
+			return
+		}
+	}
+}
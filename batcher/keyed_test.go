@@ -0,0 +1,259 @@
+/*
+Copyright 2015 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package batcher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// keyOf keys strings by their first character.
+func keyOf(item interface{}) string {
+	return item.(string)[:1]
+}
+
+func TestKeyedNoKeyFunc(t *testing.T) {
+	_, err := NewKeyed(nil, 0, 10, 0, 10, nil)
+	assert.Error(t, err)
+
+	_, err = NewKeyed(keyOf, 0, 10, 0, 10, nil, MaxPendingBytes(10))
+	assert.Error(t, err)
+}
+
+func TestKeyedMaxItems(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewKeyed(keyOf, 0, 2, 0, 10, nil)
+	assert.Nil(err)
+
+	for _, item := range []string{"a1", "b1", "a2", "b2"} {
+		assert.Nil(b.Put(item))
+	}
+
+	key, batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal("a", key)
+	assert.Equal([]interface{}{"a1", "a2"}, batch)
+
+	key, batch, err = b.Get()
+	assert.Nil(err)
+	assert.Equal("b", key)
+	assert.Equal([]interface{}{"b1", "b2"}, batch)
+}
+
+func TestKeyedMaxTime(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewKeyed(keyOf, 20*time.Millisecond, 100, 0, 10, nil)
+	assert.Nil(err)
+
+	start := time.Now()
+	assert.Nil(b.Put("a1"))
+	time.Sleep(10 * time.Millisecond)
+	assert.Nil(b.Put("b1"))
+
+	key, batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal("a", key)
+	assert.Equal([]interface{}{"a1"}, batch)
+	assert.True(time.Since(start) >= 20*time.Millisecond)
+
+	key, batch, err = b.Get()
+	assert.Nil(err)
+	assert.Equal("b", key)
+	assert.Equal([]interface{}{"b1"}, batch)
+	assert.True(time.Since(start) >= 30*time.Millisecond)
+}
+
+func TestKeyedPartitionLimits(t *testing.T) {
+	assert := assert.New(t)
+	limits := func(key string) (time.Duration, uint, uint) {
+		if key == "a" {
+			return 0, 1, 0
+		}
+		return 0, 0, 4
+	}
+	b, err := NewKeyed(keyOf, 0, 0, 0, 10, func(item interface{}) uint {
+		return uint(len(item.(string)))
+	}, PartitionLimits(limits))
+	assert.Nil(err)
+
+	assert.Nil(b.Put("b1"))
+	assert.Nil(b.Put("a1"))
+	assert.Nil(b.Put("b2"))
+
+	key, batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal("a", key)
+	assert.Equal([]interface{}{"a1"}, batch)
+
+	key, batch, err = b.Get()
+	assert.Nil(err)
+	assert.Equal("b", key)
+	assert.Equal([]interface{}{"b1", "b2"}, batch)
+}
+
+func TestKeyedMaxPendingBytes(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewKeyed(keyOf, 0, 0, 0, 10, func(item interface{}) uint {
+		return uint(len(item.(string)))
+	}, MaxPendingBytes(6))
+	assert.Nil(err)
+
+	assert.Nil(b.Put("a1"))
+	assert.Nil(b.Put("a2"))
+	assert.Nil(b.Put("b1"))
+	// a holds the most bytes and is completed to make room
+	assert.Nil(b.Put("b2"))
+
+	key, batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal("a", key)
+	assert.Equal([]interface{}{"a1", "a2"}, batch)
+
+	assert.Nil(b.Flush())
+	key, batch, err = b.Get()
+	assert.Nil(err)
+	assert.Equal("b", key)
+	assert.Equal([]interface{}{"b1", "b2"}, batch)
+}
+
+func TestKeyedMaxPartitions(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewKeyed(keyOf, 0, 0, 0, 10, nil, MaxPartitions(2))
+	assert.Nil(err)
+
+	assert.Nil(b.Put("a1"))
+	assert.Nil(b.Put("b1"))
+	assert.Nil(b.Put("a2"))
+	// b is the least recently used partition
+	assert.Nil(b.Put("c1"))
+
+	key, batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal("b", key)
+	assert.Equal([]interface{}{"b1"}, batch)
+	assert.Len(b.(*keyedBatcher).partitions, 2)
+}
+
+func TestKeyedIdleTimeout(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewKeyed(keyOf, 0, 0, 0, 10, nil, IdleTimeout(10*time.Millisecond))
+	assert.Nil(err)
+
+	assert.Nil(b.Put("a1"))
+	key, batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal("a", key)
+	assert.Equal([]interface{}{"a1"}, batch)
+
+	kb := b.(*keyedBatcher)
+	kb.lock.Lock()
+	assert.Len(kb.partitions, 0)
+	kb.lock.Unlock()
+}
+
+func TestKeyedFlush(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewKeyed(keyOf, 0, 10, 0, 10, nil)
+	assert.Nil(err)
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(b.Put(fmt.Sprintf("%c1", 'a'+i)))
+	}
+	assert.Nil(b.Flush())
+
+	keys := []string{}
+	for i := 0; i < 3; i++ {
+		key, batch, err := b.Get()
+		assert.Nil(err)
+		assert.Len(batch, 1)
+		keys = append(keys, key)
+	}
+	assert.Equal([]string{"a", "b", "c"}, keys)
+}
+
+func TestKeyedPutContextQueueFull(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewKeyed(keyOf, 0, 1, 0, 1, nil)
+	assert.Nil(err)
+
+	assert.Nil(b.Put("a1"))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, b.PutContext(ctx, "a2"))
+
+	_, batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal([]interface{}{"a1"}, batch)
+
+	// the rejected item was not kept
+	assert.Nil(b.Put("a3"))
+	_, batch, err = b.Get()
+	assert.Nil(err)
+	assert.Equal([]interface{}{"a3"}, batch)
+}
+
+func TestKeyedPutContextQueueFullNewPartition(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewKeyed(keyOf, 0, 1, 0, 1, nil, IdleTimeout(time.Hour))
+	assert.Nil(err)
+
+	assert.Nil(b.Put("a1"))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, b.PutContext(ctx, "b1"))
+
+	// the partition opened by the failed put isn't idle yet
+	kb := b.(*keyedBatcher)
+	kb.lock.Lock()
+	kb.expireAt(time.Now())
+	assert.Contains(kb.partitions, "b")
+	kb.lock.Unlock()
+}
+
+func TestKeyedGetContextCanceled(t *testing.T) {
+	b, err := NewKeyed(keyOf, 0, 10, 0, 10, nil)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err = b.GetContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestKeyedDispose(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewKeyed(keyOf, 0, 1, 0, 1, nil)
+	assert.Nil(err)
+
+	assert.Nil(b.Put("a1"))
+	done := make(chan error)
+	go func() {
+		done <- b.Put("a2")
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	b.Dispose()
+	<-done
+	assert.True(b.IsDisposed())
+	assert.Equal(ErrDisposed, b.Put("a3"))
+	assert.Equal(ErrDisposed, b.Flush())
+	_, _, err = b.Get()
+	assert.Equal(ErrDisposed, err)
+}
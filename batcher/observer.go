@@ -0,0 +1,97 @@
+/*
+Copyright 2015 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import "time"
+
+// FlushReason is the condition that completed a batch.
+type FlushReason int
+
+const (
+	// MaxItemsReached means the batch held maxItems items.
+	MaxItemsReached FlushReason = iota
+	// MaxBytesReached means the batch held maxBytes bytes.
+	MaxBytesReached
+	// MaxTimeReached means a Get waited maxTime for the batch.
+	MaxTimeReached
+	// FlushCalled means the batch was completed by Flush.
+	FlushCalled
+	// Closed means the batch was completed because the batcher was
+	// shutting down.
+	Closed
+)
+
+func (r FlushReason) String() string {
+	switch r {
+	case MaxItemsReached:
+		return "max items"
+	case MaxBytesReached:
+		return "max bytes"
+	case MaxTimeReached:
+		return "max time"
+	case FlushCalled:
+		return "flush"
+	case Closed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// BatchEvent describes a completed batch.
+type BatchEvent struct {
+	// Items is the number of items in the batch.
+	Items int
+	// Bytes is the size of the batch as evaluated by CalculateBytes,
+	// zero if no CalculateBytes function was provided.
+	Bytes uint
+	// Age is the time since the oldest item of the batch was added.
+	Age time.Duration
+	// Reason is the condition that completed the batch.
+	Reason FlushReason
+}
+
+// Observer receives events from a Batcher. Its methods are called
+// synchronously, mostly while the batcher is locked, so they should
+// return quickly and must not call back into the batcher.
+type Observer interface {
+	// BatchCompleted is called for every completed batch that holds
+	// at least one item.
+	BatchCompleted(BatchEvent)
+
+	// QueueDepth is called with the number of completed batches
+	// waiting for a Get every time a batch is added to or taken from
+	// the queue.
+	QueueDepth(int)
+}
+
+// NopObserver is an Observer that ignores all events. It can be
+// embedded to implement only some of the methods of Observer.
+type NopObserver struct{}
+
+// BatchCompleted does nothing.
+func (NopObserver) BatchCompleted(BatchEvent) {}
+
+// QueueDepth does nothing.
+func (NopObserver) QueueDepth(int) {}
+
+// Observe sets the Observer of the batcher. By default events aren't
+// tracked at all.
+func Observe(observer Observer) Option {
+	return func(b *basicBatcher) {
+		b.observer = observer
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2015 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package batcher
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type observer struct {
+	sync.Mutex
+	events []BatchEvent
+	depths []int
+}
+
+func (o *observer) BatchCompleted(event BatchEvent) {
+	o.Lock()
+	o.events = append(o.events, event)
+	o.Unlock()
+}
+
+func (o *observer) QueueDepth(depth int) {
+	o.Lock()
+	o.depths = append(o.depths, depth)
+	o.Unlock()
+}
+
+func (o *observer) reasons() []FlushReason {
+	o.Lock()
+	defer o.Unlock()
+	reasons := make([]FlushReason, 0, len(o.events))
+	for _, event := range o.events {
+		reasons = append(reasons, event.Reason)
+	}
+	return reasons
+}
+
+func TestObserverReasons(t *testing.T) {
+	assert := assert.New(t)
+	o := &observer{}
+	b, err := New(10*time.Millisecond, 3, 10, 10, func(item interface{}) uint {
+		return uint(len(item.(string)))
+	}, Observe(o))
+	assert.Nil(err)
+
+	for _, item := range []string{"a", "b", "c"} {
+		assert.Nil(b.Put(item))
+	}
+	assert.Nil(b.Put("0123456789"))
+	assert.Nil(b.Put("d"))
+	assert.Nil(b.Flush())
+
+	for i := 0; i < 3; i++ {
+		_, err := b.Get()
+		assert.Nil(err)
+	}
+
+	assert.Nil(b.Put("e"))
+	batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal([]interface{}{"e"}, batch)
+
+	assert.Equal([]FlushReason{MaxItemsReached, MaxBytesReached, FlushCalled, MaxTimeReached}, o.reasons())
+	assert.Equal(BatchEvent{Items: 3, Bytes: 3, Age: o.events[0].Age, Reason: MaxItemsReached}, o.events[0])
+	assert.Equal(10, int(o.events[1].Bytes))
+	assert.True(o.events[3].Age >= 10*time.Millisecond)
+	assert.Equal([]int{1, 2, 3, 2, 1, 0}, o.depths)
+}
+
+func TestObserverSkipsEmptyBatches(t *testing.T) {
+	assert := assert.New(t)
+	o := &observer{}
+	b, err := New(time.Millisecond, 0, 0, 10, nil, Observe(o))
+	assert.Nil(err)
+
+	batch, err := b.Get()
+	assert.Nil(err)
+	assert.Len(batch, 0)
+	assert.Nil(b.Flush())
+
+	assert.Len(o.events, 0)
+}
+
+func TestObserverClosed(t *testing.T) {
+	assert := assert.New(t)
+	o := &observer{}
+	b, err := New(0, 10, 0, 10, nil, Observe(o))
+	assert.Nil(err)
+
+	assert.Nil(b.Put("a"))
+	b.(*basicBatcher).close()
+	assert.Equal([]FlushReason{Closed}, o.reasons())
+}
+
+func TestNopObserver(t *testing.T) {
+	var o Observer = NopObserver{}
+	o.BatchCompleted(BatchEvent{})
+	o.QueueDepth(1)
+	assert.Equal(t, "max items", MaxItemsReached.String())
+	assert.Equal(t, "unknown", FlushReason(-1).String())
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2015 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import "errors"
+
+// ErrFull is returned by Put and Flush when the queue of completed
+// batches is full and the FailFast overflow policy is in effect.
+var ErrFull = errors.New("batcher: queue full")
+
+// OverflowPolicy determines what happens to a completed batch when the
+// queue of completed batches is full.
+type OverflowPolicy int
+
+const (
+	// Block waits for a Get to make room in the queue. This is the
+	// default.
+	Block OverflowPolicy = iota
+	// FailFast returns ErrFull. The item that completed the batch is not
+	// added, so the caller can retry it later.
+	FailFast
+	// DropNewest drops the item that completed the batch, or the whole
+	// batch on Flush.
+	DropNewest
+	// DropOldest drops the oldest batches in the queue until the
+	// completed batch fits. With a queue length of zero no batch is ever
+	// waiting in the queue, so this behaves like DropNewest.
+	DropOldest
+)
+
+// DropHandler is called with every item dropped by an overflow policy.
+// It is called while the batcher is locked, so it must not call back
+// into the batcher.
+type DropHandler func(interface{})
+
+// Stats holds the counters of a Batcher.
+type Stats struct {
+	// Queued is the number of items added by Put, not counting items
+	// rejected with an error or dropped by DropNewest.
+	Queued uint64
+	// Dropped is the number of items dropped by an overflow policy.
+	Dropped uint64
+	// Flushed is the number of items in batches that were completed,
+	// including batches later dropped by DropOldest.
+	Flushed uint64
+}
+
+// Option configures a Batcher.
+type Option func(*basicBatcher)
+
+// Overflow sets the policy applied when the queue of completed batches
+// is full.
+func Overflow(policy OverflowPolicy) Option {
+	return func(b *basicBatcher) {
+		b.overflow = policy
+	}
+}
+
+// OnDrop sets the function called with every item dropped by an
+// overflow policy.
+func OnDrop(fn DropHandler) Option {
+	return func(b *basicBatcher) {
+		b.onDrop = fn
+	}
+}
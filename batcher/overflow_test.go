@@ -0,0 +1,110 @@
+/*
+Copyright 2015 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package batcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverflowFailFast(t *testing.T) {
+	assert := assert.New(t)
+	b, err := New(0, 2, 0, 1, nil, Overflow(FailFast))
+	assert.Nil(err)
+
+	assert.Nil(b.Put("a"))
+	assert.Nil(b.Put("b"))
+	assert.Nil(b.Put("c"))
+	assert.Equal(ErrFull, b.Put("d"))
+	assert.Equal(ErrFull, b.Flush())
+
+	batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal([]interface{}{"a", "b"}, batch)
+
+	// the rejected item can be retried
+	assert.Nil(b.Put("d"))
+	batch, err = b.Get()
+	assert.Nil(err)
+	assert.Equal([]interface{}{"c", "d"}, batch)
+
+	assert.Equal(Stats{Queued: 4, Flushed: 4}, b.Stats())
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	assert := assert.New(t)
+	var dropped []interface{}
+	b, err := New(0, 2, 0, 1, nil, Overflow(DropNewest), OnDrop(func(item interface{}) {
+		dropped = append(dropped, item)
+	}))
+	assert.Nil(err)
+
+	for _, item := range []string{"a", "b", "c", "d", "e"} {
+		assert.Nil(b.Put(item))
+	}
+	assert.Nil(b.Flush())
+	assert.Equal([]interface{}{"d", "e", "c"}, dropped)
+
+	batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal([]interface{}{"a", "b"}, batch)
+
+	assert.Equal(Stats{Queued: 3, Dropped: 3, Flushed: 2}, b.Stats())
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	assert := assert.New(t)
+	var dropped []interface{}
+	b, err := New(0, 2, 0, 1, nil, Overflow(DropOldest), OnDrop(func(item interface{}) {
+		dropped = append(dropped, item)
+	}))
+	assert.Nil(err)
+
+	for _, item := range []string{"a", "b", "c", "d", "e", "f"} {
+		assert.Nil(b.Put(item))
+	}
+	assert.Equal([]interface{}{"a", "b", "c", "d"}, dropped)
+
+	batch, err := b.Get()
+	assert.Nil(err)
+	assert.Equal([]interface{}{"e", "f"}, batch)
+
+	assert.Equal(Stats{Queued: 6, Dropped: 4, Flushed: 6}, b.Stats())
+}
+
+func TestOverflowDropOldestUnbuffered(t *testing.T) {
+	assert := assert.New(t)
+	b, err := New(0, 1, 0, 0, nil, Overflow(DropOldest))
+	assert.Nil(err)
+
+	assert.Nil(b.Put("a"))
+	assert.Equal(Stats{Dropped: 1}, b.Stats())
+}
+
+func TestStatsBlock(t *testing.T) {
+	assert := assert.New(t)
+	b, err := New(0, 2, 0, 10, nil)
+	assert.Nil(err)
+
+	for i := 0; i < 5; i++ {
+		assert.Nil(b.Put(i))
+	}
+	assert.Equal(Stats{Queued: 5, Flushed: 4}, b.Stats())
+
+	assert.Nil(b.Flush())
+	assert.Equal(Stats{Queued: 5, Flushed: 5}, b.Stats())
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2015 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Handler processes a completed batch. A non-nil error causes the batch
+// to be retried if the push batcher was configured to do so.
+type Handler func([]interface{}) error
+
+// DeadLetterHandler is called with a batch the Handler still failed to
+// process after all retries, along with the last error returned.
+type DeadLetterHandler func([]interface{}, error)
+
+// PushBatcher accumulates items into batches like a Batcher, but hands
+// every completed batch to a Handler instead of waiting for a Get.
+type PushBatcher interface {
+	// Put adds items to the batcher.
+	Put(interface{}) error
+
+	// PutContext adds items to the batcher. If the context is done
+	// before the item could be added, ctx.Err() is returned and the
+	// item is not added.
+	PutContext(context.Context, interface{}) error
+
+	// Flush forcibly completes the batch currently being built
+	Flush() error
+
+	// Dispose will dispose of the batcher. Any calls to Put or Flush
+	// will return ErrDisposed. The batch currently being built is
+	// completed and Dispose blocks until every completed batch has
+	// been handled, including any retries.
+	Dispose()
+
+	// IsDisposed will determine if the batcher is disposed
+	IsDisposed() bool
+}
+
+// PushOption configures a PushBatcher.
+type PushOption func(*pushBatcher)
+
+// Workers sets the number of batches that may be handled concurrently.
+// If not provided, default is 1, which handles batches in order.
+func Workers(n uint) PushOption {
+	return func(b *pushBatcher) {
+		if n > 0 {
+			b.workers = n
+		}
+	}
+}
+
+// Retry sets the number of times a batch is retried after the Handler
+// returns an error. The wait before the first retry is backoff, doubling
+// for every further retry up to maxBackoff. A maxBackoff of zero leaves
+// the wait unbounded. If not provided, batches are not retried.
+func Retry(retries uint, backoff, maxBackoff time.Duration) PushOption {
+	return func(b *pushBatcher) {
+		b.retries = retries
+		b.backoff = backoff
+		b.maxBackoff = maxBackoff
+	}
+}
+
+// DeadLetter sets the function called with batches that failed to be
+// handled after all retries. If not provided, such batches are dropped.
+func DeadLetter(handler DeadLetterHandler) PushOption {
+	return func(b *pushBatcher) {
+		b.deadLetter = handler
+	}
+}
+
+// BatcherOptions sets options of the underlying Batcher, such as
+// Overflow, OnDrop and Observe. The queue those options apply to holds
+// the completed batches waiting for a free worker.
+func BatcherOptions(options ...Option) PushOption {
+	return func(b *pushBatcher) {
+		b.options = append(b.options, options...)
+	}
+}
+
+type pushBatcher struct {
+	*basicBatcher
+	handler    Handler
+	deadLetter DeadLetterHandler
+	workers    uint
+	retries    uint
+	backoff    time.Duration
+	maxBackoff time.Duration
+	options    []Option
+	done       sync.WaitGroup
+}
+
+// NewPush creates a new PushBatcher that calls handler with every
+// completed batch. Batch readiness is determined the same way as for a
+// Batcher created by New. Batches are handed to a bounded pool of
+// workers; while all workers are busy, completed batches queue up to
+// queueLen, after which Put blocks unless another overflow policy is
+// set with BatcherOptions.
+func NewPush(handler Handler, maxTime time.Duration, maxItems, maxBytes, queueLen uint,
+	calculate CalculateBytes, options ...PushOption) (PushBatcher, error) {
+
+	if handler == nil {
+		return nil, errors.New("batcher: must provide Handler function")
+	}
+
+	pb := &pushBatcher{
+		handler: handler,
+		workers: 1,
+	}
+	for _, option := range options {
+		option(pb)
+	}
+
+	b, err := New(maxTime, maxItems, maxBytes, queueLen, calculate, pb.options...)
+	if err != nil {
+		return nil, err
+	}
+	pb.basicBatcher = b.(*basicBatcher)
+
+	work := make(chan []interface{})
+	pb.done.Add(int(pb.workers) + 1)
+	for i := uint(0); i < pb.workers; i++ {
+		go pb.work(work)
+	}
+	go pb.dispatch(work)
+
+	return pb, nil
+}
+
+// dispatch hands completed batches to the workers until the batcher is
+// disposed and every completed batch has been handed out.
+func (b *pushBatcher) dispatch(work chan<- []interface{}) {
+	defer b.done.Done()
+	defer close(work)
+
+	for {
+		batch, err := b.Get()
+		if err != nil {
+			return
+		}
+
+		// batches completed by maxTime may be empty
+		if len(batch) > 0 {
+			work <- batch
+		}
+	}
+}
+
+func (b *pushBatcher) work(work <-chan []interface{}) {
+	defer b.done.Done()
+
+	for batch := range work {
+		b.handle(batch)
+	}
+}
+
+// handle calls the handler with the batch, retrying with backoff on
+// error and handing the batch to the dead letter handler if all
+// retries fail.
+func (b *pushBatcher) handle(batch []interface{}) {
+	backoff := b.backoff
+	err := b.handler(batch)
+	for i := uint(0); err != nil && i < b.retries; i++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		if b.maxBackoff > 0 && backoff > b.maxBackoff {
+			backoff = b.maxBackoff
+		}
+
+		err = b.handler(batch)
+	}
+
+	if err != nil && b.deadLetter != nil {
+		b.deadLetter(batch, err)
+	}
+}
+
+// Dispose will dispose of the batcher, blocking until every completed
+// batch has been handled.
+func (b *pushBatcher) Dispose() {
+	b.close()
+	b.done.Wait()
+}
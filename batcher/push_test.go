@@ -0,0 +1,219 @@
+/*
+Copyright 2015 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package batcher
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	sync.Mutex
+	batches [][]interface{}
+}
+
+func (r *recorder) handle(batch []interface{}) error {
+	r.Lock()
+	r.batches = append(r.batches, batch)
+	r.Unlock()
+	return nil
+}
+
+func (r *recorder) items() []interface{} {
+	r.Lock()
+	defer r.Unlock()
+	var items []interface{}
+	for _, batch := range r.batches {
+		items = append(items, batch...)
+	}
+	return items
+}
+
+func TestNoHandler(t *testing.T) {
+	_, err := NewPush(nil, 0, 10, 0, 1, nil)
+	assert.Error(t, err)
+
+	_, err = NewPush(func([]interface{}) error { return nil }, 0, 0, 100, 1, nil)
+	assert.Error(t, err)
+}
+
+func TestPushMaxItems(t *testing.T) {
+	assert := assert.New(t)
+	r := &recorder{}
+	b, err := NewPush(r.handle, 0, 10, 0, 1, nil)
+	assert.Nil(err)
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(b.Put(i))
+	}
+	b.Dispose()
+
+	assert.Len(r.batches, 10)
+	for _, batch := range r.batches {
+		assert.Len(batch, 10)
+	}
+
+	expected := make([]interface{}, 100)
+	for i := range expected {
+		expected[i] = i
+	}
+	assert.Equal(expected, r.items())
+}
+
+func TestPushMaxTime(t *testing.T) {
+	assert := assert.New(t)
+	handled := make(chan []interface{}, 1)
+	b, err := NewPush(func(batch []interface{}) error {
+		handled <- batch
+		return nil
+	}, 50*time.Millisecond, 100, 0, 1, nil)
+	assert.Nil(err)
+	defer b.Dispose()
+
+	assert.Nil(b.Put("a"))
+	select {
+	case batch := <-handled:
+		assert.Equal([]interface{}{"a"}, batch)
+	case <-time.After(time.Second):
+		t.Fatal("batch was not handled")
+	}
+}
+
+func TestPushDisposeDrains(t *testing.T) {
+	assert := assert.New(t)
+	var handled uint64
+	b, err := NewPush(func(batch []interface{}) error {
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddUint64(&handled, uint64(len(batch)))
+		return nil
+	}, 0, 3, 0, 2, nil, Workers(4))
+	assert.Nil(err)
+
+	for i := 0; i < 50; i++ {
+		assert.Nil(b.Put(i))
+	}
+	b.Dispose()
+
+	// including the partial batch being built
+	assert.Equal(uint64(50), atomic.LoadUint64(&handled))
+	assert.True(b.IsDisposed())
+	assert.Equal(ErrDisposed, b.Put(1))
+	assert.Equal(ErrDisposed, b.Flush())
+	b.Dispose()
+}
+
+func TestPushBatcherOptions(t *testing.T) {
+	assert := assert.New(t)
+	o := &observer{}
+	b, err := NewPush(func(batch []interface{}) error {
+		return nil
+	}, 0, 2, 0, 1, nil, BatcherOptions(Observe(o)))
+	assert.Nil(err)
+
+	for i := 0; i < 5; i++ {
+		assert.Nil(b.Put(i))
+	}
+	b.Dispose()
+
+	o.Lock()
+	defer o.Unlock()
+	reasons := []FlushReason{}
+	for _, event := range o.events {
+		reasons = append(reasons, event.Reason)
+	}
+	assert.Equal([]FlushReason{MaxItemsReached, MaxItemsReached, Closed}, reasons)
+}
+
+func TestPushWorkersBounded(t *testing.T) {
+	assert := assert.New(t)
+	var running, max int64
+	b, err := NewPush(func(batch []interface{}) error {
+		n := atomic.AddInt64(&running, 1)
+		for {
+			m := atomic.LoadInt64(&max)
+			if n <= m || atomic.CompareAndSwapInt64(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		atomic.AddInt64(&running, -1)
+		return nil
+	}, 0, 1, 0, 10, nil, Workers(3))
+	assert.Nil(err)
+
+	for i := 0; i < 30; i++ {
+		assert.Nil(b.Put(i))
+	}
+	b.Dispose()
+
+	assert.True(atomic.LoadInt64(&max) <= 3)
+}
+
+func TestPushRetry(t *testing.T) {
+	assert := assert.New(t)
+	var calls int
+	var times []time.Time
+	b, err := NewPush(func(batch []interface{}) error {
+		calls++
+		times = append(times, time.Now())
+		if calls < 3 {
+			return errors.New("failed")
+		}
+		return nil
+	}, 0, 1, 0, 1, nil, Retry(5, 10*time.Millisecond, 15*time.Millisecond),
+		DeadLetter(func([]interface{}, error) {
+			t.Fatal("batch should not be dead lettered")
+		}))
+	assert.Nil(err)
+
+	assert.Nil(b.Put("a"))
+	b.Dispose()
+
+	assert.Equal(3, calls)
+	assert.True(times[1].Sub(times[0]) >= 10*time.Millisecond)
+	assert.True(times[2].Sub(times[1]) >= 15*time.Millisecond)
+}
+
+func TestPushDeadLetter(t *testing.T) {
+	assert := assert.New(t)
+	failure := errors.New("failed")
+	var calls int
+	var dead [][]interface{}
+	b, err := NewPush(func(batch []interface{}) error {
+		calls++
+		if batch[0] == "bad" {
+			return failure
+		}
+		return nil
+	}, 0, 1, 0, 1, nil, Retry(2, time.Millisecond, 0),
+		DeadLetter(func(batch []interface{}, err error) {
+			assert.Equal(failure, err)
+			dead = append(dead, batch)
+		}))
+	assert.Nil(err)
+
+	assert.Nil(b.Put("good"))
+	assert.Nil(b.Put("bad"))
+	b.Dispose()
+
+	assert.Equal(4, calls)
+	assert.Equal([][]interface{}{{"bad"}}, dead)
+}
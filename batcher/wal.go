@@ -0,0 +1,225 @@
+/*
+Copyright 2015 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	segmentExt = ".wal"
+	// recordHeaderSize is the size of the length and checksum preceding
+	// every record.
+	recordHeaderSize = 8
+)
+
+// Record kinds. The payload of an item record is the encoded item, the
+// payload of batch and ack records is a little endian batch ID. A batch
+// record completes the batch of all item records since the previous
+// batch record.
+const (
+	recordItem  byte = 'I'
+	recordBatch byte = 'B'
+	recordAck   byte = 'A'
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// segment is a file of the write-ahead log.
+type segment struct {
+	index uint64
+	path  string
+	// lastBatch is the highest ID of the batches with records in the
+	// segment. The segment can be removed once every batch up to and
+	// including it has been acknowledged.
+	lastBatch uint64
+}
+
+func (s *segment) holds(id uint64) {
+	if id > s.lastBatch {
+		s.lastBatch = id
+	}
+}
+
+// wal is a write-ahead log split into segment files. Records are only
+// appended to the newest segment, the active one, and segments are only
+// removed oldest first. A record is written as its length and CRC-32C
+// checksum followed by its kind and payload, so a record torn by a
+// crash is detected and ignored. wal is not threadsafe.
+type wal struct {
+	dir         string
+	segmentSize int64
+	sync        bool
+	// segments holds the segments oldest first, the last is active.
+	segments []*segment
+	file     *os.File
+	size     int64
+}
+
+func segmentPath(dir string, index uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", index, segmentExt))
+}
+
+// openWAL calls fn with every record of the segments in dir, oldest
+// first, and starts a new active segment after them. The directory is
+// created if it doesn't exist.
+func openWAL(dir string, segmentSize int64, sync bool,
+	fn func(s *segment, kind byte, payload []byte) error) (*wal, error) {
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		return nil, err
+	}
+	// segment names are zero padded, so they sort by index
+	sort.Strings(paths)
+
+	w := &wal{dir: dir, segmentSize: segmentSize, sync: sync}
+	var next uint64
+	for _, path := range paths {
+		index, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		s := &segment{index: index, path: path}
+		err = readSegment(path, func(kind byte, payload []byte) error {
+			return fn(s, kind, payload)
+		})
+		if err != nil {
+			return nil, err
+		}
+		w.segments = append(w.segments, s)
+		next = index + 1
+	}
+
+	if err := w.roll(next); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// readSegment calls fn with every record of the segment up to the first
+// torn or corrupt one.
+func readSegment(path string, fn func(kind byte, payload []byte) error) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	for len(data) >= recordHeaderSize {
+		length := binary.LittleEndian.Uint32(data)
+		sum := binary.LittleEndian.Uint32(data[4:])
+		if length == 0 || uint64(length) > uint64(len(data)-recordHeaderSize) {
+			return nil
+		}
+
+		body := data[recordHeaderSize : recordHeaderSize+length]
+		if crc32.Checksum(body, crcTable) != sum {
+			return nil
+		}
+
+		if err := fn(body[0], body[1:]); err != nil {
+			return err
+		}
+		data = data[recordHeaderSize+length:]
+	}
+
+	return nil
+}
+
+// roll closes the active segment and starts a new one with the given
+// index.
+func (w *wal) roll(index uint64) error {
+	path := segmentPath(w.dir, index)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if w.file != nil {
+		w.file.Close()
+	}
+	w.file, w.size = file, 0
+	w.segments = append(w.segments, &segment{index: index, path: path})
+	return nil
+}
+
+func (w *wal) active() *segment {
+	return w.segments[len(w.segments)-1]
+}
+
+// append writes a record to the active segment, first starting a new
+// segment if the record doesn't fit. It returns the segment the record
+// was written to. Nothing is left in the log if an error is returned.
+func (w *wal) append(kind byte, payload []byte) (*segment, error) {
+	length := int64(recordHeaderSize + 1 + len(payload))
+	if w.size > 0 && w.size+length > w.segmentSize {
+		if err := w.roll(w.active().index + 1); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := make([]byte, length)
+	binary.LittleEndian.PutUint32(buf, uint32(1+len(payload)))
+	buf[recordHeaderSize] = kind
+	copy(buf[recordHeaderSize+1:], payload)
+	binary.LittleEndian.PutUint32(buf[4:], crc32.Checksum(buf[recordHeaderSize:], crcTable))
+
+	_, err := w.file.Write(buf)
+	if err == nil && w.sync {
+		err = w.file.Sync()
+	}
+	if err != nil {
+		// Don't leave a partial record for the next one to follow.
+		w.file.Truncate(w.size)
+		w.file.Seek(w.size, 0)
+		return nil, err
+	}
+
+	w.size += length
+	return w.active(), nil
+}
+
+// truncate removes the oldest inactive segments that only hold batches
+// with IDs below lowest.
+func (w *wal) truncate(lowest uint64) error {
+	for len(w.segments) > 1 && w.segments[0].lastBatch < lowest {
+		if err := os.Remove(w.segments[0].path); err != nil {
+			return err
+		}
+		w.segments = w.segments[1:]
+	}
+
+	return nil
+}
+
+func (w *wal) close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,435 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import (
+	"container/heap"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// minBlocksPerThread is the smallest number of blocks a thread is
+// handed by the multithreaded aggregations.  Smaller ranges aren't worth
+// the cost of starting a goroutine.
+const minBlocksPerThread = 1024
+
+const (
+	// windowBlocks is the size of the window of dense blocks sparse
+	// inputs are ored into.
+	windowBlocks = 1 << 14
+	// windowDensity is the number of blocks in a range per input block
+	// below which sparse inputs are ored through a window of dense
+	// blocks rather than merged with a heap.
+	windowDensity = 4
+)
+
+// OrAll bitwise ors all of the provided bit arrays in a single pass and
+// returns a new bit array representing the result.  This is much cheaper
+// than chaining calls to Or, which allocates an intermediate result for
+// every array.  The result is a roaring bit array if any input is one,
+// otherwise it is dense if any input is dense and sparse if not.
+func OrAll(arrays ...BitArray) BitArray {
+	return aggregate(arrays, false, 1)
+}
+
+// AndAll bitwise ands all of the provided bit arrays in a single pass
+// and returns a new bit array representing the result.  The result is a
+// roaring bit array if any input is one, otherwise it is sparse if any
+// input is sparse and dense if not.
+func AndAll(arrays ...BitArray) BitArray {
+	return aggregate(arrays, true, 1)
+}
+
+// MultithreadedOrAll is OrAll with the block ranges of the result split
+// across as many threads as are available.
+func MultithreadedOrAll(arrays ...BitArray) BitArray {
+	return aggregate(arrays, false, runtime.NumCPU())
+}
+
+// MultithreadedAndAll is AndAll with the block ranges of the result
+// split across as many threads as are available.
+func MultithreadedAndAll(arrays ...BitArray) BitArray {
+	return aggregate(arrays, true, runtime.NumCPU())
+}
+
+// aggregate ands or ors the provided bit arrays, splitting the block
+// range of the result across up to numThreads goroutines.
+func aggregate(arrays []BitArray, and bool, numThreads int) BitArray {
+	if len(arrays) == 0 {
+		return newSparseBitArray()
+	}
+
+	anyDense, anySparse, anyRoaring := false, false, false
+	sources := make([]blockSource, len(arrays))
+	for i, ba := range arrays {
+		switch ba.(type) {
+		case *bitArray:
+			anyDense = true
+		case *sparseBitArray:
+			anySparse = true
+		default:
+			anyRoaring = true
+		}
+		sources[i] = toBlockSource(ba)
+	}
+
+	var dense bool
+	if and {
+		dense = !anySparse && !anyRoaring
+		// the smallest array drives the and, the others are only
+		// probed at the indices it holds
+		sort.Slice(sources, func(i, j int) bool {
+			return sources[i].numEntries() < sources[j].numEntries()
+		})
+	} else {
+		dense = anyDense && !anyRoaring
+	}
+
+	lo, hi := blockRange(sources, and)
+	if dense {
+		ba := newBitArray(hi * s)
+		if lo < hi {
+			forEachRange(lo, hi, numThreads, func(_ int, lo, hi uint64) {
+				if and {
+					andRange(sources, lo, hi, func(index uint64, b block) {
+						ba.blocks[index] = b
+					})
+				} else {
+					orDenseRange(sources, ba.blocks, lo, hi)
+				}
+			})
+		}
+		ba.setLowest()
+		ba.setHighest()
+		return ba
+	}
+
+	ranges := make([]*sparseBitArray, numThreads)
+	n := 0
+	if lo < hi {
+		n = forEachRange(lo, hi, numThreads, func(i int, lo, hi uint64) {
+			result := newSparseBitArray()
+			emit := func(index uint64, b block) {
+				result.indices = append(result.indices, index)
+				result.blocks = append(result.blocks, b)
+			}
+
+			if and {
+				andRange(sources, lo, hi, emit)
+			} else {
+				orSparseRange(sources, lo, hi, emit)
+			}
+			ranges[i] = result
+		})
+	}
+
+	sba := concatSparseBitArrays(ranges[:n])
+	if anyRoaring {
+		return newRoaringBitArrayFromIterator(sba.Blocks())
+	}
+
+	return sba
+}
+
+// blockRange returns the range of block indices [lo, hi) that may hold
+// set bits in the result.
+func blockRange(sources []blockSource, and bool) (uint64, uint64) {
+	var lo, hi uint64
+	for i, src := range sources {
+		n := src.numEntries()
+		if n == 0 {
+			if and {
+				return 0, 0
+			}
+			continue
+		}
+
+		first, _ := src.entry(0)
+		last, _ := src.entry(n - 1)
+		if _, ok := src.(*bitArray); ok && and {
+			// a dense array bounds the result by its length, not by
+			// its first block
+			first = 0
+		}
+
+		switch {
+		case i == 0 || (!and && lo == hi):
+			lo, hi = first, last+1
+		case and:
+			lo, hi = maxUint64(lo, first), minUint64(hi, last+1)
+		default:
+			lo, hi = minUint64(lo, first), maxUint64(hi, last+1)
+		}
+	}
+
+	if hi < lo {
+		hi = lo
+	}
+
+	return lo, hi
+}
+
+// forEachRange splits [lo, hi) into up to numThreads ranges and calls fn
+// with each of them concurrently.  Every call is passed the position of
+// its range among all ranges.  It returns the number of ranges.
+func forEachRange(lo, hi uint64, numThreads int, fn func(i int, lo, hi uint64)) int {
+	n := uint64(numThreads)
+	if max := (hi - lo + minBlocksPerThread - 1) / minBlocksPerThread; n > max {
+		n = max
+	}
+	if n <= 1 {
+		fn(0, lo, hi)
+		return 1
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(int(n))
+	for i := uint64(0); i < n; i++ {
+		go func(i uint64) {
+			defer wg.Done()
+			fn(int(i), lo+(hi-lo)*i/n, lo+(hi-lo)*(i+1)/n)
+		}(i)
+	}
+	wg.Wait()
+
+	return int(n)
+}
+
+// seek returns the position of the first entry of src with a block
+// index at or after index.
+func seek(src blockSource, index uint64) int {
+	if ba, ok := src.(*bitArray); ok {
+		return int(minUint64(index, uint64(len(ba.blocks))))
+	}
+
+	return sort.Search(src.numEntries(), func(i int) bool {
+		entry, _ := src.entry(i)
+		return entry >= index
+	})
+}
+
+// orDenseRange ors the blocks of every source in [lo, hi) into blocks.
+func orDenseRange(sources []blockSource, blocks []block, lo, hi uint64) {
+	for _, src := range sources {
+		if ba, ok := src.(*bitArray); ok {
+			end := minUint64(hi, uint64(len(ba.blocks)))
+			for i := lo; i < end; i++ {
+				blocks[i] |= ba.blocks[i]
+			}
+			continue
+		}
+
+		for i := seek(src, lo); i < src.numEntries(); i++ {
+			index, b := src.entry(i)
+			if index >= hi {
+				break
+			}
+			blocks[index] |= b
+		}
+	}
+}
+
+// cursor tracks the position of a k-way merge in one of its sources.
+type cursor struct {
+	src      blockSource
+	position int
+	end      int
+	index    uint64
+	block    block
+}
+
+func (c *cursor) next() bool {
+	for ; c.position < c.end; c.position++ {
+		c.index, c.block = c.src.entry(c.position)
+		if c.block != 0 {
+			c.position++
+			return true
+		}
+	}
+
+	return false
+}
+
+// cursors is a min-heap of cursors ordered by their current index.
+type cursors []*cursor
+
+func (cs cursors) Len() int           { return len(cs) }
+func (cs cursors) Less(i, j int) bool { return cs[i].index < cs[j].index }
+func (cs cursors) Swap(i, j int)      { cs[i], cs[j] = cs[j], cs[i] }
+
+func (cs *cursors) Push(x interface{}) {
+	*cs = append(*cs, x.(*cursor))
+}
+
+func (cs *cursors) Pop() interface{} {
+	old := *cs
+	c := old[len(old)-1]
+	*cs = old[:len(old)-1]
+	return c
+}
+
+// orSparseRange ors the blocks of every source in [lo, hi) and calls
+// emit with every non-empty result block in ascending index order.  If
+// the sources hold enough blocks relative to the size of the range, the
+// blocks are ored into a window of dense blocks that slides over the
+// range.  Otherwise the sources are merged with a heap.
+func orSparseRange(sources []blockSource, lo, hi uint64, emit func(uint64, block)) {
+	cs := make(cursors, 0, len(sources))
+	total := uint64(0)
+	for _, src := range sources {
+		c := &cursor{src: src, position: seek(src, lo), end: seek(src, hi)}
+		if c.position < c.end {
+			cs = append(cs, c)
+			total += uint64(c.end - c.position)
+		}
+	}
+
+	if hi-lo <= total*windowDensity {
+		orWindowed(cs, lo, hi, emit)
+		return
+	}
+
+	h := cs[:0]
+	for _, c := range cs {
+		if c.next() {
+			h = append(h, c)
+		}
+	}
+	heap.Init(&h)
+
+	for len(h) > 0 {
+		index, result := h[0].index, block(0)
+		for len(h) > 0 && h[0].index == index {
+			result |= h[0].block
+			if h[0].next() {
+				heap.Fix(&h, 0)
+			} else {
+				heap.Pop(&h)
+			}
+		}
+		emit(index, result)
+	}
+}
+
+// orWindowed ors the blocks of the cursors into a window of dense
+// blocks that slides over [lo, hi), emitting the non-empty blocks of
+// every window before moving on to the next.
+func orWindowed(cs cursors, lo, hi uint64, emit func(uint64, block)) {
+	window := make([]block, minUint64(hi-lo, windowBlocks))
+	for start := lo; start < hi; start += uint64(len(window)) {
+		end := minUint64(start+uint64(len(window)), hi)
+		for _, c := range cs {
+			for ; c.position < c.end; c.position++ {
+				index, b := c.src.entry(c.position)
+				if index >= end {
+					break
+				}
+				window[index-start] |= b
+			}
+		}
+
+		for i, b := range window[:end-start] {
+			if b != 0 {
+				emit(start+uint64(i), b)
+				window[i] = 0
+			}
+		}
+	}
+}
+
+// andRange walks the blocks of the first source in [lo, hi), ands each
+// of them with the matching blocks of the other sources and calls emit
+// with every non-empty result block in ascending index order.
+func andRange(sources []blockSource, lo, hi uint64, emit func(uint64, block)) {
+	driver := sources[0]
+	positions := make([]int, len(sources))
+	for i, src := range sources {
+		positions[i] = seek(src, lo)
+	}
+
+	for ; positions[0] < driver.numEntries(); positions[0]++ {
+		index, result := driver.entry(positions[0])
+		if index >= hi {
+			break
+		}
+
+		for i := 1; i < len(sources) && result != 0; i++ {
+			result &= probe(sources[i], &positions[i], index)
+		}
+
+		if result != 0 {
+			emit(index, result)
+		}
+	}
+}
+
+// probe returns the block of src at the provided index, advancing the
+// position past any blocks below it.  Indices must be probed in
+// ascending order.
+func probe(src blockSource, position *int, index uint64) block {
+	if ba, ok := src.(*bitArray); ok {
+		if index < uint64(len(ba.blocks)) {
+			return ba.blocks[index]
+		}
+		return 0
+	}
+
+	n := src.numEntries()
+	*position += sort.Search(n-*position, func(i int) bool {
+		entry, _ := src.entry(*position + i)
+		return entry >= index
+	})
+	if *position == n {
+		return 0
+	}
+
+	entry, b := src.entry(*position)
+	if entry != index {
+		return 0
+	}
+
+	return b
+}
+
+// concatSparseBitArrays joins sparse bit arrays holding ascending,
+// non-overlapping block ranges into one.
+func concatSparseBitArrays(arrays []*sparseBitArray) *sparseBitArray {
+	if len(arrays) == 0 {
+		return newSparseBitArray()
+	}
+
+	if len(arrays) == 1 {
+		return arrays[0]
+	}
+
+	n := 0
+	for _, sba := range arrays {
+		n += len(sba.indices)
+	}
+
+	result := &sparseBitArray{
+		indices: make(uintSlice, 0, n),
+		blocks:  make(blocks, 0, n),
+	}
+	for _, sba := range arrays {
+		result.indices = append(result.indices, sba.indices...)
+		result.blocks = append(result.blocks, sba.blocks...)
+	}
+
+	return result
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package bitarray
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// randomArrays returns numArrays bit arrays of the given kind, each with
+// numBits random bits set below capacity.
+func randomArrays(kind string, numArrays int, capacity, numBits uint64) []BitArray {
+	r := rand.New(rand.NewSource(int64(numArrays)))
+	arrays := make([]BitArray, 0, numArrays)
+	for i := 0; i < numArrays; i++ {
+		nums := make([]uint64, 0, numBits)
+		for j := uint64(0); j < numBits; j++ {
+			nums = append(nums, uint64(r.Int63n(int64(capacity))))
+		}
+		arrays = append(arrays, constructors(capacity, nums)[kind])
+	}
+
+	return arrays
+}
+
+func chain(arrays []BitArray, op func(BitArray, BitArray) BitArray) BitArray {
+	result := arrays[0]
+	for _, ba := range arrays[1:] {
+		result = op(result, ba)
+	}
+
+	return result
+}
+
+func TestOrAll(t *testing.T) {
+	arrays := []BitArray{}
+	for _, nums := range [][]uint64{{1, 64, 1000}, {2, 64, 5000}, {3, 1 << 20}} {
+		for _, ba := range constructors(s*100, nums) {
+			arrays = append(arrays, ba)
+		}
+	}
+
+	expected := []uint64{1, 2, 3, 64, 1000, 5000, 1 << 20}
+	assert.Equal(t, expected, OrAll(arrays...).ToNums())
+	assert.Equal(t, expected, MultithreadedOrAll(arrays...).ToNums())
+}
+
+func TestAndAll(t *testing.T) {
+	arrays := []BitArray{}
+	for _, nums := range [][]uint64{{1, 64, 1000, 5000}, {2, 64, 1000, 5000}, {64, 1000, 1 << 20}} {
+		for _, ba := range constructors(s*100, nums) {
+			arrays = append(arrays, ba)
+		}
+	}
+
+	expected := []uint64{64, 1000}
+	assert.Equal(t, expected, AndAll(arrays...).ToNums())
+	assert.Equal(t, expected, MultithreadedAndAll(arrays...).ToNums())
+}
+
+func TestAggregateMatchesChained(t *testing.T) {
+	for _, kind := range []string{"dense", "sparse", "roaring"} {
+		arrays := randomArrays(kind, 20, s*10000, 5000)
+
+		expected := chain(arrays, BitArray.Or)
+		assert.True(t, expected.Equals(OrAll(arrays...)), kind)
+		assert.True(t, expected.Equals(MultithreadedOrAll(arrays...)), kind)
+
+		// ands of random arrays are empty, so and dense supersets
+		superset := randomArrays(kind, 1, s*10000, 200000)[0]
+		arrays = []BitArray{superset, superset.Or(arrays[0]), superset.Or(arrays[1])}
+		expected = chain(arrays, BitArray.And)
+		assert.False(t, expected.IsEmpty(), kind)
+		assert.True(t, expected.Equals(AndAll(arrays...)), kind)
+		assert.True(t, expected.Equals(MultithreadedAndAll(arrays...)), kind)
+	}
+}
+
+func TestAggregateResultKind(t *testing.T) {
+	dense, sparse, roaring := newBitArray(s), newSparseBitArray(), newRoaringBitArray()
+
+	assert.IsType(t, dense, OrAll(dense, dense))
+	assert.IsType(t, dense, OrAll(dense, sparse))
+	assert.IsType(t, sparse, OrAll(sparse, sparse))
+	assert.IsType(t, roaring, OrAll(dense, roaring))
+
+	assert.IsType(t, dense, AndAll(dense, dense))
+	assert.IsType(t, sparse, AndAll(dense, sparse))
+	assert.IsType(t, roaring, AndAll(sparse, roaring))
+}
+
+func TestAggregateEdgeCases(t *testing.T) {
+	assert.True(t, OrAll().IsEmpty())
+	assert.True(t, AndAll().IsEmpty())
+
+	ba := newSparseBitArray()
+	ba.SetBit(100)
+	result := OrAll(ba)
+	assert.Equal(t, []uint64{100}, result.ToNums())
+	result.SetBit(5)
+	assert.Equal(t, []uint64{100}, ba.ToNums())
+
+	assert.True(t, AndAll(ba, newSparseBitArray()).IsEmpty())
+	assert.True(t, AndAll(ba, newBitArray(0)).IsEmpty())
+	assert.Equal(t, []uint64{100}, OrAll(newSparseBitArray(), ba).ToNums())
+
+	dense := newBitArray(s * 4)
+	dense.SetBit(s*3 + 1)
+	result = AndAll(dense, newBitArray(s))
+	assert.Equal(t, s, result.Capacity())
+	assert.True(t, result.IsEmpty())
+	result = OrAll(dense, newBitArray(s))
+	assert.Equal(t, s*4, result.Capacity())
+	assert.Equal(t, []uint64{s*3 + 1}, result.ToNums())
+}
+
+func BenchmarkOrAll(b *testing.B) {
+	arrays := randomArrays("dense", 1000, s*10000, 1000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		OrAll(arrays...)
+	}
+}
+
+func BenchmarkMultithreadedOrAll(b *testing.B) {
+	arrays := randomArrays("dense", 1000, s*10000, 1000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		MultithreadedOrAll(arrays...)
+	}
+}
+
+func BenchmarkChainedOr(b *testing.B) {
+	arrays := randomArrays("dense", 1000, s*10000, 1000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		chain(arrays, BitArray.Or)
+	}
+}
+
+func BenchmarkOrAllSparse(b *testing.B) {
+	arrays := randomArrays("sparse", 1000, s*10000, 1000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		OrAll(arrays...)
+	}
+}
@@ -117,3 +117,9 @@ func andDenseWithDenseBitArray(dba, other *bitArray) BitArray {
 
 	return ba
 }
+
+func andRoaringWithRoaringBitArray(rba, other *roaringBitArray) BitArray {
+	// a container missing from either side can't contribute any bits
+	// to a bitwise and, so only matching keys are kept
+	return rba.merge(other, block.and, false, false)
+}
@@ -16,7 +16,8 @@ limitations under the License.
 
 /*
 Package bitarray implements a bit array.  Useful for tracking bool type values in a space
-efficient way.  This is *NOT* a threadsafe package.
+efficient way.  With the exception of ConcurrentBitArray, this is *NOT* a threadsafe
+package.
 */
 package bitarray
 
@@ -26,6 +27,15 @@ type bitArray struct {
 	lowest  uint64
 	highest uint64
 	anyset  bool
+	// growable indicates that SetBit and SetRange grow the bit
+	// array instead of failing for positions beyond capacity.
+	growable bool
+	// immutable indicates that the bit array is shared and any
+	// modification must be refused, see concurrent.go.
+	immutable bool
+	// ranks is a lazily built rank index, see rank.go.  Any
+	// modification of the blocks must reset it to nil.
+	ranks []uint64
 }
 
 func getIndexAndRemainder(k uint64) (uint64, uint64) {
@@ -83,8 +93,16 @@ func (ba *bitArray) ToNums() []uint64 {
 
 // SetBit sets a bit at the given index to true.
 func (ba *bitArray) SetBit(k uint64) error {
+	if ba.immutable {
+		return ErrImmutable
+	}
+
 	if k >= ba.Capacity() {
-		return OutOfRangeError(k)
+		if !ba.growable {
+			return OutOfRangeError(k)
+		}
+		i, _ := getIndexAndRemainder(k)
+		ba.grow(i + 1)
 	}
 
 	if !ba.anyset {
@@ -101,6 +119,7 @@ func (ba *bitArray) SetBit(k uint64) error {
 
 	i, pos := getIndexAndRemainder(k)
 	ba.blocks[i] = ba.blocks[i].insert(pos)
+	ba.ranks = nil
 	return nil
 }
 
@@ -108,6 +127,9 @@ func (ba *bitArray) SetBit(k uint64) error {
 // index has been set.
 func (ba *bitArray) GetBit(k uint64) (bool, error) {
 	if k >= ba.Capacity() {
+		if ba.growable {
+			return false, nil
+		}
 		return false, OutOfRangeError(k)
 	}
 
@@ -116,9 +138,16 @@ func (ba *bitArray) GetBit(k uint64) (bool, error) {
 	return result, nil
 }
 
-//ClearBit will unset a bit at the given index if it is set.
+// ClearBit will unset a bit at the given index if it is set.
 func (ba *bitArray) ClearBit(k uint64) error {
+	if ba.immutable {
+		return ErrImmutable
+	}
+
 	if k >= ba.Capacity() {
+		if ba.growable {
+			return nil
+		}
 		return OutOfRangeError(k)
 	}
 
@@ -128,6 +157,7 @@ func (ba *bitArray) ClearBit(k uint64) error {
 
 	i, pos := getIndexAndRemainder(k)
 	ba.blocks[i] &^= block(1 << pos)
+	ba.ranks = nil
 
 	if k == ba.highest {
 		ba.setHighest()
@@ -144,6 +174,10 @@ func (ba *bitArray) Or(other BitArray) BitArray {
 		return orDenseWithDenseBitArray(ba, dba)
 	}
 
+	if rba, ok := other.(*roaringBitArray); ok {
+		return orRoaringWithRoaringBitArray(toRoaringBitArray(ba), rba)
+	}
+
 	return orSparseWithDenseBitArray(other.(*sparseBitArray), ba)
 }
 
@@ -154,6 +188,10 @@ func (ba *bitArray) And(other BitArray) BitArray {
 		return andDenseWithDenseBitArray(ba, dba)
 	}
 
+	if rba, ok := other.(*roaringBitArray); ok {
+		return andRoaringWithRoaringBitArray(toRoaringBitArray(ba), rba)
+	}
+
 	return andSparseWithDenseBitArray(other.(*sparseBitArray), ba)
 }
 
@@ -164,15 +202,62 @@ func (ba *bitArray) Nand(other BitArray) BitArray {
 		return nandDenseWithDenseBitArray(ba, dba)
 	}
 
+	if rba, ok := other.(*roaringBitArray); ok {
+		return nandRoaringWithRoaringBitArray(toRoaringBitArray(ba), rba)
+	}
+
 	return nandDenseWithSparseBitArray(ba, other.(*sparseBitArray))
 }
 
+// Xor will bitwise xor two bit arrays and return a new bit array
+// representing the result.
+func (ba *bitArray) Xor(other BitArray) BitArray {
+	if dba, ok := other.(*bitArray); ok {
+		return xorDenseWithDenseBitArray(ba, dba)
+	}
+
+	if rba, ok := other.(*roaringBitArray); ok {
+		return xorRoaringWithRoaringBitArray(toRoaringBitArray(ba), rba)
+	}
+
+	return xorSparseWithDenseBitArray(other.(*sparseBitArray), ba)
+}
+
+// AndNot is equivalent to Nand.
+func (ba *bitArray) AndNot(other BitArray) BitArray {
+	return ba.Nand(other)
+}
+
+// OrInPlace will bitwise or the other bit array into this bit array,
+// growing this bit array if the other is larger.
+func (ba *bitArray) OrInPlace(other BitArray) {
+	ba.mergeInPlace(toBlockSource(other), block.or)
+}
+
+// AndInPlace will bitwise and the other bit array into this bit array.
+func (ba *bitArray) AndInPlace(other BitArray) {
+	ba.mergeInPlace(toBlockSource(other), block.and)
+}
+
+// NandInPlace will bitwise nand the other bit array into this bit array.
+func (ba *bitArray) NandInPlace(other BitArray) {
+	ba.mergeInPlace(toBlockSource(other), block.nand)
+}
+
+// XorInPlace will bitwise xor the other bit array into this bit array,
+// growing this bit array if the other is larger.
+func (ba *bitArray) XorInPlace(other BitArray) {
+	ba.mergeInPlace(toBlockSource(other), block.xor)
+}
+
 // Reset clears out the bit array.
 func (ba *bitArray) Reset() {
+	ba.checkMutable()
 	for i := uint64(0); i < uint64(len(ba.blocks)); i++ {
 		ba.blocks[i] &= block(0)
 	}
 	ba.anyset = false
+	ba.ranks = nil
 }
 
 // Equals returns a bool indicating if these two bit arrays are equal.
@@ -188,6 +273,15 @@ func (ba *bitArray) Equals(other BitArray) bool {
 	var selfIndex uint64
 	for iter := other.Blocks(); iter.Next(); {
 		toIndex, otherBlock := iter.Value()
+		if toIndex >= uint64(len(ba.blocks)) {
+			// the other array extends beyond our capacity, any bits
+			// set out there can't be matched
+			if otherBlock > 0 {
+				return false
+			}
+			continue
+		}
+
 		if toIndex > selfIndex {
 			for i := selfIndex; i < toIndex; i++ {
 				if ba.blocks[i] > 0 {
@@ -203,6 +297,10 @@ func (ba *bitArray) Equals(other BitArray) bool {
 		selfIndex++
 	}
 
+	if !ba.anyset {
+		return true
+	}
+
 	lastIndex, _ := getIndexAndRemainder(ba.highest)
 	if lastIndex >= selfIndex {
 		return false
@@ -224,6 +322,10 @@ func (ba *bitArray) Intersects(other BitArray) bool {
 		return ba.intersectsSparseBitArray(sba)
 	}
 
+	if rba, ok := other.(*roaringBitArray); ok {
+		return ba.intersectsRoaringBitArray(rba)
+	}
+
 	return ba.intersectsDenseBitArray(other.(*bitArray))
 }
 
@@ -241,6 +343,7 @@ func (ba *bitArray) complement() {
 	for i := uint64(0); i < uint64(len(ba.blocks)); i++ {
 		ba.blocks[i] = ^ba.blocks[i]
 	}
+	ba.ranks = nil
 
 	ba.setLowest()
 	if ba.anyset {
@@ -258,6 +361,17 @@ func (ba *bitArray) intersectsSparseBitArray(other *sparseBitArray) bool {
 	return true
 }
 
+func (ba *bitArray) intersectsRoaringBitArray(other *roaringBitArray) bool {
+	for iter := other.Blocks(); iter.Next(); {
+		index, block := iter.Value()
+		if !ba.blocks[index].intersects(block) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (ba *bitArray) intersectsDenseBitArray(other *bitArray) bool {
 	for i, block := range other.blocks {
 		if !ba.blocks[i].intersects(block) {
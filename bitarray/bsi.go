@@ -0,0 +1,231 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import "math/bits"
+
+// BitSlicedIndex stores an unsigned integer value per column as a set of
+// bit arrays, one per bit of the values.  The i-th slice holds the
+// columns whose value has the i-th bit set.  Range predicates and
+// aggregates are then answered with a handful of bitwise operations per
+// slice instead of a pass over every column.  Columns are stored in
+// roaring bit arrays.  A BitSlicedIndex is *NOT* threadsafe.
+type BitSlicedIndex struct {
+	// exists holds the columns that have a value.
+	exists *roaringBitArray
+	// slices holds one bit array per bit of the largest value.
+	slices []*roaringBitArray
+}
+
+// SetValue sets the value of the given column, replacing any value it
+// had before.
+func (bsi *BitSlicedIndex) SetValue(column, value uint64) {
+	for n := bits.Len64(value); len(bsi.slices) < n; {
+		bsi.slices = append(bsi.slices, newRoaringBitArray())
+	}
+
+	bsi.exists.SetBit(column)
+	for i, slice := range bsi.slices {
+		if value&(1<<uint(i)) != 0 {
+			slice.SetBit(column)
+		} else {
+			slice.ClearBit(column)
+		}
+	}
+}
+
+// GetValue returns the value of the given column.  The returned bool is
+// false if the column has no value.
+func (bsi *BitSlicedIndex) GetValue(column uint64) (uint64, bool) {
+	if ok, _ := bsi.exists.GetBit(column); !ok {
+		return 0, false
+	}
+
+	var value uint64
+	for i, slice := range bsi.slices {
+		if ok, _ := slice.GetBit(column); ok {
+			value |= 1 << uint(i)
+		}
+	}
+
+	return value, true
+}
+
+// ClearValue removes the value of the given column.
+func (bsi *BitSlicedIndex) ClearValue(column uint64) {
+	bsi.exists.ClearBit(column)
+	for _, slice := range bsi.slices {
+		slice.ClearBit(column)
+	}
+}
+
+// Columns returns a bit array of the columns that have a value.
+func (bsi *BitSlicedIndex) Columns() BitArray {
+	return bsi.exists.copy()
+}
+
+// compare returns the columns whose value is less than, equal to and
+// greater than the given value.
+func (bsi *BitSlicedIndex) compare(value uint64) (lt, eq, gt *roaringBitArray) {
+	lt, gt = newRoaringBitArray(), newRoaringBitArray()
+	if bits.Len64(value) > len(bsi.slices) {
+		// the value is larger than any stored value
+		return bsi.exists.copy(), newRoaringBitArray(), gt
+	}
+
+	eq = bsi.exists.copy()
+	for i := len(bsi.slices) - 1; i >= 0 && !eq.IsEmpty(); i-- {
+		slice := bsi.slices[i]
+		if value&(1<<uint(i)) != 0 {
+			lt.OrInPlace(eq.Nand(slice))
+			eq.AndInPlace(slice)
+		} else {
+			gt.OrInPlace(eq.And(slice))
+			eq.NandInPlace(slice)
+		}
+	}
+
+	return lt, eq, gt
+}
+
+// LT returns a bit array of the columns whose value is less than the
+// given value.
+func (bsi *BitSlicedIndex) LT(value uint64) BitArray {
+	lt, _, _ := bsi.compare(value)
+	return lt
+}
+
+// LE returns a bit array of the columns whose value is less than or
+// equal to the given value.
+func (bsi *BitSlicedIndex) LE(value uint64) BitArray {
+	lt, eq, _ := bsi.compare(value)
+	lt.OrInPlace(eq)
+	return lt
+}
+
+// EQ returns a bit array of the columns whose value equals the given
+// value.
+func (bsi *BitSlicedIndex) EQ(value uint64) BitArray {
+	_, eq, _ := bsi.compare(value)
+	return eq
+}
+
+// GE returns a bit array of the columns whose value is greater than or
+// equal to the given value.
+func (bsi *BitSlicedIndex) GE(value uint64) BitArray {
+	_, eq, gt := bsi.compare(value)
+	gt.OrInPlace(eq)
+	return gt
+}
+
+// GT returns a bit array of the columns whose value is greater than the
+// given value.
+func (bsi *BitSlicedIndex) GT(value uint64) BitArray {
+	_, _, gt := bsi.compare(value)
+	return gt
+}
+
+// Between returns a bit array of the columns whose value lies in the
+// range [lo, hi], inclusive.
+func (bsi *BitSlicedIndex) Between(lo, hi uint64) BitArray {
+	if lo > hi {
+		return newRoaringBitArray()
+	}
+
+	result := bsi.GE(lo)
+	result.AndInPlace(bsi.LE(hi))
+	return result
+}
+
+// filtered returns the columns that have a value and are set in the
+// filter.  A nil filter selects every column.
+func (bsi *BitSlicedIndex) filtered(filter BitArray) *roaringBitArray {
+	if filter == nil {
+		return bsi.exists
+	}
+
+	return bsi.exists.merge(toRoaringBitArray(filter), block.and, false, false)
+}
+
+// Sum returns the sum of the values of the columns set in the filter
+// along with the number of columns summed.  A nil filter sums every
+// column.  The sum wraps around if it overflows a uint64.
+func (bsi *BitSlicedIndex) Sum(filter BitArray) (uint64, uint64) {
+	columns := bsi.filtered(filter)
+	var sum uint64
+	for i, slice := range bsi.slices {
+		count := columns.merge(slice, block.and, false, false).Count()
+		sum += count << uint(i)
+	}
+
+	return sum, columns.Count()
+}
+
+// Min returns the smallest value of the columns set in the filter.  A
+// nil filter considers every column.  The returned bool is false if no
+// column set in the filter has a value.
+func (bsi *BitSlicedIndex) Min(filter BitArray) (uint64, bool) {
+	return bsi.extreme(filter, false)
+}
+
+// Max returns the largest value of the columns set in the filter.  A
+// nil filter considers every column.  The returned bool is false if no
+// column set in the filter has a value.
+func (bsi *BitSlicedIndex) Max(filter BitArray) (uint64, bool) {
+	return bsi.extreme(filter, true)
+}
+
+// extreme narrows the candidate columns slice by slice, starting at the
+// most significant, to those with the bit set if max or unset if not.
+// A slice that would leave no candidates fixes that bit of the result.
+func (bsi *BitSlicedIndex) extreme(filter BitArray, max bool) (uint64, bool) {
+	candidates := bsi.filtered(filter)
+	if candidates.IsEmpty() {
+		return 0, false
+	}
+
+	var value uint64
+	for i := len(bsi.slices) - 1; i >= 0; i-- {
+		var narrowed *roaringBitArray
+		if max {
+			narrowed = candidates.merge(bsi.slices[i], block.and, false, false)
+		} else {
+			narrowed = candidates.merge(bsi.slices[i], block.nand, true, false)
+		}
+
+		if narrowed.IsEmpty() {
+			if !max {
+				value |= 1 << uint(i)
+			}
+			continue
+		}
+
+		if max {
+			value |= 1 << uint(i)
+		}
+		candidates = narrowed
+	}
+
+	return value, true
+}
+
+// NewBitSlicedIndex returns an empty BitSlicedIndex.
+func NewBitSlicedIndex() *BitSlicedIndex {
+	return &BitSlicedIndex{
+		exists: newRoaringBitArray(),
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package bitarray
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitSlicedIndexSetGet(t *testing.T) {
+	bsi := NewBitSlicedIndex()
+	bsi.SetValue(1, 5)
+	bsi.SetValue(100000, 1<<40)
+	bsi.SetValue(7, 0)
+
+	value, ok := bsi.GetValue(1)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(5), value)
+
+	value, ok = bsi.GetValue(100000)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1<<40), value)
+
+	value, ok = bsi.GetValue(7)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(0), value)
+
+	_, ok = bsi.GetValue(2)
+	assert.False(t, ok)
+
+	bsi.SetValue(1, 2)
+	value, _ = bsi.GetValue(1)
+	assert.Equal(t, uint64(2), value)
+
+	bsi.ClearValue(1)
+	_, ok = bsi.GetValue(1)
+	assert.False(t, ok)
+	assert.Equal(t, []uint64{7, 100000}, bsi.Columns().ToNums())
+}
+
+func TestBitSlicedIndexPredicates(t *testing.T) {
+	bsi := NewBitSlicedIndex()
+	values := map[uint64]uint64{}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		column, value := uint64(r.Intn(100000)), uint64(r.Intn(1000))
+		bsi.SetValue(column, value)
+		values[column] = value
+	}
+
+	expect := func(fn func(uint64) bool) []uint64 {
+		result := newRoaringBitArray()
+		for column, value := range values {
+			if fn(value) {
+				result.SetBit(column)
+			}
+		}
+		return result.ToNums()
+	}
+
+	for _, v := range []uint64{0, 1, 255, 256, 500, 999, 1000, 1 << 20} {
+		assert.Equal(t, expect(func(x uint64) bool { return x < v }), bsi.LT(v).ToNums(), "LT %d", v)
+		assert.Equal(t, expect(func(x uint64) bool { return x <= v }), bsi.LE(v).ToNums(), "LE %d", v)
+		assert.Equal(t, expect(func(x uint64) bool { return x == v }), bsi.EQ(v).ToNums(), "EQ %d", v)
+		assert.Equal(t, expect(func(x uint64) bool { return x >= v }), bsi.GE(v).ToNums(), "GE %d", v)
+		assert.Equal(t, expect(func(x uint64) bool { return x > v }), bsi.GT(v).ToNums(), "GT %d", v)
+	}
+
+	assert.Equal(t, expect(func(x uint64) bool { return x >= 100 && x <= 200 }), bsi.Between(100, 200).ToNums())
+	assert.True(t, bsi.Between(200, 100).IsEmpty())
+}
+
+func TestBitSlicedIndexAggregates(t *testing.T) {
+	bsi := NewBitSlicedIndex()
+	for column := uint64(0); column < 100; column++ {
+		bsi.SetValue(column*3, column+10)
+	}
+
+	sum, count := bsi.Sum(nil)
+	assert.Equal(t, uint64(100), count)
+	assert.Equal(t, uint64(10*100+99*100/2), sum)
+
+	min, ok := bsi.Min(nil)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(10), min)
+
+	max, ok := bsi.Max(nil)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(109), max)
+
+	filter := newBitArray(1000)
+	filter.SetBit(30) // value 20
+	filter.SetBit(31) // no value
+	filter.SetBit(60) // value 30
+	filter.SetBit(45) // value 25
+
+	sum, count = bsi.Sum(filter)
+	assert.Equal(t, uint64(3), count)
+	assert.Equal(t, uint64(75), sum)
+
+	min, ok = bsi.Min(filter)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(20), min)
+
+	max, ok = bsi.Max(filter)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(30), max)
+
+	// predicates compose with aggregates
+	sum, count = bsi.Sum(bsi.GT(100))
+	assert.Equal(t, uint64(9), count)
+	assert.Equal(t, uint64(101+109)*9/2, sum)
+
+	empty := newSparseBitArray()
+	empty.SetBit(1)
+	_, ok = bsi.Min(empty)
+	assert.False(t, ok)
+	_, ok = bsi.Max(empty)
+	assert.False(t, ok)
+	sum, count = bsi.Sum(empty)
+	assert.Equal(t, uint64(0), sum)
+	assert.Equal(t, uint64(0), count)
+}
+
+func BenchmarkBitSlicedIndexBetween(b *testing.B) {
+	bsi := NewBitSlicedIndex()
+	r := rand.New(rand.NewSource(1))
+	for column := uint64(0); column < 100000; column++ {
+		bsi.SetValue(column, uint64(r.Intn(1<<16)))
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		bsi.Between(1<<10, 1<<14)
+	}
+}
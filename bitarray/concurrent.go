@@ -0,0 +1,181 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// ConcurrentBitArray is a fixed size dense bit array that is safe for
+// concurrent use without locks.  Every block is modified with an atomic
+// compare and swap, so writers of different bits never block each other.
+type ConcurrentBitArray interface {
+	// SetBit sets the bit at the given position.  This function
+	// returns an error if the position is out of range.
+	SetBit(k uint64) error
+	// GetBit gets the bit at the given position.  This function
+	// returns an error if the position is out of range.
+	GetBit(k uint64) (bool, error)
+	// ClearBit clears the bit at the given position.  This function
+	// returns an error if the position is out of range.
+	ClearBit(k uint64) error
+	// TestAndSet sets the bit at the given position and returns a
+	// bool indicating if it was already set.  Of many goroutines
+	// setting the same bit, exactly one sees false.
+	TestAndSet(k uint64) (bool, error)
+	// TestAndClear clears the bit at the given position and returns
+	// a bool indicating if it was set.  Of many goroutines clearing
+	// the same bit, exactly one sees true.
+	TestAndClear(k uint64) (bool, error)
+	// Capacity returns the number of bits in the bit array.
+	Capacity() uint64
+	// Snapshot returns an immutable copy of the bit array that can be
+	// used in Or, And and other queries while writers continue.  Every
+	// block is read atomically, but bits changed while the snapshot is
+	// taken may or may not be part of it.  Snapshots are shared by all
+	// callers until the bit array is next modified, so taking them is
+	// cheap for read-mostly workloads.  Modifying a snapshot returns
+	// or panics with ErrImmutable.
+	Snapshot() BitArray
+}
+
+type snapshot struct {
+	version uint64
+	ba      *bitArray
+}
+
+type concurrentBitArray struct {
+	// version is incremented by every modification of the blocks so
+	// that a stale snapshot can be detected.  It is the first field
+	// to guarantee 64 bit alignment for atomic operations.
+	version uint64
+	blocks  []uint64
+	// snapshot is the last snapshot taken, a *snapshot.
+	snapshot unsafe.Pointer
+}
+
+// modify atomically replaces the block holding k with the result of op
+// and returns the block as it was before.
+func (cba *concurrentBitArray) modify(k uint64, op func(block, block) block) (block, error) {
+	if k >= cba.Capacity() {
+		return 0, OutOfRangeError(k)
+	}
+
+	i, pos := getIndexAndRemainder(k)
+	mask := block(1 << pos)
+	for {
+		old := atomic.LoadUint64(&cba.blocks[i])
+		updated := uint64(op(block(old), mask))
+		if updated == old {
+			return block(old), nil
+		}
+
+		if atomic.CompareAndSwapUint64(&cba.blocks[i], old, updated) {
+			atomic.AddUint64(&cba.version, 1)
+			return block(old), nil
+		}
+	}
+}
+
+// SetBit sets the bit at the given position.
+func (cba *concurrentBitArray) SetBit(k uint64) error {
+	_, err := cba.modify(k, block.or)
+	return err
+}
+
+// GetBit gets the bit at the given position.
+func (cba *concurrentBitArray) GetBit(k uint64) (bool, error) {
+	if k >= cba.Capacity() {
+		return false, OutOfRangeError(k)
+	}
+
+	i, pos := getIndexAndRemainder(k)
+	return block(atomic.LoadUint64(&cba.blocks[i]))&block(1<<pos) != 0, nil
+}
+
+// ClearBit clears the bit at the given position.
+func (cba *concurrentBitArray) ClearBit(k uint64) error {
+	_, err := cba.modify(k, block.nand)
+	return err
+}
+
+// TestAndSet sets the bit at the given position and returns a bool
+// indicating if it was already set.
+func (cba *concurrentBitArray) TestAndSet(k uint64) (bool, error) {
+	old, err := cba.modify(k, block.or)
+	_, pos := getIndexAndRemainder(k)
+	return old&block(1<<pos) != 0, err
+}
+
+// TestAndClear clears the bit at the given position and returns a bool
+// indicating if it was set.
+func (cba *concurrentBitArray) TestAndClear(k uint64) (bool, error) {
+	old, err := cba.modify(k, block.nand)
+	_, pos := getIndexAndRemainder(k)
+	return old&block(1<<pos) != 0, err
+}
+
+// Capacity returns the number of bits in the bit array.
+func (cba *concurrentBitArray) Capacity() uint64 {
+	return uint64(len(cba.blocks)) * s
+}
+
+// Snapshot returns an immutable copy of the bit array.
+func (cba *concurrentBitArray) Snapshot() BitArray {
+	version := atomic.LoadUint64(&cba.version)
+	if last := (*snapshot)(atomic.LoadPointer(&cba.snapshot)); last != nil && last.version == version {
+		return last.ba
+	}
+
+	ba := newBitArray(cba.Capacity())
+	for i := range cba.blocks {
+		ba.blocks[i] = block(atomic.LoadUint64(&cba.blocks[i]))
+	}
+	ba.setLowest()
+	ba.setHighest()
+	// build the rank index up front, the snapshot is read concurrently
+	// and must never modify itself
+	ba.rankIndex()
+	ba.immutable = true
+
+	// the snapshot is tagged with the version read before copying, any
+	// modification made while copying makes it stale immediately
+	atomic.StorePointer(&cba.snapshot, unsafe.Pointer(&snapshot{version: version, ba: ba}))
+	return ba
+}
+
+// checkMutable panics with ErrImmutable if this bit array is immutable.
+// It guards modifications that have no way of returning an error.
+func (ba *bitArray) checkMutable() {
+	if ba.immutable {
+		panic(ErrImmutable)
+	}
+}
+
+// NewConcurrentBitArray returns a new ConcurrentBitArray with room for
+// size bits.
+func NewConcurrentBitArray(size uint64) ConcurrentBitArray {
+	i, r := getIndexAndRemainder(size)
+	if r > 0 {
+		i++
+	}
+
+	return &concurrentBitArray{
+		blocks: make([]uint64, i),
+	}
+}
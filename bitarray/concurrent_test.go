@@ -0,0 +1,195 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package bitarray
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentSetGetClear(t *testing.T) {
+	cba := NewConcurrentBitArray(s * 2)
+	assert.Equal(t, s*2, cba.Capacity())
+
+	assert.Nil(t, cba.SetBit(5))
+	assert.Nil(t, cba.SetBit(s+1))
+
+	result, err := cba.GetBit(5)
+	assert.Nil(t, err)
+	assert.True(t, result)
+	result, err = cba.GetBit(6)
+	assert.Nil(t, err)
+	assert.False(t, result)
+
+	assert.Nil(t, cba.ClearBit(5))
+	result, _ = cba.GetBit(5)
+	assert.False(t, result)
+
+	assert.Equal(t, OutOfRangeError(s*2), cba.SetBit(s*2))
+	assert.Equal(t, OutOfRangeError(s*2), cba.ClearBit(s*2))
+	_, err = cba.GetBit(s * 2)
+	assert.Equal(t, OutOfRangeError(s*2), err)
+	_, err = cba.TestAndSet(s * 2)
+	assert.Equal(t, OutOfRangeError(s*2), err)
+}
+
+func TestConcurrentTestAndSet(t *testing.T) {
+	cba := NewConcurrentBitArray(s)
+
+	result, err := cba.TestAndSet(3)
+	assert.Nil(t, err)
+	assert.False(t, result)
+	result, err = cba.TestAndSet(3)
+	assert.Nil(t, err)
+	assert.True(t, result)
+
+	result, err = cba.TestAndClear(3)
+	assert.Nil(t, err)
+	assert.True(t, result)
+	result, err = cba.TestAndClear(3)
+	assert.Nil(t, err)
+	assert.False(t, result)
+}
+
+func TestConcurrentTestAndSetSingleWinner(t *testing.T) {
+	numBits, numRoutines := uint64(1000), 8
+	cba := NewConcurrentBitArray(numBits)
+
+	var wins uint64
+	var wg sync.WaitGroup
+	wg.Add(numRoutines)
+	for i := 0; i < numRoutines; i++ {
+		go func() {
+			defer wg.Done()
+			for k := uint64(0); k < numBits; k++ {
+				if set, _ := cba.TestAndSet(k); !set {
+					atomic.AddUint64(&wins, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, numBits, wins)
+	assert.Equal(t, numBits, cba.Snapshot().Count())
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	numBits, numRoutines := uint64(s*100), 8
+	cba := NewConcurrentBitArray(numBits)
+
+	var wg sync.WaitGroup
+	wg.Add(numRoutines + 1)
+	for i := 0; i < numRoutines; i++ {
+		go func(i uint64) {
+			defer wg.Done()
+			// every routine owns every numRoutines-th bit, so all
+			// routines hammer the same blocks
+			for k := i; k < numBits; k += uint64(numRoutines) {
+				cba.SetBit(k)
+			}
+			for k := i; k < numBits; k += uint64(numRoutines) * 2 {
+				cba.ClearBit(k)
+			}
+		}(uint64(i))
+	}
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			snapshot := cba.Snapshot()
+			snapshot.Count()
+			snapshot.Rank(numBits / 2)
+		}
+	}()
+	wg.Wait()
+
+	snapshot := cba.Snapshot()
+	assert.Equal(t, numBits/2, snapshot.Count())
+	for k := uint64(0); k < numBits; k++ {
+		result, _ := snapshot.GetBit(k)
+		assert.Equal(t, k%uint64(numRoutines*2) >= uint64(numRoutines), result)
+	}
+}
+
+func TestConcurrentSnapshot(t *testing.T) {
+	cba := NewConcurrentBitArray(s * 4)
+	cba.SetBit(1)
+	cba.SetBit(s * 3)
+
+	snapshot := cba.Snapshot()
+	assert.Equal(t, []uint64{1, s * 3}, snapshot.ToNums())
+	assert.True(t, snapshot == cba.Snapshot())
+
+	// setting a bit that is already set changes nothing
+	cba.SetBit(1)
+	assert.True(t, snapshot == cba.Snapshot())
+
+	cba.SetBit(2)
+	assert.Equal(t, []uint64{1, s * 3}, snapshot.ToNums())
+	assert.Equal(t, []uint64{1, 2, s * 3}, cba.Snapshot().ToNums())
+	assert.False(t, snapshot == cba.Snapshot())
+}
+
+func TestConcurrentSnapshotQueries(t *testing.T) {
+	cba := NewConcurrentBitArray(s * 4)
+	cba.SetBit(1)
+	cba.SetBit(s * 3)
+	snapshot := cba.Snapshot()
+
+	other := newSparseBitArray()
+	other.SetBit(1)
+	other.SetBit(s)
+
+	assert.Equal(t, []uint64{1, s, s * 3}, snapshot.Or(other).ToNums())
+	assert.Equal(t, []uint64{1}, snapshot.And(other).ToNums())
+	assert.Equal(t, []uint64{1, s, s * 3}, other.Or(snapshot).ToNums())
+	assert.Equal(t, []uint64{1, s * 3}, OrAll(snapshot, snapshot).ToNums())
+
+	// results of queries are regular, mutable bit arrays
+	result := snapshot.Or(other)
+	assert.Nil(t, result.SetBit(5))
+}
+
+func TestConcurrentSnapshotImmutable(t *testing.T) {
+	cba := NewConcurrentBitArray(s)
+	cba.SetBit(1)
+	snapshot := cba.Snapshot()
+
+	assert.Equal(t, ErrImmutable, snapshot.SetBit(2))
+	assert.Equal(t, ErrImmutable, snapshot.ClearBit(1))
+	assert.Equal(t, ErrImmutable, snapshot.SetRange(0, 10))
+	assert.Equal(t, ErrImmutable, snapshot.ClearRange(0, 10))
+	assert.Panics(t, func() { snapshot.Reset() })
+	assert.Panics(t, func() { snapshot.OrInPlace(newSparseBitArray()) })
+	assert.Panics(t, func() { snapshot.AndInPlace(newSparseBitArray()) })
+	assert.Equal(t, []uint64{1}, snapshot.ToNums())
+}
+
+func BenchmarkConcurrentSetBit(b *testing.B) {
+	cba := NewConcurrentBitArray(s * 1000)
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		k := uint64(0)
+		for pb.Next() {
+			cba.SetBit(k % (s * 1000))
+			k += 7
+		}
+	})
+}
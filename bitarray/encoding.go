@@ -30,17 +30,29 @@ func Marshal(ba BitArray) ([]byte, error) {
 		return eba.Serialize()
 	} else if sba, ok := ba.(*sparseBitArray); ok {
 		return sba.Serialize()
+	} else if rba, ok := ba.(*roaringBitArray); ok {
+		// roaring bit arrays postdate the original format and are
+		// always written in the native streaming format
+		w := new(bytes.Buffer)
+		if err := encodeNative(w, rba); err != nil {
+			return nil, err
+		}
+		return w.Bytes(), nil
 	} else {
 		return nil, errors.New("not a valid BitArray")
 	}
 }
 
-// Unmarshal takes a byte slice, of the same format produced by Marshal,
-// and returns a BitArray.
+// Unmarshal takes a byte slice, of the same format produced by Marshal
+// or an Encoder, and returns a BitArray.
 func Unmarshal(input []byte) (BitArray, error) {
 	if len(input) == 0 {
 		return nil, errors.New("no data in input")
 	}
+
+	if input[0] != 'B' && input[0] != 'S' {
+		return NewDecoder(bytes.NewReader(input)).Decode()
+	}
 	if input[0] == 'B' {
 		ret := newBitArray(0)
 		err := ret.Deserialize(input)
@@ -198,6 +210,10 @@ func (ba *bitArray) Serialize() ([]byte, error) {
 // specified when creating the bitArray. Also note that if an error is returned,
 // the bitArray this is called on might be populated with partial data.
 func (ret *bitArray) Deserialize(incoming []byte) error {
+	if ret.immutable {
+		return ErrImmutable
+	}
+
 	r := bytes.NewReader(incoming[1:]) // Discard identifier
 
 	err := binary.Read(r, binary.LittleEndian, &ret.lowest)
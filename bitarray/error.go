@@ -16,7 +16,15 @@ limitations under the License.
 
 package bitarray
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrImmutable is returned when trying to modify an immutable bit array,
+// such as a snapshot of a ConcurrentBitArray.  Modifications that can't
+// return an error panic with it instead.
+var ErrImmutable = errors.New("bitarray: bit array is immutable")
 
 // OutOfRangeError is an error caused by trying to access a bitarray past the end of its
 // capacity.
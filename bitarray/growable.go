@@ -0,0 +1,60 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+// Shrink releases the trailing blocks of this bit array that have no
+// bits set, keeping at least one block.  The backing slice is reallocated so the memory held by the
+// released blocks can be reclaimed.
+func (ba *bitArray) Shrink() {
+	ba.checkMutable()
+	n := uint64(0)
+	if ba.anyset {
+		i, _ := getIndexAndRemainder(ba.highest)
+		n = i + 1
+	}
+	if n == 0 {
+		// keep a block so iterating the empty array still has one to yield
+		n = 1
+	}
+
+	if n == uint64(len(ba.blocks)) && n == uint64(cap(ba.blocks)) {
+		return
+	}
+
+	blocks := make([]block, n)
+	copy(blocks, ba.blocks)
+	ba.blocks = blocks
+	ba.ranks = nil
+}
+
+// newGrowableBitArray returns a new growable dense bit array with an
+// initial capacity of at least size.
+func newGrowableBitArray(size uint64) *bitArray {
+	ba := newBitArray(size)
+	ba.growable = true
+	return ba
+}
+
+// NewGrowableBitArray returns a dense bit array that starts out with
+// room for size bits and grows to fit any position that is set, so
+// SetBit and SetRange never return an OutOfRangeError.  Getting or
+// clearing positions beyond the capacity is not an error either.  The
+// results of operations that return a new bit array, like Or and And,
+// are regular fixed size bit arrays.
+func NewGrowableBitArray(size uint64) GrowableBitArray {
+	return newGrowableBitArray(size)
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrowableSetBit(t *testing.T) {
+	ba := newGrowableBitArray(0)
+	assert.Equal(t, uint64(0), ba.Capacity())
+
+	assert.Nil(t, ba.SetBit(5))
+	assert.Equal(t, s, ba.Capacity())
+
+	assert.Nil(t, ba.SetBit(s*10+3))
+	assert.Equal(t, s*11, ba.Capacity())
+	assert.Equal(t, []uint64{5, s*10 + 3}, ba.ToNums())
+
+	result, err := ba.GetBit(s * 100)
+	assert.Nil(t, err)
+	assert.False(t, result)
+	assert.Nil(t, ba.ClearBit(s*100))
+	assert.Equal(t, s*11, ba.Capacity())
+
+	checkBit(t, ba, 5, true)
+	checkBit(t, ba, s*10+3, true)
+	checkBit(t, ba, s*10+4, false)
+}
+
+func TestGrowableSetRange(t *testing.T) {
+	ba := newGrowableBitArray(s)
+
+	assert.Nil(t, ba.SetRange(s-2, s*3))
+	assert.Equal(t, s*3, ba.Capacity())
+	assert.Equal(t, s*2+2, ba.Count())
+
+	assert.Nil(t, ba.ClearRange(s*2, s*10))
+	assert.Equal(t, s+2, ba.Count())
+	assert.Equal(t, s*3, ba.Capacity())
+}
+
+func TestGrowableShrink(t *testing.T) {
+	ba := newGrowableBitArray(s * 100)
+	ba.SetBit(3)
+	ba.SetBit(s*50 + 1)
+
+	ba.Shrink()
+	assert.Equal(t, s*51, ba.Capacity())
+	assert.Equal(t, len(ba.blocks), cap(ba.blocks))
+
+	ba.ClearBit(s*50 + 1)
+	ba.Shrink()
+	assert.Equal(t, s, ba.Capacity())
+	assert.Equal(t, []uint64{3}, ba.ToNums())
+
+	ba.Reset()
+	ba.Shrink()
+	assert.Equal(t, s, ba.Capacity())
+	assert.True(t, ba.IsEmpty())
+
+	assert.Nil(t, ba.SetBit(s*2))
+	assert.Equal(t, []uint64{s * 2}, ba.ToNums())
+}
+
+func TestGrowableShrinkEmptyCombined(t *testing.T) {
+	others := constructors(s*2, []uint64{3, s + 1})
+	others["growable"] = newGrowableBitArray(s * 2)
+	others["growable"].SetBit(3)
+	others["growable"].SetBit(s + 1)
+
+	for name, other := range others {
+		ba := newGrowableBitArray(s * 4)
+		ba.Shrink()
+
+		assert.True(t, ba.Or(other).Equals(other), name)
+		assert.True(t, other.Or(ba).Equals(other), name)
+		assert.Empty(t, ba.And(other).ToNums(), name)
+		assert.Empty(t, other.And(ba).ToNums(), name)
+		assert.Empty(t, ba.Nand(other).ToNums(), name)
+		assert.True(t, other.Nand(ba).Equals(other), name)
+		assert.True(t, ba.Xor(other).Equals(other), name)
+		assert.True(t, other.Xor(ba).Equals(other), name)
+		assert.False(t, ba.Equals(other), name)
+		assert.False(t, other.Equals(ba), name)
+		assert.True(t, other.Intersects(ba), name)
+
+		result := other.Or(ba)
+		result.OrInPlace(ba)
+		result.XorInPlace(ba)
+		result.NandInPlace(ba)
+		assert.True(t, result.Equals(other), name)
+		result.AndInPlace(ba)
+		assert.Empty(t, result.ToNums(), name)
+
+		ba.OrInPlace(other)
+		assert.Equal(t, []uint64{3, s + 1}, ba.ToNums(), name)
+	}
+}
+
+func TestGrowableEquals(t *testing.T) {
+	ba := newGrowableBitArray(0)
+	other := newBitArray(s * 10)
+	assert.True(t, ba.Equals(other))
+	assert.True(t, other.Equals(ba))
+
+	ba.SetBit(s * 5)
+	other.SetBit(s * 5)
+	assert.True(t, ba.Equals(other))
+	assert.True(t, other.Equals(ba))
+
+	ba.SetBit(s * 20)
+	assert.False(t, ba.Equals(other))
+	assert.False(t, other.Equals(ba))
+
+	ba.ClearBit(s * 20)
+	ba.Shrink()
+	assert.True(t, ba.Equals(other))
+	assert.True(t, other.Equals(ba))
+}
+
+func TestFixedSizeBitArrayDoesNotGrow(t *testing.T) {
+	ba := newBitArray(s)
+	assert.Equal(t, OutOfRangeError(s), ba.SetBit(s))
+	assert.Equal(t, OutOfRangeError(s*2-1), ba.SetRange(0, s*2))
+	assert.Equal(t, s, ba.Capacity())
+}
+
+func BenchmarkGrowableSetBit(b *testing.B) {
+	numItems := uint64(1000000)
+
+	for i := 0; i < b.N; i++ {
+		ba := newGrowableBitArray(0)
+		for j := uint64(0); j < numItems; j += 7 {
+			ba.SetBit(j)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+// blockSource gives random access to the blocks of a bit array in
+// ascending index order.  Entries may hold empty blocks.
+type blockSource interface {
+	numEntries() int
+	entry(i int) (uint64, block)
+}
+
+func (ba *bitArray) numEntries() int {
+	return len(ba.blocks)
+}
+
+func (ba *bitArray) entry(i int) (uint64, block) {
+	return uint64(i), ba.blocks[i]
+}
+
+func (sba *sparseBitArray) numEntries() int {
+	return len(sba.indices)
+}
+
+func (sba *sparseBitArray) entry(i int) (uint64, block) {
+	return sba.indices[i], sba.blocks[i]
+}
+
+// toBlockSource returns the provided bit array as a blockSource,
+// converting it to a sparse bit array if necessary.
+func toBlockSource(ba BitArray) blockSource {
+	switch ba := ba.(type) {
+	case *bitArray:
+		return ba
+	case *sparseBitArray:
+		return ba
+	}
+
+	sba := newSparseBitArray()
+	for iter := ba.Blocks(); iter.Next(); {
+		index, b := iter.Value()
+		if b == 0 {
+			continue
+		}
+		sba.indices = append(sba.indices, index)
+		sba.blocks = append(sba.blocks, b)
+	}
+
+	return sba
+}
+
+// isIdentityWithEmpty returns a bool indicating if applying op to a
+// block and an empty block returns the original block.  When it does,
+// blocks that only exist in the receiver can be left untouched.
+func isIdentityWithEmpty(op func(block, block) block) bool {
+	return op(maximumBlock, 0) == maximumBlock
+}
+
+// mergeInPlace applies op to every block of this dense bit array and the
+// matching block of other, storing the result in this bit array.  The
+// bit array grows if other has blocks beyond our capacity that op
+// would keep.
+func (ba *bitArray) mergeInPlace(other blockSource, op func(block, block) block) {
+	ba.checkMutable()
+	n := other.numEntries()
+	for j := n - 1; j >= 0; j-- {
+		index, b := other.entry(j)
+		if index < uint64(len(ba.blocks)) {
+			break
+		}
+
+		if op(0, b) != 0 {
+			ba.grow(index + 1)
+			break
+		}
+	}
+
+	if isIdentityWithEmpty(op) {
+		for j := 0; j < n; j++ {
+			index, b := other.entry(j)
+			if index >= uint64(len(ba.blocks)) {
+				break
+			}
+			ba.blocks[index] = op(ba.blocks[index], b)
+		}
+	} else {
+		j := 0
+		for i := range ba.blocks {
+			var b block
+			for ; j < n; j++ {
+				index, otherBlock := other.entry(j)
+				if index >= uint64(i) {
+					if index == uint64(i) {
+						b = otherBlock
+					}
+					break
+				}
+			}
+			ba.blocks[i] = op(ba.blocks[i], b)
+		}
+	}
+
+	ba.ranks = nil
+	ba.setLowest()
+	ba.setHighest()
+}
+
+// grow extends this bit array so that it holds at least n blocks.
+func (ba *bitArray) grow(n uint64) {
+	if n <= uint64(len(ba.blocks)) {
+		return
+	}
+
+	if n <= uint64(cap(ba.blocks)) {
+		ba.blocks = ba.blocks[:n]
+		return
+	}
+
+	blocks := make([]block, n, maxUint64(n, uint64(cap(ba.blocks))*2))
+	copy(blocks, ba.blocks)
+	ba.blocks = blocks
+}
+
+// mergeInPlace applies op to every block of this sparse bit array and
+// the matching block of other, storing the result in this bit array.
+// Blocks that only exist in other are treated as combined with an empty
+// block and are inserted if the result is not empty.  New entries are
+// merged in from the back so the existing slices are only reallocated
+// if they lack the capacity for the result.
+func (sba *sparseBitArray) mergeInPlace(other blockSource, op func(block, block) block) {
+	n := other.numEntries()
+
+	// count the entries missing from this array that will be inserted
+	extra := 0
+	i := 0
+	for j := 0; j < n; j++ {
+		index, b := other.entry(j)
+		for i < len(sba.indices) && sba.indices[i] < index {
+			i++
+		}
+		if i < len(sba.indices) && sba.indices[i] == index {
+			continue
+		}
+		if op(0, b) != 0 {
+			extra++
+		}
+	}
+
+	// grow both slices at once rather than an entry at a time, as a
+	// large range inserts millions of entries
+	selfIndex := len(sba.indices) - 1
+	sba.indices = append(sba.indices, make(uintSlice, extra)...)
+	sba.blocks = append(sba.blocks, make(blocks, extra)...)
+
+	identity := isIdentityWithEmpty(op)
+	w := len(sba.indices) - 1
+	for j := n - 1; j >= 0 || selfIndex >= 0; {
+		var otherIndex uint64
+		var otherBlock block
+		if j >= 0 {
+			otherIndex, otherBlock = other.entry(j)
+		}
+
+		switch {
+		case j < 0 || (selfIndex >= 0 && sba.indices[selfIndex] > otherIndex):
+			if identity && j < 0 && w == selfIndex {
+				// nothing remains to be inserted below this point
+				selfIndex = -1
+				continue
+			}
+			sba.indices[w] = sba.indices[selfIndex]
+			sba.blocks[w] = op(sba.blocks[selfIndex], 0)
+			selfIndex--
+			w--
+		case selfIndex < 0 || sba.indices[selfIndex] < otherIndex:
+			if result := op(0, otherBlock); result != 0 {
+				sba.indices[w] = otherIndex
+				sba.blocks[w] = result
+				w--
+			}
+			j--
+		default:
+			sba.indices[w] = otherIndex
+			sba.blocks[w] = op(sba.blocks[selfIndex], otherBlock)
+			selfIndex--
+			j--
+			w--
+		}
+	}
+
+	sba.compact()
+}
+
+// compact removes any empty blocks left behind by an operation.
+func (sba *sparseBitArray) compact() {
+	w := 0
+	for i := range sba.indices {
+		if sba.blocks[i] == 0 {
+			continue
+		}
+		sba.indices[w] = sba.indices[i]
+		sba.blocks[w] = sba.blocks[i]
+		w++
+	}
+
+	for i := w; i < len(sba.indices); i++ {
+		sba.indices[i] = 0
+		sba.blocks[i] = 0
+	}
+	sba.indices = sba.indices[:w]
+	sba.blocks = sba.blocks[:w]
+}
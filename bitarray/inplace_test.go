@@ -0,0 +1,174 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// constructors builds a bit array of every implementation holding the
+// provided numbers.
+func constructors(capacity uint64, nums []uint64) map[string]BitArray {
+	arrays := map[string]BitArray{
+		"dense":   newBitArray(capacity),
+		"sparse":  newSparseBitArray(),
+		"roaring": newRoaringBitArray(),
+	}
+
+	for _, ba := range arrays {
+		for _, num := range nums {
+			ba.SetBit(num)
+		}
+	}
+
+	return arrays
+}
+
+func TestInPlaceOperations(t *testing.T) {
+	self := []uint64{1, 64, 65, 300, 1000, 5000}
+	other := []uint64{1, 65, 301, 5000, 9000}
+
+	tests := []struct {
+		name     string
+		op       func(BitArray, BitArray)
+		expected []uint64
+	}{
+		{"or", BitArray.OrInPlace, []uint64{1, 64, 65, 300, 301, 1000, 5000, 9000}},
+		{"and", BitArray.AndInPlace, []uint64{1, 65, 5000}},
+		{"nand", BitArray.NandInPlace, []uint64{64, 300, 1000}},
+		{"xor", BitArray.XorInPlace, []uint64{64, 300, 301, 1000, 9000}},
+	}
+
+	for _, test := range tests {
+		for selfName := range constructors(0, nil) {
+			for otherName, ob := range constructors(10000, other) {
+				ba := constructors(6000, self)[selfName]
+				test.op(ba, ob)
+				assert.Equal(t, test.expected, ba.ToNums(),
+					"%s %s with %s", test.name, selfName, otherName)
+			}
+		}
+	}
+}
+
+func TestInPlaceMatchesCopy(t *testing.T) {
+	self := []uint64{3, 70, 71, 1000, 1001, 4000}
+	other := []uint64{3, 71, 2000}
+
+	for selfName := range constructors(0, nil) {
+		for otherName, ob := range constructors(s*64, other) {
+			arrays := constructors(s*64, self)
+			ba := arrays[selfName]
+
+			expected := ba.Xor(ob)
+			ba.XorInPlace(ob)
+			assert.True(t, expected.Equals(ba), "%s with %s", selfName, otherName)
+		}
+	}
+}
+
+func TestDenseOrInPlaceGrows(t *testing.T) {
+	ba := newBitArray(s)
+	ba.SetBit(1)
+	other := newSparseBitArray()
+	other.SetBit(s * 10)
+
+	ba.OrInPlace(other)
+	assert.Equal(t, s*11, ba.Capacity())
+	assert.Equal(t, []uint64{1, s * 10}, ba.ToNums())
+	assert.Equal(t, s*10, ba.highest)
+}
+
+func TestDenseAndInPlaceDoesNotGrow(t *testing.T) {
+	ba := newBitArray(s)
+	ba.SetBit(1)
+	other := newSparseBitArray()
+	other.SetBit(1)
+	other.SetBit(s * 10)
+
+	ba.AndInPlace(other)
+	assert.Equal(t, s, ba.Capacity())
+	assert.Equal(t, []uint64{1}, ba.ToNums())
+}
+
+func TestSparseInPlaceRemovesEmptyBlocks(t *testing.T) {
+	sba := newSparseBitArray()
+	sba.SetBit(1)
+	sba.SetBit(s * 2)
+
+	other := newSparseBitArray()
+	other.SetBit(1)
+
+	sba.XorInPlace(other)
+	assert.Equal(t, uintSlice{2}, sba.indices)
+
+	sba.NandInPlace(sba)
+	assert.True(t, sba.IsEmpty())
+}
+
+func TestSparseOrInPlaceDoesNotAllocateWithCapacity(t *testing.T) {
+	sba := newSparseBitArray()
+	sba.indices = make(uintSlice, 0, 10)
+	sba.blocks = make(blocks, 0, 10)
+	sba.SetBit(s * 4)
+
+	other := newSparseBitArray()
+	other.SetBit(1)
+	other.SetBit(s * 8)
+
+	allocs := testing.AllocsPerRun(1, func() {
+		sba.OrInPlace(other)
+	})
+	assert.Equal(t, float64(0), allocs)
+	assert.Equal(t, []uint64{1, s * 4, s * 8}, sba.ToNums())
+}
+
+func BenchmarkOrInPlaceDenseWithDense(b *testing.B) {
+	numItems := uint64(160000)
+	ba := newBitArray(numItems)
+	other := newBitArray(numItems)
+	for i := uint64(0); i < numItems; i += 3 {
+		other.SetBit(i)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		ba.OrInPlace(other)
+	}
+}
+
+func BenchmarkOrInPlaceSparseWithSparse(b *testing.B) {
+	numItems := uint64(160000)
+	sba := newSparseBitArray()
+	other := newSparseBitArray()
+	for i := uint64(0); i < numItems; i += s {
+		if i%200 == 0 {
+			sba.SetBit(i)
+		} else if i%300 == 0 {
+			other.SetBit(i)
+		}
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		sba.OrInPlace(other)
+	}
+}
@@ -45,6 +45,28 @@ type BitArray interface {
 	// Blocks returns an iterator to be used to iterate
 	// over the bit array.
 	Blocks() Iterator
+	// SetBits returns an iterator over the positions of the set
+	// bits in ascending order.
+	SetBits() BitIterator
+	// NextSetBit returns the position of the first set bit at or
+	// after from.  The returned bool is false if there is none.
+	NextSetBit(from uint64) (uint64, bool)
+	// PrevSetBit returns the position of the last set bit at or
+	// before from.  The returned bool is false if there is none.
+	PrevSetBit(from uint64) (uint64, bool)
+	// SetRange sets every bit in the range [lo, hi).  This
+	// function returns an error if the range is out of bounds.
+	// A sparse bit array never returns an error.
+	SetRange(lo, hi uint64) error
+	// ClearRange clears every bit in the range [lo, hi).  This
+	// function returns an error if the range is out of bounds.
+	// A sparse bit array never returns an error.
+	ClearRange(lo, hi uint64) error
+	// CountRange returns the number of set bits in the range [lo, hi).
+	CountRange(lo, hi uint64) uint64
+	// GetRange returns a new bit array of the same kind holding the
+	// bits in the range [lo, hi), shifted so that lo is at position 0.
+	GetRange(lo, hi uint64) BitArray
 	// Equals returns a bool indicating equality between the
 	// two bit arrays.
 	Equals(other BitArray) bool
@@ -64,11 +86,46 @@ type BitArray interface {
 	// Nand will bitwise nand the two bitarrays and return a new bitarray
 	// representing the result.
 	Nand(other BitArray) BitArray
+	// Xor will bitwise xor the two bitarrays and return a new bitarray
+	// representing the result.
+	Xor(other BitArray) BitArray
+	// AndNot will bitwise and not the two bitarrays and return a new
+	// bitarray representing the result.  This is the same operation
+	// as Nand.
+	AndNot(other BitArray) BitArray
+	// OrInPlace will bitwise or the other bitarray into this bitarray.
+	// A dense bit array grows to fit the other bitarray if required.
+	OrInPlace(other BitArray)
+	// AndInPlace will bitwise and the other bitarray into this bitarray.
+	AndInPlace(other BitArray)
+	// NandInPlace will bitwise nand the other bitarray into this bitarray.
+	NandInPlace(other BitArray)
+	// XorInPlace will bitwise xor the other bitarray into this bitarray.
+	// A dense bit array grows to fit the other bitarray if required.
+	XorInPlace(other BitArray)
 	// ToNums converts this bit array to the list of numbers contained
 	// within it.
 	ToNums() []uint64
 	// IsEmpty checks to see if any values are set on the bitarray
 	IsEmpty() bool
+	// Count returns the number of set bits in the bitarray.
+	Count() uint64
+	// Rank returns the number of set bits at positions strictly
+	// below k.
+	Rank(k uint64) uint64
+	// Select returns the position of the n-th set bit, counting from
+	// zero.  This function returns an OutOfRangeError if the bitarray
+	// has n or fewer bits set.
+	Select(n uint64) (uint64, error)
+}
+
+// GrowableBitArray is a dense bit array that grows to fit any position
+// that is set instead of returning an OutOfRangeError.
+type GrowableBitArray interface {
+	BitArray
+	// Shrink releases the trailing blocks of the bit array that
+	// have no bits set, reducing its capacity.
+	Shrink()
 }
 
 // Iterator defines methods used to iterate over a bit array.
@@ -79,3 +136,13 @@ type Iterator interface {
 	// Value returns the next block and its index
 	Value() (uint64, block)
 }
+
+// BitIterator defines methods used to iterate over the set bits
+// of a bit array.
+type BitIterator interface {
+	// Next moves the pointer to the next set bit.  Returns
+	// false when no set bits remain.
+	Next() bool
+	// Value returns the position of the current set bit.
+	Value() uint64
+}
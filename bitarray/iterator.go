@@ -50,7 +50,8 @@ type bitArrayIterator struct {
 // items exist.
 func (iter *bitArrayIterator) Next() bool {
 	iter.index++
-	return uint64(iter.index) <= iter.stopIndex
+	// a zero capacity bit array has no block to yield, not even at index 0
+	return uint64(iter.index) <= iter.stopIndex && iter.index < int64(len(iter.ba.blocks))
 }
 
 // Value returns an index and the block at this index.
@@ -67,3 +68,84 @@ func newBitArrayIterator(ba *bitArray) *bitArrayIterator {
 		stopIndex: stop,
 	}
 }
+
+type roaringBitArrayIterator struct {
+	containerIndex int
+	blockIndex     int64
+	blocks         []block
+	buffer         []block
+	rba            *roaringBitArray
+}
+
+// Next moves to the next non-empty block and returns a bool indicating
+// if any further items exist.
+func (iter *roaringBitArrayIterator) Next() bool {
+	for {
+		for iter.blockIndex++; iter.blockIndex < int64(len(iter.blocks)); iter.blockIndex++ {
+			if iter.blocks[iter.blockIndex] != 0 {
+				return true
+			}
+		}
+
+		iter.containerIndex++
+		if iter.containerIndex >= len(iter.rba.containers) {
+			return false
+		}
+
+		iter.load()
+	}
+}
+
+// Value returns the index and block at the current position.
+func (iter *roaringBitArrayIterator) Value() (uint64, block) {
+	key := iter.rba.keys[iter.containerIndex]
+	return key*containerBlocks + uint64(iter.blockIndex), iter.blocks[iter.blockIndex]
+}
+
+// load expands the current container into the block buffer.
+func (iter *roaringBitArrayIterator) load() {
+	c := iter.rba.containers[iter.containerIndex]
+	iter.blockIndex = -1
+	if bc, ok := c.(*bitmapContainer); ok {
+		iter.blocks = bc.blocks
+		return
+	}
+
+	if iter.buffer == nil {
+		iter.buffer = make([]block, containerBlocks)
+	}
+	for i := range iter.buffer {
+		iter.buffer[i] = 0
+	}
+	c.fill(iter.buffer)
+	iter.blocks = iter.buffer
+}
+
+func newRoaringBitArrayIterator(rba *roaringBitArray) *roaringBitArrayIterator {
+	return &roaringBitArrayIterator{
+		rba:            rba,
+		containerIndex: -1,
+	}
+}
+
+// blocksIterator iterates over a plain slice of blocks.
+type blocksIterator struct {
+	index  int64
+	blocks []block
+}
+
+// Next increments the index and returns a bool indicating if any
+// further items exist.
+func (iter *blocksIterator) Next() bool {
+	iter.index++
+	return iter.index < int64(len(iter.blocks))
+}
+
+// Value returns an index and the block at this index.
+func (iter *blocksIterator) Value() (uint64, block) {
+	return uint64(iter.index), iter.blocks[iter.index]
+}
+
+func newBlocksIterator(bs []block) *blocksIterator {
+	return &blocksIterator{index: -1, blocks: bs}
+}
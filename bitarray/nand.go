@@ -142,6 +142,11 @@ func nandDenseWithDenseBitArray(dba, other *bitArray) BitArray {
 	ba := newBitArray(min * s)
 
 	for i := uint64(0); i < min; i++ {
+		if i >= uint64(len(other.blocks)) {
+			// nothing to clear beyond the other array
+			ba.blocks[i] = dba.blocks[i]
+			continue
+		}
 		ba.blocks[i] = dba.blocks[i].nand(other.blocks[i])
 	}
 
@@ -150,3 +155,9 @@ func nandDenseWithDenseBitArray(dba, other *bitArray) BitArray {
 
 	return ba
 }
+
+func nandRoaringWithRoaringBitArray(rba, other *roaringBitArray) BitArray {
+	// nand only removes bits from the receiver, so containers that only
+	// exist in the other array are dropped
+	return rba.merge(other, block.nand, true, false)
+}
@@ -150,3 +150,9 @@ func orDenseWithDenseBitArray(dba *bitArray, other *bitArray) BitArray {
 
 	return ba
 }
+
+func orRoaringWithRoaringBitArray(rba, other *roaringBitArray) BitArray {
+	// containers missing from either side are kept as is, only
+	// containers with matching keys need to be combined
+	return rba.merge(other, block.or, true, true)
+}
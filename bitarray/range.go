@@ -0,0 +1,484 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import (
+	"math"
+	"math/bits"
+)
+
+// nextSetBit returns the lowest set bit in this block at or above
+// the given position.
+func (b block) nextSetBit(position uint64) (uint64, bool) {
+	b &= maximumBlock << position
+	if b == 0 {
+		return 0, false
+	}
+
+	return uint64(bits.TrailingZeros64(uint64(b))), true
+}
+
+// prevSetBit returns the highest set bit in this block at or below
+// the given position.
+func (b block) prevSetBit(position uint64) (uint64, bool) {
+	b &= maximumBlock >> (s - 1 - position)
+	if b == 0 {
+		return 0, false
+	}
+
+	return s - 1 - uint64(bits.LeadingZeros64(uint64(b))), true
+}
+
+// rangeSource is a blockSource holding every bit in the range
+// [lo, hi).  The range must not be empty.
+type rangeSource struct {
+	lo, hi uint64
+}
+
+func (rs rangeSource) numEntries() int {
+	return int((rs.hi-1)/s - rs.lo/s + 1)
+}
+
+func (rs rangeSource) entry(i int) (uint64, block) {
+	first, last := rs.lo/s, (rs.hi-1)/s
+	index := first + uint64(i)
+	b := maximumBlock
+	if index == first {
+		b &= maximumBlock << (rs.lo % s)
+	}
+	if index == last {
+		b &= maximumBlock >> (s - 1 - (rs.hi-1)%s)
+	}
+
+	return index, b
+}
+
+type setBitIterator struct {
+	blocks  Iterator
+	index   uint64
+	current block
+	value   uint64
+}
+
+// Next moves to the next set bit and returns a bool indicating
+// if any further set bits exist.
+func (iter *setBitIterator) Next() bool {
+	for iter.current == 0 {
+		if iter.blocks == nil || !iter.blocks.Next() {
+			return false
+		}
+		iter.index, iter.current = iter.blocks.Value()
+	}
+
+	position := uint64(bits.TrailingZeros64(uint64(iter.current)))
+	iter.current &= iter.current - 1
+	iter.value = iter.index*s + position
+	return true
+}
+
+// Value returns the position of the current set bit.
+func (iter *setBitIterator) Value() uint64 {
+	return iter.value
+}
+
+func newSetBitIterator(blocks Iterator) *setBitIterator {
+	return &setBitIterator{blocks: blocks}
+}
+
+// countRange returns the number of set bits of the bit array in the
+// range [lo, hi).
+func countRange(ba BitArray, lo, hi uint64) uint64 {
+	if lo >= hi {
+		return 0
+	}
+
+	return ba.Rank(hi) - ba.Rank(lo)
+}
+
+// copyRange sets the bits of the bit array in the range [lo, hi) in
+// result, shifted down by lo, and returns result.
+func copyRange(ba, result BitArray, lo, hi uint64) BitArray {
+	for k, ok := ba.NextSetBit(lo); ok && k < hi; k, ok = ba.NextSetBit(k + 1) {
+		result.SetBit(k - lo)
+		if k == math.MaxUint64 {
+			// k + 1 would wrap around to bit 0
+			break
+		}
+	}
+
+	return result
+}
+
+// SetBits returns an iterator over the set bits of this bit array.
+func (ba *bitArray) SetBits() BitIterator {
+	if !ba.anyset {
+		return newSetBitIterator(nil)
+	}
+
+	return newSetBitIterator(ba.Blocks())
+}
+
+// NextSetBit returns the position of the first set bit at or after from.
+func (ba *bitArray) NextSetBit(from uint64) (uint64, bool) {
+	if !ba.anyset || from >= ba.Capacity() {
+		return 0, false
+	}
+
+	i, pos := getIndexAndRemainder(from)
+	for ; i < uint64(len(ba.blocks)); i++ {
+		if position, ok := ba.blocks[i].nextSetBit(pos); ok {
+			return i*s + position, true
+		}
+		pos = 0
+	}
+
+	return 0, false
+}
+
+// PrevSetBit returns the position of the last set bit at or before from.
+func (ba *bitArray) PrevSetBit(from uint64) (uint64, bool) {
+	if !ba.anyset {
+		return 0, false
+	}
+
+	if from >= ba.Capacity() {
+		from = ba.Capacity() - 1
+	}
+
+	i, pos := getIndexAndRemainder(from)
+	for ; i < uint64(len(ba.blocks)); i-- {
+		if position, ok := ba.blocks[i].prevSetBit(pos); ok {
+			return i*s + position, true
+		}
+		pos = s - 1
+	}
+
+	return 0, false
+}
+
+// SetRange sets every bit in the range [lo, hi).
+func (ba *bitArray) SetRange(lo, hi uint64) error {
+	if ba.immutable {
+		return ErrImmutable
+	}
+
+	if hi > ba.Capacity() && !ba.growable {
+		return OutOfRangeError(hi - 1)
+	}
+
+	if lo < hi {
+		ba.mergeInPlace(rangeSource{lo: lo, hi: hi}, block.or)
+	}
+
+	return nil
+}
+
+// ClearRange clears every bit in the range [lo, hi).
+func (ba *bitArray) ClearRange(lo, hi uint64) error {
+	if ba.immutable {
+		return ErrImmutable
+	}
+
+	if hi > ba.Capacity() {
+		if !ba.growable {
+			return OutOfRangeError(hi - 1)
+		}
+		// nothing is set beyond our capacity
+		hi = ba.Capacity()
+	}
+
+	if lo < hi {
+		ba.mergeInPlace(rangeSource{lo: lo, hi: hi}, block.nand)
+	}
+
+	return nil
+}
+
+// CountRange returns the number of set bits in the range [lo, hi).
+func (ba *bitArray) CountRange(lo, hi uint64) uint64 {
+	return countRange(ba, lo, hi)
+}
+
+// GetRange returns a new dense bit array holding the bits in the range
+// [lo, hi).  The result is of size hi - lo, but no larger than needed to
+// hold the bits up to the end of this bit array.
+func (ba *bitArray) GetRange(lo, hi uint64) BitArray {
+	// bits beyond our capacity are never set
+	hi = minUint64(hi, ba.Capacity())
+	if lo >= hi {
+		return newBitArray(0)
+	}
+
+	result := newBitArray(hi - lo)
+	blockAt := func(i uint64) block {
+		if i < uint64(len(ba.blocks)) {
+			return ba.blocks[i]
+		}
+		return 0
+	}
+
+	index, shift := getIndexAndRemainder(lo)
+	for i := range result.blocks {
+		b := blockAt(index+uint64(i)) >> shift
+		if shift > 0 {
+			b |= blockAt(index+uint64(i)+1) << (s - shift)
+		}
+		result.blocks[i] = b
+	}
+
+	if _, r := getIndexAndRemainder(hi - lo); r > 0 {
+		result.blocks[len(result.blocks)-1] &= ^(maximumBlock << r)
+	}
+
+	result.setLowest()
+	result.setHighest()
+	return result
+}
+
+// SetBits returns an iterator over the set bits of this bit array.
+func (sba *sparseBitArray) SetBits() BitIterator {
+	return newSetBitIterator(sba.Blocks())
+}
+
+// NextSetBit returns the position of the first set bit at or after from.
+func (sba *sparseBitArray) NextSetBit(from uint64) (uint64, bool) {
+	index, pos := getIndexAndRemainder(from)
+	for i := sba.indices.search(index); i < int64(len(sba.indices)); i++ {
+		if sba.indices[i] != index {
+			pos = 0
+		}
+
+		if position, ok := sba.blocks[i].nextSetBit(pos); ok {
+			return sba.indices[i]*s + position, true
+		}
+	}
+
+	return 0, false
+}
+
+// PrevSetBit returns the position of the last set bit at or before from.
+func (sba *sparseBitArray) PrevSetBit(from uint64) (uint64, bool) {
+	index, pos := getIndexAndRemainder(from)
+	i := sba.indices.search(index)
+	if i < int64(len(sba.indices)) && sba.indices[i] == index {
+		if position, ok := sba.blocks[i].prevSetBit(pos); ok {
+			return index*s + position, true
+		}
+	}
+
+	for i--; i >= 0; i-- {
+		if position, ok := sba.blocks[i].prevSetBit(s - 1); ok {
+			return sba.indices[i]*s + position, true
+		}
+	}
+
+	return 0, false
+}
+
+// SetRange sets every bit in the range [lo, hi).
+func (sba *sparseBitArray) SetRange(lo, hi uint64) error {
+	if lo < hi {
+		sba.mergeInPlace(rangeSource{lo: lo, hi: hi}, block.or)
+	}
+
+	return nil
+}
+
+// ClearRange clears every bit in the range [lo, hi).
+func (sba *sparseBitArray) ClearRange(lo, hi uint64) error {
+	if lo < hi {
+		sba.mergeInPlace(rangeSource{lo: lo, hi: hi}, block.nand)
+	}
+
+	return nil
+}
+
+// CountRange returns the number of set bits in the range [lo, hi).
+func (sba *sparseBitArray) CountRange(lo, hi uint64) uint64 {
+	return countRange(sba, lo, hi)
+}
+
+// GetRange returns a new sparse bit array holding the bits in the
+// range [lo, hi).
+func (sba *sparseBitArray) GetRange(lo, hi uint64) BitArray {
+	return copyRange(sba, newSparseBitArray(), lo, hi)
+}
+
+// SetBits returns an iterator over the set bits of this bit array.
+func (rba *roaringBitArray) SetBits() BitIterator {
+	return newSetBitIterator(rba.Blocks())
+}
+
+// NextSetBit returns the position of the first set bit at or after from.
+func (rba *roaringBitArray) NextSetBit(from uint64) (uint64, bool) {
+	key, low := splitKey(from)
+	i := rba.keys.search(key)
+	if i < int64(len(rba.keys)) && rba.keys[i] == key {
+		if x, ok := rba.containers[i].nextValue(low); ok {
+			return key<<containerBits + uint64(x), true
+		}
+		i++
+	}
+
+	if i == int64(len(rba.keys)) {
+		return 0, false
+	}
+
+	// containers are never empty, so the next one holds the answer
+	x, _ := rba.containers[i].nextValue(0)
+	return rba.keys[i]<<containerBits + uint64(x), true
+}
+
+// PrevSetBit returns the position of the last set bit at or before from.
+func (rba *roaringBitArray) PrevSetBit(from uint64) (uint64, bool) {
+	key, low := splitKey(from)
+	i := rba.keys.search(key)
+	if i < int64(len(rba.keys)) && rba.keys[i] == key {
+		if x, ok := rba.containers[i].prevValue(low); ok {
+			return key<<containerBits + uint64(x), true
+		}
+	}
+
+	if i == 0 {
+		return 0, false
+	}
+
+	return rba.keys[i-1]<<containerBits + uint64(rba.containers[i-1].maximum()), true
+}
+
+// SetRange sets every bit in the range [lo, hi).
+func (rba *roaringBitArray) SetRange(lo, hi uint64) error {
+	if lo < hi {
+		*rba = *rba.merge(newRoaringBitArrayRange(lo, hi), block.or, true, true)
+	}
+
+	return nil
+}
+
+// ClearRange clears every bit in the range [lo, hi).
+func (rba *roaringBitArray) ClearRange(lo, hi uint64) error {
+	if lo < hi {
+		*rba = *rba.merge(newRoaringBitArrayRange(lo, hi), block.nand, true, false)
+	}
+
+	return nil
+}
+
+// CountRange returns the number of set bits in the range [lo, hi).
+func (rba *roaringBitArray) CountRange(lo, hi uint64) uint64 {
+	return countRange(rba, lo, hi)
+}
+
+// GetRange returns a new roaring bit array holding the bits in the
+// range [lo, hi).
+func (rba *roaringBitArray) GetRange(lo, hi uint64) BitArray {
+	return copyRange(rba, newRoaringBitArray(), lo, hi)
+}
+
+// newRoaringBitArrayRange returns a roaring bit array with every bit in
+// the range [lo, hi) set.  The range must not be empty.
+func newRoaringBitArrayRange(lo, hi uint64) *roaringBitArray {
+	rba := newRoaringBitArray()
+	firstKey, start := splitKey(lo)
+	lastKey, last := splitKey(hi - 1)
+	for key := firstKey; key <= lastKey; key++ {
+		r := run{start: 0, last: containerSize - 1}
+		if key == firstKey {
+			r.start = start
+		}
+		if key == lastKey {
+			r.last = last
+		}
+
+		rba.keys = append(rba.keys, key)
+		rba.containers = append(rba.containers, &runContainer{runs: []run{r}})
+	}
+
+	return rba
+}
+
+func (ac *arrayContainer) nextValue(x uint16) (uint16, bool) {
+	i := ac.search(x)
+	if i == len(ac.values) {
+		return 0, false
+	}
+
+	return ac.values[i], true
+}
+
+func (ac *arrayContainer) prevValue(x uint16) (uint16, bool) {
+	i := ac.search(x)
+	if i < len(ac.values) && ac.values[i] == x {
+		return x, true
+	}
+
+	if i == 0 {
+		return 0, false
+	}
+
+	return ac.values[i-1], true
+}
+
+func (bc *bitmapContainer) nextValue(x uint16) (uint16, bool) {
+	index, pos := getIndexAndRemainder(uint64(x))
+	for ; index < containerBlocks; index++ {
+		if position, ok := bc.blocks[index].nextSetBit(pos); ok {
+			return uint16(index*s + position), true
+		}
+		pos = 0
+	}
+
+	return 0, false
+}
+
+func (bc *bitmapContainer) prevValue(x uint16) (uint16, bool) {
+	index, pos := getIndexAndRemainder(uint64(x))
+	for ; index < containerBlocks; index-- {
+		if position, ok := bc.blocks[index].prevSetBit(pos); ok {
+			return uint16(index*s + position), true
+		}
+		pos = s - 1
+	}
+
+	return 0, false
+}
+
+func (rc *runContainer) nextValue(x uint16) (uint16, bool) {
+	i := rc.search(x)
+	if i == len(rc.runs) {
+		return 0, false
+	}
+
+	if rc.runs[i].start > x {
+		return rc.runs[i].start, true
+	}
+
+	return x, true
+}
+
+func (rc *runContainer) prevValue(x uint16) (uint16, bool) {
+	i := rc.search(x)
+	if i < len(rc.runs) && rc.runs[i].start <= x {
+		return x, true
+	}
+
+	if i == 0 {
+		return 0, false
+	}
+
+	return rc.runs[i-1].last, true
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockNextPrevSetBit(t *testing.T) {
+	b := block(0).insert(3).insert(40)
+
+	position, ok := b.nextSetBit(0)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), position)
+
+	position, ok = b.nextSetBit(4)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(40), position)
+
+	_, ok = b.nextSetBit(41)
+	assert.False(t, ok)
+
+	position, ok = b.prevSetBit(s - 1)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(40), position)
+
+	position, ok = b.prevSetBit(39)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), position)
+
+	_, ok = b.prevSetBit(2)
+	assert.False(t, ok)
+}
+
+func TestSetBits(t *testing.T) {
+	nums := []uint64{0, 5, 63, 64, 200, 70000}
+
+	for name, ba := range constructors(80000, nums) {
+		result := []uint64{}
+		for iter := ba.SetBits(); iter.Next(); {
+			result = append(result, iter.Value())
+		}
+		assert.Equal(t, nums, result, name)
+	}
+
+	for name, ba := range constructors(0, nil) {
+		assert.False(t, ba.SetBits().Next(), name)
+	}
+}
+
+func TestNextPrevSetBit(t *testing.T) {
+	nums := []uint64{5, 64, 200, 70000}
+
+	for name, ba := range constructors(80000, nums) {
+		next := func(from uint64) interface{} {
+			if k, ok := ba.NextSetBit(from); ok {
+				return k
+			}
+			return nil
+		}
+		prev := func(from uint64) interface{} {
+			if k, ok := ba.PrevSetBit(from); ok {
+				return k
+			}
+			return nil
+		}
+
+		assert.Equal(t, uint64(5), next(0), name)
+		assert.Equal(t, uint64(5), next(5), name)
+		assert.Equal(t, uint64(64), next(6), name)
+		assert.Equal(t, uint64(70000), next(201), name)
+		assert.Nil(t, next(70001), name)
+
+		assert.Nil(t, prev(4), name)
+		assert.Equal(t, uint64(5), prev(5), name)
+		assert.Equal(t, uint64(5), prev(63), name)
+		assert.Equal(t, uint64(200), prev(69999), name)
+		assert.Equal(t, uint64(70000), prev(1<<40), name)
+	}
+}
+
+func TestSetClearRange(t *testing.T) {
+	for name, ba := range constructors(s*8, nil) {
+		assert.Nil(t, ba.SetRange(10, s*3+5), name)
+		assert.Equal(t, s*3+5-10, ba.Count(), name)
+		checkBit(t, ba, 9, false)
+		checkBit(t, ba, 10, true)
+		checkBit(t, ba, s*3+4, true)
+		checkBit(t, ba, s*3+5, false)
+
+		assert.Nil(t, ba.ClearRange(20, s*2), name)
+		assert.Equal(t, []uint64{10, 11, 12, 13, 14, 15, 16, 17, 18, 19},
+			ba.GetRange(0, 20).ToNums(), name)
+		assert.Equal(t, uint64(10+s+5), ba.Count(), name)
+
+		// empty ranges are a no-op
+		assert.Nil(t, ba.SetRange(30, 30), name)
+		assert.Nil(t, ba.ClearRange(30, 20), name)
+		assert.Equal(t, uint64(10+s+5), ba.Count(), name)
+	}
+}
+
+func TestDenseSetRangeOutOfBounds(t *testing.T) {
+	ba := newBitArray(s)
+	assert.Equal(t, OutOfRangeError(s), ba.SetRange(0, s+1))
+	assert.Equal(t, OutOfRangeError(s), ba.ClearRange(0, s+1))
+	assert.True(t, ba.IsEmpty())
+}
+
+func TestRoaringSetRangeAcrossContainers(t *testing.T) {
+	rba := newRoaringBitArray()
+	rba.SetBit(3)
+	assert.Nil(t, rba.SetRange(containerSize-2, containerSize*3+2))
+	assert.Len(t, rba.keys, 4)
+	assert.IsType(t, &runContainer{}, rba.containers[2])
+	assert.Equal(t, uint64(1+containerSize*2+4), rba.Count())
+
+	assert.Nil(t, rba.ClearRange(containerSize, containerSize*2))
+	assert.Len(t, rba.keys, 3)
+	assert.Equal(t, uint64(1+containerSize+4), rba.Count())
+}
+
+func TestCountRange(t *testing.T) {
+	nums := []uint64{1, 2, 64, 100, 70000}
+
+	for name, ba := range constructors(80000, nums) {
+		assert.Equal(t, uint64(2), ba.CountRange(0, 64), name)
+		assert.Equal(t, uint64(3), ba.CountRange(2, 101), name)
+		assert.Equal(t, uint64(1), ba.CountRange(101, 1<<40), name)
+		assert.Equal(t, uint64(0), ba.CountRange(50, 10), name)
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	nums := []uint64{1, 2, 64, 100, 130, 70000}
+
+	for name, ba := range constructors(80000, nums) {
+		result := ba.GetRange(2, 131)
+		assert.IsType(t, ba, result, name)
+		assert.Equal(t, []uint64{0, 62, 98, 128}, result.ToNums(), name)
+
+		assert.True(t, ba.GetRange(3, 64).IsEmpty(), name)
+		assert.True(t, ba.GetRange(10, 5).IsEmpty(), name)
+	}
+
+	ba := newBitArray(s * 2)
+	ba.SetBit(s*2 - 1)
+	result := ba.GetRange(s-1, s*2)
+	assert.Equal(t, newBitArray(s+1).Capacity(), result.Capacity())
+	assert.Equal(t, []uint64{s}, result.ToNums())
+
+	// bits beyond the requested range are masked off
+	result = ba.GetRange(0, s*2-1)
+	assert.True(t, result.IsEmpty())
+}
+
+func TestGetRangeBeyondCapacity(t *testing.T) {
+	ba := newBitArray(100)
+	ba.SetBit(99)
+
+	result := ba.GetRange(0, 1<<40)
+	assert.Equal(t, ba.Capacity(), result.Capacity())
+	assert.Equal(t, []uint64{99}, result.ToNums())
+
+	result = ba.GetRange(90, 1<<40)
+	assert.Equal(t, []uint64{9}, result.ToNums())
+	assert.True(t, ba.GetRange(1<<30, 1<<40).IsEmpty())
+}
+
+func TestSparseSetRangeLarge(t *testing.T) {
+	sba := newSparseBitArray()
+	sba.SetBit(s * 10)
+	sba.SetBit(1 << 30)
+
+	assert.Nil(t, sba.SetRange(1, 1<<24))
+	assert.Equal(t, uint64(1<<24), sba.Count())
+	// the blocks of the range and the block holding 1 << 30
+	assert.Equal(t, int(1<<24/s+1), len(sba.indices))
+
+	assert.Nil(t, sba.ClearRange(0, 1<<24))
+	assert.Equal(t, []uint64{1 << 30}, sba.ToNums())
+}
+
+func TestGetRangeTopBit(t *testing.T) {
+	for name, ba := range map[string]BitArray{
+		"sparse":  newSparseBitArray(),
+		"roaring": newRoaringBitArray(),
+	} {
+		ba.SetBit(math.MaxUint64 - 1)
+		ba.SetBit(math.MaxUint64)
+
+		result := ba.GetRange(math.MaxUint64-2, math.MaxUint64)
+		assert.Equal(t, []uint64{1}, result.ToNums(), name)
+
+		assert.True(t, ba.GetRange(math.MaxUint64, math.MaxUint64).IsEmpty(), name)
+	}
+}
+
+func BenchmarkSetBitsDense(b *testing.B) {
+	numItems := uint64(160000)
+	ba := newBitArray(numItems)
+	for i := uint64(0); i < numItems; i += 3 {
+		ba.SetBit(i)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for iter := ba.SetBits(); iter.Next(); {
+			iter.Value()
+		}
+	}
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import (
+	"math/bits"
+	"sort"
+)
+
+const (
+	// rankSampleRate is the number of blocks covered by a single
+	// entry in the rank index of a dense bit array.  Larger values
+	// use less memory at the expense of more popcounts per query.
+	rankSampleRate = 8
+	// rankIndexThreshold is the number of blocks a dense bit array
+	// requires before Rank and Select build a rank index.  Smaller
+	// arrays are cheap enough to count directly.
+	rankIndexThreshold = 1024
+)
+
+func (b block) popCount() uint64 {
+	return uint64(bits.OnesCount64(uint64(b)))
+}
+
+// rank returns the number of set bits in this block at positions
+// strictly below the given position.
+func (b block) rank(position uint64) uint64 {
+	return (b & ^(maximumBlock << position)).popCount()
+}
+
+// selectBit returns the position of the n-th set bit in this block.
+// The block must have more than n bits set.
+func (b block) selectBit(n uint64) uint64 {
+	for ; n > 0; n-- {
+		b &= b - 1
+	}
+
+	return uint64(bits.TrailingZeros64(uint64(b)))
+}
+
+// Count returns the number of set bits in this bit array.
+func (ba *bitArray) Count() uint64 {
+	if !ba.anyset {
+		return 0
+	}
+
+	var count uint64
+	for _, b := range ba.blocks {
+		count += b.popCount()
+	}
+
+	return count
+}
+
+// Rank returns the number of set bits below k.  Large bit arrays build a
+// rank index on the first call so subsequent calls run in constant time.
+// The index is discarded whenever the bit array is modified.
+func (ba *bitArray) Rank(k uint64) uint64 {
+	if k >= ba.Capacity() {
+		return ba.Count()
+	}
+
+	i, pos := getIndexAndRemainder(k)
+	var count uint64
+	start := uint64(0)
+	if ranks := ba.rankIndex(); ranks != nil {
+		start = i / rankSampleRate * rankSampleRate
+		count = ranks[i/rankSampleRate]
+	}
+
+	for j := start; j < i; j++ {
+		count += ba.blocks[j].popCount()
+	}
+
+	return count + ba.blocks[i].rank(pos)
+}
+
+// Select returns the position of the n-th set bit, counting from zero.
+func (ba *bitArray) Select(n uint64) (uint64, error) {
+	requested := n
+	start := uint64(0)
+	if ranks := ba.rankIndex(); ranks != nil {
+		// find the last sample with fewer than n+1 bits below it
+		sample := uint64(sort.Search(len(ranks), func(i int) bool { return ranks[i] > n })) - 1
+		start = sample * rankSampleRate
+		n -= ranks[sample]
+	}
+
+	for i := start; i < uint64(len(ba.blocks)); i++ {
+		count := ba.blocks[i].popCount()
+		if n < count {
+			return i*s + ba.blocks[i].selectBit(n), nil
+		}
+		n -= count
+	}
+
+	return 0, OutOfRangeError(requested)
+}
+
+// rankIndex returns the rank index of this bit array, building it if
+// required.  It returns nil if the bit array is too small to need one.
+func (ba *bitArray) rankIndex() []uint64 {
+	if len(ba.blocks) < rankIndexThreshold {
+		return nil
+	}
+
+	if ba.ranks == nil {
+		ranks := make([]uint64, (len(ba.blocks)+rankSampleRate-1)/rankSampleRate)
+		var count uint64
+		for i, b := range ba.blocks {
+			if i%rankSampleRate == 0 {
+				ranks[i/rankSampleRate] = count
+			}
+			count += b.popCount()
+		}
+		ba.ranks = ranks
+	}
+
+	return ba.ranks
+}
+
+// Count returns the number of set bits in this bit array.
+func (sba *sparseBitArray) Count() uint64 {
+	var count uint64
+	for _, b := range sba.blocks {
+		count += b.popCount()
+	}
+
+	return count
+}
+
+// Rank returns the number of set bits below k.
+func (sba *sparseBitArray) Rank(k uint64) uint64 {
+	index, position := getIndexAndRemainder(k)
+	i := sba.indices.search(index)
+
+	var count uint64
+	for _, b := range sba.blocks[:i] {
+		count += b.popCount()
+	}
+
+	if i < int64(len(sba.indices)) && sba.indices[i] == index {
+		count += sba.blocks[i].rank(position)
+	}
+
+	return count
+}
+
+// Select returns the position of the n-th set bit, counting from zero.
+func (sba *sparseBitArray) Select(n uint64) (uint64, error) {
+	requested := n
+	for i, b := range sba.blocks {
+		count := b.popCount()
+		if n < count {
+			return sba.indices[i]*s + b.selectBit(n), nil
+		}
+		n -= count
+	}
+
+	return 0, OutOfRangeError(requested)
+}
+
+// Count returns the number of set bits in this bit array.
+func (rba *roaringBitArray) Count() uint64 {
+	return uint64(rba.cardinality())
+}
+
+// Rank returns the number of set bits below k.
+func (rba *roaringBitArray) Rank(k uint64) uint64 {
+	key, low := splitKey(k)
+	i := rba.keys.search(key)
+
+	var count uint64
+	for _, c := range rba.containers[:i] {
+		count += uint64(c.cardinality())
+	}
+
+	if i < int64(len(rba.keys)) && rba.keys[i] == key {
+		count += uint64(rba.containers[i].rank(low))
+	}
+
+	return count
+}
+
+// Select returns the position of the n-th set bit, counting from zero.
+func (rba *roaringBitArray) Select(n uint64) (uint64, error) {
+	requested := n
+	for i, c := range rba.containers {
+		card := uint64(c.cardinality())
+		if n < card {
+			return rba.keys[i]<<containerBits + uint64(c.selectValue(int(n))), nil
+		}
+		n -= card
+	}
+
+	return 0, OutOfRangeError(requested)
+}
+
+func (ac *arrayContainer) rank(x uint16) int {
+	return ac.search(x)
+}
+
+func (ac *arrayContainer) selectValue(n int) uint16 {
+	return ac.values[n]
+}
+
+func (bc *bitmapContainer) rank(x uint16) int {
+	index, position := getIndexAndRemainder(uint64(x))
+	count := bc.blocks[index].rank(position)
+	for _, b := range bc.blocks[:index] {
+		count += b.popCount()
+	}
+
+	return int(count)
+}
+
+func (bc *bitmapContainer) selectValue(n int) uint16 {
+	remaining := uint64(n)
+	for i, b := range bc.blocks {
+		count := b.popCount()
+		if remaining < count {
+			return uint16(uint64(i)*s + b.selectBit(remaining))
+		}
+		remaining -= count
+	}
+
+	return 0
+}
+
+func (rc *runContainer) rank(x uint16) int {
+	count := 0
+	for _, r := range rc.runs {
+		if r.start >= x {
+			break
+		}
+		if r.last >= x {
+			return count + int(x-r.start)
+		}
+		count += int(r.last-r.start) + 1
+	}
+
+	return count
+}
+
+func (rc *runContainer) selectValue(n int) uint16 {
+	for _, r := range rc.runs {
+		length := int(r.last-r.start) + 1
+		if n < length {
+			return r.start + uint16(n)
+		}
+		n -= length
+	}
+
+	return 0
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockRankAndSelect(t *testing.T) {
+	b := block(0).insert(1).insert(5).insert(s - 1)
+
+	assert.Equal(t, uint64(3), b.popCount())
+	assert.Equal(t, uint64(0), b.rank(1))
+	assert.Equal(t, uint64(1), b.rank(2))
+	assert.Equal(t, uint64(2), b.rank(s-1))
+
+	assert.Equal(t, uint64(1), b.selectBit(0))
+	assert.Equal(t, uint64(5), b.selectBit(1))
+	assert.Equal(t, s-1, b.selectBit(2))
+}
+
+func TestCountRankSelect(t *testing.T) {
+	nums := []uint64{0, 3, 64, 65, 1000, 70000, 70001, 140000}
+
+	for name, ba := range constructors(200000, nums) {
+		assert.Equal(t, uint64(len(nums)), ba.Count(), name)
+
+		for i, num := range nums {
+			assert.Equal(t, uint64(i), ba.Rank(num), "%s rank %d", name, num)
+			assert.Equal(t, uint64(i+1), ba.Rank(num+1), "%s rank %d", name, num+1)
+
+			result, err := ba.Select(uint64(i))
+			assert.Nil(t, err)
+			assert.Equal(t, num, result, "%s select %d", name, i)
+		}
+
+		assert.Equal(t, uint64(len(nums)), ba.Rank(1<<40), name)
+
+		_, err := ba.Select(uint64(len(nums)))
+		assert.Equal(t, OutOfRangeError(len(nums)), err, name)
+	}
+}
+
+func TestCountEmpty(t *testing.T) {
+	for name, ba := range constructors(100, nil) {
+		assert.Equal(t, uint64(0), ba.Count(), name)
+		assert.Equal(t, uint64(0), ba.Rank(50), name)
+
+		_, err := ba.Select(0)
+		assert.IsType(t, OutOfRangeError(0), err, name)
+	}
+}
+
+func TestDenseRankIndex(t *testing.T) {
+	numItems := uint64(rankIndexThreshold * s * 2)
+	ba := newBitArray(numItems)
+	for i := uint64(0); i < numItems; i += 7 {
+		ba.SetBit(i)
+	}
+
+	assert.Equal(t, uint64(1), ba.Rank(1))
+	assert.NotNil(t, ba.ranks)
+
+	for _, k := range []uint64{0, 7, 8, 1000, 99999, numItems - 100} {
+		expected := (k + 6) / 7
+		assert.Equal(t, expected, ba.Rank(k), "rank %d", k)
+
+		result, err := ba.Select(expected)
+		assert.Nil(t, err)
+		assert.Equal(t, expected*7, result)
+	}
+
+	// modifying the array invalidates the index
+	ba.SetBit(1)
+	assert.Nil(t, ba.ranks)
+	assert.Equal(t, uint64(2), ba.Rank(2))
+	assert.Equal(t, uint64(16), ba.Rank(100))
+}
+
+func TestRoaringContainerRankSelect(t *testing.T) {
+	rba := newRoaringBitArray()
+	// one container of each kind
+	for i := uint64(0); i < 10; i++ {
+		rba.SetBit(i * 3)
+	}
+	for i := uint64(containerSize); i < containerSize*2; i += 2 {
+		rba.SetBit(i)
+	}
+	for i := uint64(containerSize * 2); i < containerSize*3; i++ {
+		rba.SetBit(i)
+	}
+	assert.IsType(t, &arrayContainer{}, rba.containers[0])
+	assert.IsType(t, &bitmapContainer{}, rba.containers[1])
+	assert.IsType(t, &runContainer{}, rba.containers[2])
+
+	assert.Equal(t, uint64(10+containerSize/2+containerSize), rba.Count())
+	assert.Equal(t, uint64(10+3), rba.Rank(containerSize+5))
+	assert.Equal(t, uint64(10+containerSize/2+5), rba.Rank(containerSize*2+5))
+
+	result, err := rba.Select(12)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(containerSize+4), result)
+
+	result, err = rba.Select(10 + containerSize/2 + 5)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(containerSize*2+5), result)
+}
+
+func BenchmarkDenseRank(b *testing.B) {
+	numItems := uint64(1000000)
+	ba := newBitArray(numItems)
+	for i := uint64(0); i < numItems; i += 3 {
+		ba.SetBit(i)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		ba.Rank(uint64(i) % numItems)
+	}
+}
@@ -0,0 +1,376 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+// roaringBitArray is a compressed bit array that splits positions into
+// chunks of 2^16 values.  Each chunk is stored in whichever container
+// (sorted array, bitmap or list of runs) represents it in the least
+// amount of memory.  This gives the roaring bit array the memory
+// footprint of a sparse bit array for sparse data and close to the speed
+// of a dense bit array for dense data.
+type roaringBitArray struct {
+	keys       uintSlice
+	containers []container
+}
+
+func splitKey(k uint64) (uint64, uint16) {
+	return k >> containerBits, uint16(k)
+}
+
+func (rba *roaringBitArray) insertContainer(i int64, c container) {
+	rba.containers = append(rba.containers, nil)
+	copy(rba.containers[i+1:], rba.containers[i:])
+	rba.containers[i] = c
+}
+
+func (rba *roaringBitArray) deleteContainerAtIndex(i int64) {
+	copy(rba.containers[i:], rba.containers[i+1:])
+	rba.containers[len(rba.containers)-1] = nil
+	rba.containers = rba.containers[:len(rba.containers)-1]
+	rba.keys.deleteAtIndex(i)
+}
+
+// SetBit sets the bit at the given position.
+func (rba *roaringBitArray) SetBit(k uint64) error {
+	key, low := splitKey(k)
+	i, inserted := rba.keys.insert(key)
+	if inserted {
+		rba.insertContainer(i, &arrayContainer{})
+	}
+
+	rba.containers[i] = rba.containers[i].add(low)
+	return nil
+}
+
+// GetBit gets the bit at the given position.
+func (rba *roaringBitArray) GetBit(k uint64) (bool, error) {
+	key, low := splitKey(k)
+	i := rba.keys.get(key)
+	if i == -1 {
+		return false, nil
+	}
+
+	return rba.containers[i].contains(low), nil
+}
+
+// ClearBit clears the bit at the given position.
+func (rba *roaringBitArray) ClearBit(k uint64) error {
+	key, low := splitKey(k)
+	i := rba.keys.get(key)
+	if i == -1 {
+		return nil
+	}
+
+	rba.containers[i] = rba.containers[i].remove(low)
+	if rba.containers[i].cardinality() == 0 {
+		rba.deleteContainerAtIndex(i)
+	}
+
+	return nil
+}
+
+// Reset erases all values from this bitarray.
+func (rba *roaringBitArray) Reset() {
+	for i := range rba.containers {
+		rba.containers[i] = nil
+	}
+	rba.containers = rba.containers[:0]
+	rba.keys = rba.keys[:0]
+}
+
+// Blocks returns an iterator over this bitarray's non-empty blocks.
+func (rba *roaringBitArray) Blocks() Iterator {
+	return newRoaringBitArrayIterator(rba)
+}
+
+// Capacity returns the value of the highest possible *seen* value
+// in this roaring bitarray.
+func (rba *roaringBitArray) Capacity() uint64 {
+	if len(rba.keys) == 0 {
+		return 0
+	}
+
+	last := len(rba.keys) - 1
+	highest := rba.keys[last]<<containerBits + uint64(rba.containers[last].maximum())
+	index, _ := getIndexAndRemainder(highest)
+	return (index + 1) * s
+}
+
+// Equals returns a bool indicating if the provided bit array
+// equals this bitarray.
+func (rba *roaringBitArray) Equals(other BitArray) bool {
+	iter, otherIter := rba.Blocks(), other.Blocks()
+	for {
+		selfIndex, selfBlock, selfOk := nextNonEmptyBlock(iter)
+		otherIndex, otherBlock, otherOk := nextNonEmptyBlock(otherIter)
+		if !selfOk || !otherOk {
+			return selfOk == otherOk
+		}
+
+		if selfIndex != otherIndex || !selfBlock.equals(otherBlock) {
+			return false
+		}
+	}
+}
+
+// Intersects returns a bool indicating if the provided bit array
+// intersects with this bitarray.
+func (rba *roaringBitArray) Intersects(other BitArray) bool {
+	for iter := other.Blocks(); iter.Next(); {
+		otherIndex, otherBlock := iter.Value()
+		if otherBlock == 0 {
+			continue
+		}
+
+		if !rba.blockAt(otherIndex).intersects(otherBlock) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Or will perform a bitwise or operation with the provided bitarray and
+// return a new result bitarray.
+func (rba *roaringBitArray) Or(other BitArray) BitArray {
+	return orRoaringWithRoaringBitArray(rba, toRoaringBitArray(other))
+}
+
+// And will perform a bitwise and operation with the provided bitarray and
+// return a new result bitarray.
+func (rba *roaringBitArray) And(other BitArray) BitArray {
+	return andRoaringWithRoaringBitArray(rba, toRoaringBitArray(other))
+}
+
+// Nand will return the result of doing a bitwise and not of the bit array
+// with the other bit array on each block.
+func (rba *roaringBitArray) Nand(other BitArray) BitArray {
+	return nandRoaringWithRoaringBitArray(rba, toRoaringBitArray(other))
+}
+
+// Xor will perform a bitwise xor operation with the provided bitarray and
+// return a new result bitarray.
+func (rba *roaringBitArray) Xor(other BitArray) BitArray {
+	return xorRoaringWithRoaringBitArray(rba, toRoaringBitArray(other))
+}
+
+// AndNot is equivalent to Nand.
+func (rba *roaringBitArray) AndNot(other BitArray) BitArray {
+	return rba.Nand(other)
+}
+
+// OrInPlace will perform a bitwise or operation with the provided
+// bitarray and store the result in this bitarray.
+func (rba *roaringBitArray) OrInPlace(other BitArray) {
+	*rba = *rba.merge(toRoaringBitArray(other), block.or, true, true)
+}
+
+// AndInPlace will perform a bitwise and operation with the provided
+// bitarray and store the result in this bitarray.
+func (rba *roaringBitArray) AndInPlace(other BitArray) {
+	*rba = *rba.merge(toRoaringBitArray(other), block.and, false, false)
+}
+
+// NandInPlace will perform a bitwise nand operation with the provided
+// bitarray and store the result in this bitarray.
+func (rba *roaringBitArray) NandInPlace(other BitArray) {
+	*rba = *rba.merge(toRoaringBitArray(other), block.nand, true, false)
+}
+
+// XorInPlace will perform a bitwise xor operation with the provided
+// bitarray and store the result in this bitarray.
+func (rba *roaringBitArray) XorInPlace(other BitArray) {
+	*rba = *rba.merge(toRoaringBitArray(other), block.xor, true, true)
+}
+
+// ToNums converts this roaring bitarray to a list of numbers contained
+// within it.
+func (rba *roaringBitArray) ToNums() []uint64 {
+	if len(rba.keys) == 0 {
+		return nil
+	}
+
+	nums := make([]uint64, 0, rba.cardinality())
+	for i, key := range rba.keys {
+		rba.containers[i].toNums(key<<containerBits, &nums)
+	}
+
+	return nums
+}
+
+// IsEmpty checks to see if any values are set on the bitarray.
+func (rba *roaringBitArray) IsEmpty() bool {
+	// empty containers are always removed so no keys means no values
+	return len(rba.keys) == 0
+}
+
+func (rba *roaringBitArray) cardinality() int {
+	card := 0
+	for _, c := range rba.containers {
+		card += c.cardinality()
+	}
+
+	return card
+}
+
+// blockAt returns the block at the provided block index.
+func (rba *roaringBitArray) blockAt(index uint64) block {
+	key := index / containerBlocks
+	i := rba.keys.get(key)
+	if i == -1 {
+		return 0
+	}
+
+	return rba.containers[i].blockAt(index % containerBlocks)
+}
+
+func (rba *roaringBitArray) copy() *roaringBitArray {
+	keys := make(uintSlice, len(rba.keys))
+	copy(keys, rba.keys)
+	containers := make([]container, len(rba.containers))
+	for i, c := range rba.containers {
+		containers[i] = c.clone()
+	}
+
+	return &roaringBitArray{
+		keys:       keys,
+		containers: containers,
+	}
+}
+
+// merge walks the containers of both bit arrays in key order and builds
+// a new bit array.  Containers that exist under the same key in both
+// arrays are combined block by block with op.  Containers only found in
+// this array or the other array are copied into the result if keepSelf
+// or keepOther, respectively, are set.
+func (rba *roaringBitArray) merge(other *roaringBitArray,
+	op func(block, block) block, keepSelf, keepOther bool) *roaringBitArray {
+
+	result := newRoaringBitArray()
+	selfBlocks := make([]block, containerBlocks)
+	otherBlocks := make([]block, containerBlocks)
+	selfIndex, otherIndex := 0, 0
+	for selfIndex < len(rba.keys) || otherIndex < len(other.keys) {
+		switch {
+		case otherIndex == len(other.keys) ||
+			(selfIndex < len(rba.keys) && rba.keys[selfIndex] < other.keys[otherIndex]):
+			if keepSelf {
+				result.keys = append(result.keys, rba.keys[selfIndex])
+				result.containers = append(result.containers, rba.containers[selfIndex].clone())
+			}
+			selfIndex++
+		case selfIndex == len(rba.keys) || other.keys[otherIndex] < rba.keys[selfIndex]:
+			if keepOther {
+				result.keys = append(result.keys, other.keys[otherIndex])
+				result.containers = append(result.containers, other.containers[otherIndex].clone())
+			}
+			otherIndex++
+		default:
+			for i := range selfBlocks {
+				selfBlocks[i], otherBlocks[i] = 0, 0
+			}
+			rba.containers[selfIndex].fill(selfBlocks)
+			other.containers[otherIndex].fill(otherBlocks)
+			for i := range selfBlocks {
+				selfBlocks[i] = op(selfBlocks[i], otherBlocks[i])
+			}
+
+			if c := containerFromBlocks(selfBlocks); c != nil {
+				result.keys = append(result.keys, rba.keys[selfIndex])
+				result.containers = append(result.containers, c)
+			}
+			selfIndex++
+			otherIndex++
+		}
+	}
+
+	return result
+}
+
+// nextNonEmptyBlock advances the iterator to the next block with any
+// bits set.  The returned bool is false once the iterator is exhausted.
+func nextNonEmptyBlock(iter Iterator) (uint64, block, bool) {
+	for iter.Next() {
+		index, b := iter.Value()
+		if b != 0 {
+			return index, b, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// toRoaringBitArray returns the provided bit array as a roaring bit
+// array, converting it if necessary.
+func toRoaringBitArray(ba BitArray) *roaringBitArray {
+	if rba, ok := ba.(*roaringBitArray); ok {
+		return rba
+	}
+
+	return newRoaringBitArrayFromIterator(ba.Blocks())
+}
+
+// newRoaringBitArrayFromIterator builds a roaring bit array from the
+// blocks yielded by the provided iterator.  Blocks must be yielded in
+// ascending index order.
+func newRoaringBitArrayFromIterator(iter Iterator) *roaringBitArray {
+	rba := newRoaringBitArray()
+	bs := make([]block, containerBlocks)
+	key, dirty := uint64(0), false
+	flush := func() {
+		if c := containerFromBlocks(bs); c != nil {
+			rba.keys = append(rba.keys, key)
+			rba.containers = append(rba.containers, c)
+		}
+		for i := range bs {
+			bs[i] = 0
+		}
+		dirty = false
+	}
+
+	for iter.Next() {
+		index, b := iter.Value()
+		if b == 0 {
+			continue
+		}
+
+		if dirty && index/containerBlocks != key {
+			flush()
+		}
+		key = index / containerBlocks
+		bs[index%containerBlocks] = b
+		dirty = true
+	}
+
+	if dirty {
+		flush()
+	}
+
+	return rba
+}
+
+func newRoaringBitArray() *roaringBitArray {
+	return &roaringBitArray{}
+}
+
+// NewRoaringBitArray will create a compressed bit array that picks the
+// most compact representation for every 2^16 chunk of positions.  It
+// remains small for sparse data like a sparse bit array while keeping
+// dense runs of set bits fast to operate on.
+func NewRoaringBitArray() BitArray {
+	return newRoaringBitArray()
+}
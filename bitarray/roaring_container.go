@@ -0,0 +1,458 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import (
+	"math/bits"
+	"sort"
+)
+
+const (
+	// containerBits is the number of low bits of a position stored
+	// inside of a container.  The remaining high bits form the key.
+	containerBits = 16
+	// containerSize is the number of positions covered by a single
+	// container.
+	containerSize = 1 << containerBits
+	// containerBlocks is the number of blocks required to represent
+	// a container as a bitmap.
+	containerBlocks = containerSize / s
+	// arrayMaxSize is the largest cardinality stored as a sorted array.
+	// Beyond this a bitmap always takes less memory.
+	arrayMaxSize = 4096
+	// bitmapBytes is the memory footprint of a bitmap container.
+	bitmapBytes = containerSize / 8
+)
+
+// container stores the low 16 bits of every position that shares
+// the same high bits in a roaring bit array.  Mutating methods return
+// the container that should replace the receiver as a container may
+// change its representation as it fills or empties.
+type container interface {
+	add(x uint16) container
+	remove(x uint16) container
+	contains(x uint16) bool
+	cardinality() int
+	// maximum returns the highest value in the container.  The result
+	// is undefined for an empty container.
+	maximum() uint16
+	// blockAt returns the block at the given block index, where the
+	// block index is in the range [0, containerBlocks).
+	blockAt(i uint64) block
+	// fill ors this container into the provided bitmap, which must
+	// be containerBlocks long.
+	fill(bs []block)
+	toNums(offset uint64, nums *[]uint64)
+	// rank returns the number of values in the container below x.
+	rank(x uint16) int
+	// selectValue returns the n-th value in the container.
+	selectValue(n int) uint16
+	// nextValue returns the lowest value in the container that is
+	// greater than or equal to x.
+	nextValue(x uint16) (uint16, bool)
+	// prevValue returns the highest value in the container that is
+	// less than or equal to x.
+	prevValue(x uint16) (uint16, bool)
+	clone() container
+}
+
+// arrayContainer stores a sorted list of values and is used for
+// sparse containers.
+type arrayContainer struct {
+	values []uint16
+}
+
+func (ac *arrayContainer) search(x uint16) int {
+	return sort.Search(len(ac.values), func(i int) bool { return ac.values[i] >= x })
+}
+
+func (ac *arrayContainer) add(x uint16) container {
+	i := ac.search(x)
+	if i < len(ac.values) && ac.values[i] == x {
+		return ac
+	}
+
+	ac.values = append(ac.values, 0)
+	copy(ac.values[i+1:], ac.values[i:])
+	ac.values[i] = x
+	if len(ac.values) <= arrayMaxSize {
+		return ac
+	}
+
+	bs := make([]block, containerBlocks)
+	ac.fill(bs)
+	return containerFromBlocks(bs)
+}
+
+func (ac *arrayContainer) remove(x uint16) container {
+	i := ac.search(x)
+	if i == len(ac.values) || ac.values[i] != x {
+		return ac
+	}
+
+	copy(ac.values[i:], ac.values[i+1:])
+	ac.values = ac.values[:len(ac.values)-1]
+
+	// a removal can leave the values in so few runs that a run
+	// container takes less memory
+	runs := 0
+	for j, x := range ac.values {
+		if j == 0 || ac.values[j-1]+1 != x {
+			runs++
+		}
+	}
+	if runs*2 >= len(ac.values) {
+		return ac
+	}
+
+	bs := make([]block, containerBlocks)
+	ac.fill(bs)
+	return containerFromBlocks(bs)
+}
+
+func (ac *arrayContainer) contains(x uint16) bool {
+	i := ac.search(x)
+	return i < len(ac.values) && ac.values[i] == x
+}
+
+func (ac *arrayContainer) cardinality() int {
+	return len(ac.values)
+}
+
+func (ac *arrayContainer) maximum() uint16 {
+	return ac.values[len(ac.values)-1]
+}
+
+func (ac *arrayContainer) blockAt(i uint64) block {
+	var b block
+	for j := ac.search(uint16(i * s)); j < len(ac.values); j++ {
+		index, position := getIndexAndRemainder(uint64(ac.values[j]))
+		if index != i {
+			break
+		}
+		b = b.insert(position)
+	}
+
+	return b
+}
+
+func (ac *arrayContainer) fill(bs []block) {
+	for _, x := range ac.values {
+		index, position := getIndexAndRemainder(uint64(x))
+		bs[index] = bs[index].insert(position)
+	}
+}
+
+func (ac *arrayContainer) toNums(offset uint64, nums *[]uint64) {
+	for _, x := range ac.values {
+		*nums = append(*nums, offset+uint64(x))
+	}
+}
+
+func (ac *arrayContainer) clone() container {
+	values := make([]uint16, len(ac.values))
+	copy(values, ac.values)
+	return &arrayContainer{values: values}
+}
+
+// bitmapContainer stores the container as a flat bitmap and is used
+// for dense containers without long runs.
+type bitmapContainer struct {
+	blocks []block
+	card   int
+}
+
+func (bc *bitmapContainer) add(x uint16) container {
+	index, position := getIndexAndRemainder(uint64(x))
+	if bc.blocks[index].get(position) {
+		return bc
+	}
+
+	bc.blocks[index] = bc.blocks[index].insert(position)
+	bc.card++
+	if bc.card == containerSize {
+		return &runContainer{runs: []run{{start: 0, last: containerSize - 1}}}
+	}
+
+	return bc
+}
+
+func (bc *bitmapContainer) remove(x uint16) container {
+	index, position := getIndexAndRemainder(uint64(x))
+	if !bc.blocks[index].get(position) {
+		return bc
+	}
+
+	bc.blocks[index] = bc.blocks[index].remove(position)
+	bc.card--
+	if bc.card > arrayMaxSize {
+		return bc
+	}
+
+	values := make([]uint16, 0, bc.card)
+	for i, b := range bc.blocks {
+		for b != 0 {
+			values = append(values, uint16(uint64(i)*s)+uint16(bits.TrailingZeros64(uint64(b))))
+			b &= b - 1
+		}
+	}
+
+	return &arrayContainer{values: values}
+}
+
+func (bc *bitmapContainer) contains(x uint16) bool {
+	index, position := getIndexAndRemainder(uint64(x))
+	return bc.blocks[index].get(position)
+}
+
+func (bc *bitmapContainer) cardinality() int {
+	return bc.card
+}
+
+func (bc *bitmapContainer) maximum() uint16 {
+	for i := len(bc.blocks) - 1; i >= 0; i-- {
+		if bc.blocks[i] != 0 {
+			return uint16(uint64(i)*s + bc.blocks[i].findLeftPosition())
+		}
+	}
+
+	return 0
+}
+
+func (bc *bitmapContainer) blockAt(i uint64) block {
+	return bc.blocks[i]
+}
+
+func (bc *bitmapContainer) fill(bs []block) {
+	for i, b := range bc.blocks {
+		bs[i] |= b
+	}
+}
+
+func (bc *bitmapContainer) toNums(offset uint64, nums *[]uint64) {
+	for i, b := range bc.blocks {
+		if b != 0 {
+			b.toNums(offset+uint64(i)*s, nums)
+		}
+	}
+}
+
+func (bc *bitmapContainer) clone() container {
+	blocks := make([]block, len(bc.blocks))
+	copy(blocks, bc.blocks)
+	return &bitmapContainer{blocks: blocks, card: bc.card}
+}
+
+// run is an inclusive range of set values in a run container.
+type run struct {
+	start, last uint16
+}
+
+// runContainer stores the container as a list of sorted, non-adjacent
+// runs and is used for containers made up of long stretches of set bits.
+type runContainer struct {
+	runs []run
+}
+
+// search returns the index of the first run whose last value is
+// greater than or equal to x.
+func (rc *runContainer) search(x uint16) int {
+	return sort.Search(len(rc.runs), func(i int) bool { return rc.runs[i].last >= x })
+}
+
+func (rc *runContainer) add(x uint16) container {
+	i := rc.search(x)
+	if i < len(rc.runs) && rc.runs[i].start <= x {
+		return rc
+	}
+
+	mergesLeft := i > 0 && rc.runs[i-1].last+1 == x
+	mergesRight := i < len(rc.runs) && rc.runs[i].start-1 == x
+	switch {
+	case mergesLeft && mergesRight:
+		rc.runs[i-1].last = rc.runs[i].last
+		copy(rc.runs[i:], rc.runs[i+1:])
+		rc.runs = rc.runs[:len(rc.runs)-1]
+	case mergesLeft:
+		rc.runs[i-1].last = x
+	case mergesRight:
+		rc.runs[i].start = x
+	default:
+		rc.runs = append(rc.runs, run{})
+		copy(rc.runs[i+1:], rc.runs[i:])
+		rc.runs[i] = run{start: x, last: x}
+		if len(rc.runs)*4 > bitmapBytes {
+			bs := make([]block, containerBlocks)
+			rc.fill(bs)
+			return containerFromBlocks(bs)
+		}
+	}
+
+	return rc
+}
+
+func (rc *runContainer) remove(x uint16) container {
+	i := rc.search(x)
+	if i == len(rc.runs) || rc.runs[i].start > x {
+		return rc
+	}
+
+	r := rc.runs[i]
+	switch {
+	case r.start == r.last:
+		copy(rc.runs[i:], rc.runs[i+1:])
+		rc.runs = rc.runs[:len(rc.runs)-1]
+	case x == r.start:
+		rc.runs[i].start++
+	case x == r.last:
+		rc.runs[i].last--
+	default:
+		rc.runs = append(rc.runs, run{})
+		copy(rc.runs[i+1:], rc.runs[i:])
+		rc.runs[i].last = x - 1
+		rc.runs[i+1].start = x + 1
+	}
+
+	// splitting and shortening runs can leave a bitmap or array
+	// cheaper, the same choice containerFromBlocks makes
+	card := rc.cardinality()
+	if card == 0 || (len(rc.runs)*4 < bitmapBytes && len(rc.runs)*2 < card) {
+		return rc
+	}
+
+	bs := make([]block, containerBlocks)
+	rc.fill(bs)
+	return containerFromBlocks(bs)
+}
+
+func (rc *runContainer) contains(x uint16) bool {
+	i := rc.search(x)
+	return i < len(rc.runs) && rc.runs[i].start <= x
+}
+
+func (rc *runContainer) cardinality() int {
+	card := 0
+	for _, r := range rc.runs {
+		card += int(r.last-r.start) + 1
+	}
+
+	return card
+}
+
+func (rc *runContainer) maximum() uint16 {
+	return rc.runs[len(rc.runs)-1].last
+}
+
+func (rc *runContainer) blockAt(i uint64) block {
+	bs := make([]block, 1)
+	lo, hi := i*s, i*s+s-1
+	for j := rc.search(uint16(lo)); j < len(rc.runs); j++ {
+		r := rc.runs[j]
+		if uint64(r.start) > hi {
+			break
+		}
+		setBlockRange(bs, maxUint64(uint64(r.start), lo)-lo, minUint64(uint64(r.last), hi)-lo)
+	}
+
+	return bs[0]
+}
+
+func (rc *runContainer) fill(bs []block) {
+	for _, r := range rc.runs {
+		setBlockRange(bs, uint64(r.start), uint64(r.last))
+	}
+}
+
+func (rc *runContainer) toNums(offset uint64, nums *[]uint64) {
+	for _, r := range rc.runs {
+		for x := uint64(r.start); x <= uint64(r.last); x++ {
+			*nums = append(*nums, offset+x)
+		}
+	}
+}
+
+func (rc *runContainer) clone() container {
+	runs := make([]run, len(rc.runs))
+	copy(runs, rc.runs)
+	return &runContainer{runs: runs}
+}
+
+// setBlockRange sets every bit in the inclusive range [lo, hi] of the
+// provided blocks.
+func setBlockRange(bs []block, lo, hi uint64) {
+	loIndex, loPosition := getIndexAndRemainder(lo)
+	hiIndex, hiPosition := getIndexAndRemainder(hi)
+	if loIndex == hiIndex {
+		bs[loIndex] |= (maximumBlock >> (s - 1 - hiPosition)) & (maximumBlock << loPosition)
+		return
+	}
+
+	bs[loIndex] |= maximumBlock << loPosition
+	for i := loIndex + 1; i < hiIndex; i++ {
+		bs[i] = maximumBlock
+	}
+	bs[hiIndex] |= maximumBlock >> (s - 1 - hiPosition)
+}
+
+// containerFromBlocks returns the smallest container representing the
+// provided bitmap or nil if the bitmap is empty.  The bitmap is copied
+// so the caller is free to reuse it.
+func containerFromBlocks(bs []block) container {
+	card, runs := 0, 0
+	var carry block
+	for _, b := range bs {
+		card += bits.OnesCount64(uint64(b))
+		// a run starts at every set bit whose lower neighbor is unset
+		runs += bits.OnesCount64(uint64(b &^ (b<<1 | carry)))
+		carry = b >> (s - 1)
+	}
+
+	switch {
+	case card == 0:
+		return nil
+	case runs*4 < bitmapBytes && runs*2 < card:
+		rs := make([]run, 0, runs)
+		for i := uint64(0); i < containerSize; i++ {
+			index, position := getIndexAndRemainder(i)
+			if !bs[index].get(position) {
+				continue
+			}
+
+			if len(rs) > 0 && uint64(rs[len(rs)-1].last)+1 == i {
+				rs[len(rs)-1].last = uint16(i)
+			} else {
+				rs = append(rs, run{start: uint16(i), last: uint16(i)})
+			}
+		}
+
+		return &runContainer{runs: rs}
+	case card <= arrayMaxSize:
+		values := make([]uint16, 0, card)
+		for i, b := range bs {
+			for b != 0 {
+				values = append(values, uint16(uint64(i)*s)+uint16(bits.TrailingZeros64(uint64(b))))
+				b &= b - 1
+			}
+		}
+
+		return &arrayContainer{values: values}
+	default:
+		blocks := make([]block, containerBlocks)
+		copy(blocks, bs)
+		return &bitmapContainer{blocks: blocks, card: card}
+	}
+}
@@ -0,0 +1,389 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoaringGetSetClearBit(t *testing.T) {
+	rba := newRoaringBitArray()
+	assert.True(t, rba.IsEmpty())
+
+	nums := []uint64{0, 5, 63, 64, containerSize - 1, containerSize, 1 << 40}
+	for _, num := range nums {
+		assert.Nil(t, rba.SetBit(num))
+	}
+
+	for _, num := range nums {
+		checkBit(t, rba, num, true)
+	}
+	checkBit(t, rba, 6, false)
+	checkBit(t, rba, containerSize+1, false)
+	assert.False(t, rba.IsEmpty())
+	assert.Len(t, rba.keys, 3)
+	assert.Equal(t, nums, rba.ToNums())
+
+	for _, num := range nums {
+		assert.Nil(t, rba.ClearBit(num))
+	}
+	assert.True(t, rba.IsEmpty())
+	assert.Len(t, rba.containers, 0)
+}
+
+func TestRoaringContainerConversions(t *testing.T) {
+	rba := newRoaringBitArray()
+	for i := uint64(0); i < arrayMaxSize; i++ {
+		rba.SetBit(i * 2)
+	}
+	assert.IsType(t, &arrayContainer{}, rba.containers[0])
+
+	rba.SetBit(1)
+	assert.IsType(t, &bitmapContainer{}, rba.containers[0])
+	assert.Equal(t, arrayMaxSize+1, rba.cardinality())
+
+	rba.ClearBit(1)
+	assert.IsType(t, &arrayContainer{}, rba.containers[0])
+
+	rba.Reset()
+	for i := uint64(0); i < containerSize; i++ {
+		rba.SetBit(i)
+	}
+	assert.IsType(t, &runContainer{}, rba.containers[0])
+	assert.Equal(t, containerSize, rba.cardinality())
+
+	rba.ClearBit(100)
+	checkBit(t, rba, 99, true)
+	checkBit(t, rba, 100, false)
+	checkBit(t, rba, 101, true)
+	assert.Len(t, rba.containers[0].(*runContainer).runs, 2)
+
+	rba.SetBit(100)
+	assert.Len(t, rba.containers[0].(*runContainer).runs, 1)
+}
+
+func TestRoaringContainersShrink(t *testing.T) {
+	rba := newRoaringBitArray()
+	rba.SetRange(0, containerSize)
+	assert.IsType(t, &runContainer{}, rba.containers[0])
+
+	for i := uint64(0); i < containerSize; i += 2 {
+		rba.ClearBit(i)
+	}
+	assert.IsType(t, &bitmapContainer{}, rba.containers[0])
+	assert.Equal(t, containerSize/2, rba.cardinality())
+
+	for i := uint64(1); i < containerSize-s; i += 2 {
+		rba.ClearBit(i)
+	}
+	assert.IsType(t, &arrayContainer{}, rba.containers[0])
+	assert.Equal(t, int(s/2), rba.cardinality())
+
+	rba.Reset()
+	for i := uint64(0); i < 100; i += 2 {
+		rba.SetBit(i)
+	}
+	for i := uint64(200); i < 300; i++ {
+		rba.SetBit(i)
+	}
+	assert.IsType(t, &arrayContainer{}, rba.containers[0])
+
+	for i := uint64(0); i < 100; i += 2 {
+		rba.ClearBit(i)
+	}
+	assert.IsType(t, &runContainer{}, rba.containers[0])
+	assert.Equal(t, []run{{start: 200, last: 299}}, rba.containers[0].(*runContainer).runs)
+}
+
+func TestRoaringCapacity(t *testing.T) {
+	rba := newRoaringBitArray()
+	assert.Equal(t, uint64(0), rba.Capacity())
+
+	rba.SetBit(s * 2)
+	assert.Equal(t, s*3, rba.Capacity())
+
+	rba.SetBit(containerSize + 1)
+	assert.Equal(t, uint64(containerSize+s), rba.Capacity())
+}
+
+func TestRoaringBlocks(t *testing.T) {
+	rba := newRoaringBitArray()
+	rba.SetBit(3)
+	rba.SetBit(s*5 + 1)
+	rba.SetBit(containerSize + 2)
+
+	indices := []uint64{}
+	blocks := []block{}
+	for iter := rba.Blocks(); iter.Next(); {
+		index, b := iter.Value()
+		indices = append(indices, index)
+		blocks = append(blocks, b)
+	}
+
+	assert.Equal(t, []uint64{0, 5, containerBlocks}, indices)
+	assert.Equal(t, []block{1 << 3, 1 << 1, 1 << 2}, blocks)
+}
+
+func TestRoaringEquals(t *testing.T) {
+	rba := newRoaringBitArray()
+	other := newRoaringBitArray()
+	assert.True(t, rba.Equals(other))
+
+	rba.SetBit(5)
+	assert.False(t, rba.Equals(other))
+
+	other.SetBit(5)
+	assert.True(t, rba.Equals(other))
+
+	dense := newBitArray(100)
+	dense.SetBit(5)
+	sparse := newSparseBitArray()
+	sparse.SetBit(5)
+	assert.True(t, rba.Equals(dense))
+	assert.True(t, rba.Equals(sparse))
+	assert.True(t, dense.Equals(rba))
+	assert.True(t, sparse.Equals(rba))
+
+	rba.SetBit(containerSize)
+	assert.False(t, rba.Equals(dense))
+	assert.False(t, dense.Equals(rba))
+}
+
+func TestRoaringIntersects(t *testing.T) {
+	rba := newRoaringBitArray()
+	other := newRoaringBitArray()
+
+	rba.SetBit(1)
+	rba.SetBit(containerSize + 1)
+	other.SetBit(containerSize + 1)
+	assert.True(t, rba.Intersects(other))
+
+	other.SetBit(2)
+	assert.False(t, rba.Intersects(other))
+
+	dense := newBitArray(containerSize * 2)
+	dense.SetBit(1)
+	dense.SetBit(containerSize + 1)
+	dense.SetBit(10)
+	assert.True(t, dense.Intersects(rba))
+	assert.False(t, rba.Intersects(dense))
+}
+
+func TestRoaringOr(t *testing.T) {
+	rba := newRoaringBitArray()
+	rba.SetBit(1)
+	rba.SetBit(containerSize * 3)
+
+	other := newRoaringBitArray()
+	other.SetBit(2)
+	other.SetBit(containerSize)
+
+	expected := []uint64{1, 2, containerSize, containerSize * 3}
+	assert.Equal(t, expected, rba.Or(other).ToNums())
+
+	dense := newBitArray(containerSize * 2)
+	dense.SetBit(2)
+	dense.SetBit(containerSize)
+	assert.Equal(t, expected, rba.Or(dense).ToNums())
+	assert.Equal(t, expected, dense.Or(rba).ToNums())
+
+	sparse := newSparseBitArray()
+	sparse.SetBit(2)
+	sparse.SetBit(containerSize)
+	assert.Equal(t, expected, rba.Or(sparse).ToNums())
+	assert.Equal(t, expected, sparse.Or(rba).ToNums())
+}
+
+func TestRoaringAnd(t *testing.T) {
+	rba := newRoaringBitArray()
+	other := newRoaringBitArray()
+	for i := uint64(0); i < containerSize*2; i += 3 {
+		rba.SetBit(i)
+	}
+	for i := uint64(0); i < containerSize*2; i += 5 {
+		other.SetBit(i)
+	}
+
+	expected := []uint64{}
+	for i := uint64(0); i < containerSize*2; i += 15 {
+		expected = append(expected, i)
+	}
+	assert.Equal(t, expected, rba.And(other).ToNums())
+
+	dense := newBitArray(containerSize * 2)
+	sparse := newSparseBitArray()
+	for i := uint64(0); i < containerSize*2; i += 5 {
+		dense.SetBit(i)
+		sparse.SetBit(i)
+	}
+	assert.Equal(t, expected, rba.And(dense).ToNums())
+	assert.Equal(t, expected, dense.And(rba).ToNums())
+	assert.Equal(t, expected, rba.And(sparse).ToNums())
+	assert.Equal(t, expected, sparse.And(rba).ToNums())
+
+	rba.Reset()
+	rba.SetBit(containerSize * 5)
+	assert.True(t, rba.And(other).IsEmpty())
+}
+
+func TestRoaringNand(t *testing.T) {
+	rba := newRoaringBitArray()
+	rba.SetBit(1)
+	rba.SetBit(2)
+	rba.SetBit(containerSize * 3)
+
+	other := newRoaringBitArray()
+	other.SetBit(2)
+	other.SetBit(containerSize)
+
+	expected := []uint64{1, containerSize * 3}
+	assert.Equal(t, expected, rba.Nand(other).ToNums())
+
+	dense := newBitArray(containerSize * 4)
+	dense.SetBit(2)
+	dense.SetBit(containerSize)
+	assert.Equal(t, expected, rba.Nand(dense).ToNums())
+	assert.Equal(t, []uint64{containerSize}, dense.Nand(rba).ToNums())
+
+	sparse := newSparseBitArray()
+	sparse.SetBit(2)
+	sparse.SetBit(containerSize)
+	assert.Equal(t, expected, rba.Nand(sparse).ToNums())
+	assert.Equal(t, []uint64{containerSize}, sparse.Nand(rba).ToNums())
+}
+
+func TestRoaringWithZeroCapacityDense(t *testing.T) {
+	dense := newBitArray(s)
+	dense.SetBit(3)
+	empty := map[string]BitArray{
+		"zero":     newBitArray(0),
+		"getRange": dense.GetRange(5, 5),
+	}
+
+	for name, zero := range empty {
+		rba := newRoaringBitArray()
+		assert.True(t, zero.Or(rba).IsEmpty(), name)
+		assert.True(t, rba.Or(zero).IsEmpty(), name)
+		assert.True(t, rba.Equals(zero), name)
+		assert.True(t, zero.Equals(rba), name)
+
+		rba.SetBit(1)
+		rba.SetBit(containerSize)
+		expected := []uint64{1, containerSize}
+		assert.Equal(t, expected, zero.Or(rba).ToNums(), name)
+		assert.Equal(t, expected, rba.Or(zero).ToNums(), name)
+		assert.Equal(t, expected, zero.Xor(rba).ToNums(), name)
+		assert.Equal(t, expected, rba.Xor(zero).ToNums(), name)
+		assert.Equal(t, expected, rba.Nand(zero).ToNums(), name)
+		assert.Empty(t, zero.Nand(rba).ToNums(), name)
+		assert.Empty(t, zero.And(rba).ToNums(), name)
+		assert.Empty(t, rba.And(zero).ToNums(), name)
+		assert.False(t, rba.Equals(zero), name)
+		assert.False(t, zero.Equals(rba), name)
+		assert.True(t, rba.Intersects(zero), name)
+
+		sparse := newSparseBitArray()
+		assert.True(t, sparse.Equals(zero), name)
+		sparse.SetBit(1)
+		assert.Equal(t, []uint64{1}, sparse.Or(zero).ToNums(), name)
+		assert.False(t, sparse.Equals(zero), name)
+
+		rba.OrInPlace(zero)
+		rba.XorInPlace(zero)
+		rba.NandInPlace(zero)
+		assert.Equal(t, expected, rba.ToNums(), name)
+		rba.AndInPlace(zero)
+		assert.True(t, rba.IsEmpty(), name)
+	}
+}
+
+func TestRoaringOpsDoNotMutate(t *testing.T) {
+	rba := newRoaringBitArray()
+	other := newRoaringBitArray()
+	rba.SetBit(1)
+	other.SetBit(containerSize)
+
+	result := rba.Or(other)
+	result.SetBit(2)
+	result.SetBit(containerSize + 1)
+
+	assert.Equal(t, []uint64{1}, rba.ToNums())
+	assert.Equal(t, []uint64{containerSize}, other.ToNums())
+}
+
+func TestContainerFromBlocks(t *testing.T) {
+	bs := make([]block, containerBlocks)
+	assert.Nil(t, containerFromBlocks(bs))
+
+	bs[0] = 1
+	assert.IsType(t, &arrayContainer{}, containerFromBlocks(bs))
+
+	setBlockRange(bs, 100, 20000)
+	c := containerFromBlocks(bs)
+	assert.IsType(t, &runContainer{}, c)
+	assert.Equal(t, []run{{0, 0}, {100, 20000}}, c.(*runContainer).runs)
+
+	for i := range bs {
+		bs[i] = 0x5555555555555555
+	}
+	c = containerFromBlocks(bs)
+	assert.IsType(t, &bitmapContainer{}, c)
+	assert.Equal(t, containerSize/2, c.cardinality())
+}
+
+func TestSetBlockRange(t *testing.T) {
+	bs := make([]block, 3)
+	setBlockRange(bs, 3, 5)
+	assert.Equal(t, block(0x38), bs[0])
+
+	bs = make([]block, 3)
+	setBlockRange(bs, s-1, s*2)
+	assert.Equal(t, []block{1 << (s - 1), maximumBlock, 1}, bs)
+}
+
+func BenchmarkRoaringSetBit(b *testing.B) {
+	numItems := uint64(1000000)
+	rba := newRoaringBitArray()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for j := uint64(0); j < numItems; j += 7 {
+			rba.SetBit(j)
+		}
+	}
+}
+
+func BenchmarkRoaringOrRoaring(b *testing.B) {
+	numItems := uint64(1000000)
+	rba := newRoaringBitArray()
+	other := newRoaringBitArray()
+	for i := uint64(0); i < numItems; i += 3 {
+		rba.SetBit(i)
+	}
+	for i := uint64(0); i < numItems; i += 5 {
+		other.SetBit(i)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rba.Or(other)
+	}
+}
@@ -232,6 +232,10 @@ func (sba *sparseBitArray) Or(other BitArray) BitArray {
 		return orSparseWithSparseBitArray(sba, ba)
 	}
 
+	if rba, ok := other.(*roaringBitArray); ok {
+		return orRoaringWithRoaringBitArray(toRoaringBitArray(sba), rba)
+	}
+
 	return orSparseWithDenseBitArray(sba, other.(*bitArray))
 }
 
@@ -242,6 +246,10 @@ func (sba *sparseBitArray) And(other BitArray) BitArray {
 		return andSparseWithSparseBitArray(sba, ba)
 	}
 
+	if rba, ok := other.(*roaringBitArray); ok {
+		return andRoaringWithRoaringBitArray(toRoaringBitArray(sba), rba)
+	}
+
 	return andSparseWithDenseBitArray(sba, other.(*bitArray))
 }
 
@@ -252,9 +260,56 @@ func (sba *sparseBitArray) Nand(other BitArray) BitArray {
 		return nandSparseWithSparseBitArray(sba, ba)
 	}
 
+	if rba, ok := other.(*roaringBitArray); ok {
+		return nandRoaringWithRoaringBitArray(toRoaringBitArray(sba), rba)
+	}
+
 	return nandSparseWithDenseBitArray(sba, other.(*bitArray))
 }
 
+// Xor will perform a bitwise xor operation with the provided bitarray and
+// return a new result bitarray.
+func (sba *sparseBitArray) Xor(other BitArray) BitArray {
+	if ba, ok := other.(*sparseBitArray); ok {
+		return xorSparseWithSparseBitArray(sba, ba)
+	}
+
+	if rba, ok := other.(*roaringBitArray); ok {
+		return xorRoaringWithRoaringBitArray(toRoaringBitArray(sba), rba)
+	}
+
+	return xorSparseWithDenseBitArray(sba, other.(*bitArray))
+}
+
+// AndNot is equivalent to Nand.
+func (sba *sparseBitArray) AndNot(other BitArray) BitArray {
+	return sba.Nand(other)
+}
+
+// OrInPlace will perform a bitwise or operation with the provided
+// bitarray and store the result in this bitarray.
+func (sba *sparseBitArray) OrInPlace(other BitArray) {
+	sba.mergeInPlace(toBlockSource(other), block.or)
+}
+
+// AndInPlace will perform a bitwise and operation with the provided
+// bitarray and store the result in this bitarray.
+func (sba *sparseBitArray) AndInPlace(other BitArray) {
+	sba.mergeInPlace(toBlockSource(other), block.and)
+}
+
+// NandInPlace will perform a bitwise nand operation with the provided
+// bitarray and store the result in this bitarray.
+func (sba *sparseBitArray) NandInPlace(other BitArray) {
+	sba.mergeInPlace(toBlockSource(other), block.nand)
+}
+
+// XorInPlace will perform a bitwise xor operation with the provided
+// bitarray and store the result in this bitarray.
+func (sba *sparseBitArray) XorInPlace(other BitArray) {
+	sba.mergeInPlace(toBlockSource(other), block.xor)
+}
+
 func (sba *sparseBitArray) IsEmpty() bool {
 	// This works because the and, nand and delete functions only
 	// keep values that have a non-zero block.
@@ -0,0 +1,650 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/bits"
+)
+
+// Format is a serialization format understood by the Encoder.
+type Format uint8
+
+const (
+	// NativeFormat is a versioned and checksummed format that
+	// preserves the kind of bit array that was encoded.
+	NativeFormat Format = iota
+	// RoaringFormatSpec is the portable format described at
+	// https://github.com/RoaringBitmap/RoaringFormatSpec and understood
+	// by the Java, C and Go roaring libraries.  It can only hold
+	// positions below 2^32 and always decodes to a roaring bit array.
+	RoaringFormatSpec
+)
+
+// formatVersion is the version of the native format written by
+// the Encoder.
+const formatVersion = 1
+
+// nativeMagic starts every bit array in the native format.  The first
+// byte can't be confused with the identifier byte of the format
+// written by Marshal or with a roaring cookie.
+var nativeMagic = [4]byte{0x89, 'B', 'A', 'R'}
+
+const (
+	roaringCookie          = 12347
+	roaringCookieNoRuns    = 12346
+	roaringNoOffsetMinimum = 4
+)
+
+const (
+	containerTypeArray uint8 = iota
+	containerTypeBitmap
+	containerTypeRun
+)
+
+var (
+	// ErrChecksum is returned by the Decoder when the checksum of the
+	// decoded bit array doesn't match the stored checksum.
+	ErrChecksum = errors.New("bitarray: checksum mismatch")
+	// ErrUnsupportedVersion is returned by the Decoder when the bit
+	// array was written by a newer version of the native format.
+	ErrUnsupportedVersion = errors.New("bitarray: unsupported format version")
+	// ErrUnsupportedPosition is returned by the Encoder when a bit
+	// array can't be represented in the requested format.
+	ErrUnsupportedPosition = errors.New("bitarray: position too large for format")
+	// ErrCorrupt is returned by the Decoder when the decoded bit array
+	// is malformed, such as a roaring container that is empty or out of
+	// order.
+	ErrCorrupt = errors.New("bitarray: corrupt encoding")
+)
+
+// EncoderOption configures an Encoder.
+type EncoderOption func(*Encoder)
+
+// EncodingFormat sets the format written by the Encoder.  The default
+// is NativeFormat.
+func EncodingFormat(format Format) EncoderOption {
+	return func(e *Encoder) {
+		e.format = format
+	}
+}
+
+// Encoder writes bit arrays to an output stream.
+type Encoder struct {
+	w      *bufio.Writer
+	format Format
+}
+
+// NewEncoder returns an encoder that writes to w.
+func NewEncoder(w io.Writer, options ...EncoderOption) *Encoder {
+	e := &Encoder{w: bufio.NewWriter(w)}
+	for _, option := range options {
+		option(e)
+	}
+
+	return e
+}
+
+// Encode writes the provided bit array to the stream.
+func (e *Encoder) Encode(ba BitArray) error {
+	var err error
+	if e.format == RoaringFormatSpec {
+		err = encodeRoaringFormatSpec(e.w, toRoaringBitArray(ba))
+	} else {
+		err = encodeNative(e.w, ba)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return e.w.Flush()
+}
+
+// Decoder reads bit arrays from an input stream.  Bit arrays in the
+// native format, the RoaringFormatSpec and the format written by Marshal
+// are recognized.  The Marshal format has no length, so it consumes the
+// remainder of the stream.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a decoder that reads from r.  The decoder may
+// buffer data read from r beyond the bit arrays requested.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next bit array from the stream.
+func (d *Decoder) Decode() (BitArray, error) {
+	header, err := d.r.Peek(4)
+	if err != nil {
+		if err == io.EOF && len(header) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	switch {
+	case [4]byte{header[0], header[1], header[2], header[3]} == nativeMagic:
+		return decodeNative(d.r)
+	case binary.LittleEndian.Uint16(header) == roaringCookie,
+		binary.LittleEndian.Uint32(header) == roaringCookieNoRuns:
+		return decodeRoaringFormatSpec(d.r)
+	case header[0] == 'B' || header[0] == 'S':
+		input, err := ioutil.ReadAll(d.r)
+		if err != nil {
+			return nil, err
+		}
+		return Unmarshal(input)
+	}
+
+	return nil, errors.New("unrecognized encoding")
+}
+
+// binaryWriter writes little endian values and remembers the first
+// error encountered so callers can check once at the end.
+type binaryWriter struct {
+	w   io.Writer
+	buf [8]byte
+	err error
+}
+
+func (bw *binaryWriter) write(b []byte) {
+	if bw.err == nil {
+		_, bw.err = bw.w.Write(b)
+	}
+}
+
+func (bw *binaryWriter) uint8(v uint8) {
+	bw.buf[0] = v
+	bw.write(bw.buf[:1])
+}
+
+func (bw *binaryWriter) uint16(v uint16) {
+	binary.LittleEndian.PutUint16(bw.buf[:2], v)
+	bw.write(bw.buf[:2])
+}
+
+func (bw *binaryWriter) uint32(v uint32) {
+	binary.LittleEndian.PutUint32(bw.buf[:4], v)
+	bw.write(bw.buf[:4])
+}
+
+func (bw *binaryWriter) uint64(v uint64) {
+	binary.LittleEndian.PutUint64(bw.buf[:8], v)
+	bw.write(bw.buf[:8])
+}
+
+// binaryReader reads little endian values and remembers the first
+// error encountered so callers can check once at the end.
+type binaryReader struct {
+	r   io.Reader
+	buf [8]byte
+	err error
+}
+
+func (br *binaryReader) read(n int) []byte {
+	if br.err != nil {
+		return br.buf[:n]
+	}
+
+	if _, err := io.ReadFull(br.r, br.buf[:n]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		br.err = err
+		for i := range br.buf {
+			br.buf[i] = 0
+		}
+	}
+
+	return br.buf[:n]
+}
+
+func (br *binaryReader) uint8() uint8 {
+	return br.read(1)[0]
+}
+
+func (br *binaryReader) uint16() uint16 {
+	return binary.LittleEndian.Uint16(br.read(2))
+}
+
+func (br *binaryReader) uint32() uint32 {
+	return binary.LittleEndian.Uint32(br.read(4))
+}
+
+func (br *binaryReader) uint64() uint64 {
+	return binary.LittleEndian.Uint64(br.read(8))
+}
+
+// boundedCapacity limits preallocation for counts read from untrusted
+// input.  Slices still grow past this if the input really is that large.
+func boundedCapacity(n uint64) int {
+	if n > 1<<16 {
+		return 1 << 16
+	}
+
+	return int(n)
+}
+
+// encodeNative writes the bit array in the native format, which is the
+// magic bytes, a version byte, a byte identifying the kind of bit
+// array, the payload and finally a CRC-32 of everything before it.
+func encodeNative(w io.Writer, ba BitArray) error {
+	crc := crc32.NewIEEE()
+	bw := &binaryWriter{w: io.MultiWriter(w, crc)}
+	bw.write(nativeMagic[:])
+	bw.uint8(formatVersion)
+
+	switch ba := ba.(type) {
+	case *bitArray:
+		bw.uint8('B')
+		bw.uint64(uint64(len(ba.blocks)))
+		for _, b := range ba.blocks {
+			bw.uint64(uint64(b))
+		}
+	case *sparseBitArray:
+		bw.uint8('S')
+		bw.uint64(uint64(len(ba.indices)))
+		for i, index := range ba.indices {
+			bw.uint64(index)
+			bw.uint64(uint64(ba.blocks[i]))
+		}
+	case *roaringBitArray:
+		bw.uint8('R')
+		bw.uint64(uint64(len(ba.keys)))
+		for i, key := range ba.keys {
+			bw.uint64(key)
+			encodeNativeContainer(bw, ba.containers[i])
+		}
+	default:
+		return errors.New("not a valid BitArray")
+	}
+
+	if bw.err != nil {
+		return bw.err
+	}
+
+	bw = &binaryWriter{w: w}
+	bw.uint32(crc.Sum32())
+	return bw.err
+}
+
+func encodeNativeContainer(bw *binaryWriter, c container) {
+	switch c := c.(type) {
+	case *arrayContainer:
+		bw.uint8(containerTypeArray)
+		bw.uint32(uint32(len(c.values)))
+		for _, x := range c.values {
+			bw.uint16(x)
+		}
+	case *bitmapContainer:
+		bw.uint8(containerTypeBitmap)
+		bw.uint32(uint32(c.card))
+		for _, b := range c.blocks {
+			bw.uint64(uint64(b))
+		}
+	case *runContainer:
+		bw.uint8(containerTypeRun)
+		bw.uint32(uint32(len(c.runs)))
+		for _, r := range c.runs {
+			bw.uint16(r.start)
+			bw.uint16(r.last)
+		}
+	}
+}
+
+func decodeNative(r io.Reader) (BitArray, error) {
+	crc := crc32.NewIEEE()
+	br := &binaryReader{r: io.TeeReader(r, crc)}
+	br.read(len(nativeMagic))
+	version := br.uint8()
+	if br.err == nil && version != formatVersion {
+		return nil, ErrUnsupportedVersion
+	}
+
+	var ba BitArray
+	switch kind := br.uint8(); kind {
+	case 'B':
+		ba = decodeNativeBitArray(br)
+	case 'S':
+		ba = decodeNativeSparseBitArray(br)
+	case 'R':
+		ba = decodeNativeRoaringBitArray(br)
+	default:
+		if br.err == nil {
+			return nil, errors.New("unrecognized encoding")
+		}
+	}
+
+	if br.err != nil {
+		return nil, br.err
+	}
+
+	sum := crc.Sum32()
+	if (&binaryReader{r: r}).uint32() != sum {
+		return nil, ErrChecksum
+	}
+
+	return ba, nil
+}
+
+func decodeNativeBitArray(br *binaryReader) BitArray {
+	n := br.uint64()
+	ba := &bitArray{blocks: make([]block, 0, boundedCapacity(n))}
+	for i := uint64(0); i < n && br.err == nil; i++ {
+		ba.blocks = append(ba.blocks, block(br.uint64()))
+	}
+
+	ba.setLowest()
+	ba.setHighest()
+	return ba
+}
+
+func decodeNativeSparseBitArray(br *binaryReader) BitArray {
+	n := br.uint64()
+	sba := &sparseBitArray{
+		indices: make(uintSlice, 0, boundedCapacity(n)),
+		blocks:  make(blocks, 0, boundedCapacity(n)),
+	}
+	for i := uint64(0); i < n && br.err == nil; i++ {
+		index := br.uint64()
+		if i > 0 && index <= sba.indices[i-1] && br.err == nil {
+			br.err = ErrCorrupt
+			return nil
+		}
+
+		sba.indices = append(sba.indices, index)
+		sba.blocks = append(sba.blocks, block(br.uint64()))
+	}
+
+	return sba
+}
+
+func decodeNativeRoaringBitArray(br *binaryReader) BitArray {
+	n := br.uint64()
+	rba := newRoaringBitArray()
+	for i := uint64(0); i < n && br.err == nil; i++ {
+		key := br.uint64()
+		kind := br.uint8()
+		count := br.uint32()
+		if br.err != nil {
+			return nil
+		}
+
+		// keys must be ascending and leave room for the container's
+		// positions, and counts no larger than a valid container's
+		if (i > 0 && key <= rba.keys[i-1]) || key > math.MaxUint64>>containerBits ||
+			(kind == containerTypeArray && count > arrayMaxSize) ||
+			(kind == containerTypeRun && count > containerSize/2) {
+			br.err = ErrCorrupt
+			return nil
+		}
+
+		var c container
+		switch kind {
+		case containerTypeArray:
+			c = decodeArrayContainer(br, int(count))
+		case containerTypeBitmap:
+			c = decodeBitmapContainer(br, int(count))
+		case containerTypeRun:
+			runs := make([]run, 0, count)
+			for j := uint32(0); j < count && br.err == nil; j++ {
+				runs = append(runs, run{start: br.uint16(), last: br.uint16()})
+			}
+			c = &runContainer{runs: runs}
+		default:
+			if br.err == nil {
+				br.err = errors.New("unrecognized container")
+			}
+			return nil
+		}
+
+		if br.err == nil && !validContainer(c) {
+			br.err = ErrCorrupt
+		}
+		rba.keys = append(rba.keys, key)
+		rba.containers = append(rba.containers, c)
+	}
+
+	return rba
+}
+
+// validContainer reports whether a decoded container holds at least one
+// value and keeps the invariants the container methods rely on.
+func validContainer(c container) bool {
+	switch c := c.(type) {
+	case *arrayContainer:
+		if len(c.values) == 0 || len(c.values) > arrayMaxSize {
+			return false
+		}
+		for i := 1; i < len(c.values); i++ {
+			if c.values[i] <= c.values[i-1] {
+				return false
+			}
+		}
+	case *bitmapContainer:
+		card := 0
+		for _, b := range c.blocks {
+			card += bits.OnesCount64(uint64(b))
+		}
+		return card > 0 && card == c.card
+	case *runContainer:
+		if len(c.runs) == 0 {
+			return false
+		}
+		for i, r := range c.runs {
+			if r.last < r.start {
+				return false
+			}
+			// runs are sorted with at least one unset value between them
+			if i > 0 && uint32(r.start) <= uint32(c.runs[i-1].last)+1 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func decodeArrayContainer(br *binaryReader, card int) container {
+	values := make([]uint16, 0, card)
+	for i := 0; i < card && br.err == nil; i++ {
+		values = append(values, br.uint16())
+	}
+
+	return &arrayContainer{values: values}
+}
+
+func decodeBitmapContainer(br *binaryReader, card int) container {
+	blocks := make([]block, containerBlocks)
+	for i := range blocks {
+		blocks[i] = block(br.uint64())
+	}
+
+	return &bitmapContainer{blocks: blocks, card: card}
+}
+
+// encodeRoaringFormatSpec writes the roaring bit array according to
+// the RoaringFormatSpec.
+func encodeRoaringFormatSpec(w io.Writer, rba *roaringBitArray) error {
+	size := len(rba.keys)
+	if size > 0 && rba.keys[size-1] >= containerSize {
+		return ErrUnsupportedPosition
+	}
+
+	hasRuns := false
+	for _, c := range rba.containers {
+		if _, ok := c.(*runContainer); ok {
+			hasRuns = true
+			break
+		}
+	}
+
+	bw := &binaryWriter{w: w}
+	headerSize := 8
+	if hasRuns {
+		bw.uint32(uint32(roaringCookie) | uint32(size-1)<<16)
+		runFlags := make([]byte, (size+7)/8)
+		for i, c := range rba.containers {
+			if _, ok := c.(*runContainer); ok {
+				runFlags[i/8] |= 1 << (uint(i) % 8)
+			}
+		}
+		bw.write(runFlags)
+		headerSize = 4 + len(runFlags)
+	} else {
+		bw.uint32(roaringCookieNoRuns)
+		bw.uint32(uint32(size))
+	}
+
+	for i, key := range rba.keys {
+		bw.uint16(uint16(key))
+		bw.uint16(uint16(rba.containers[i].cardinality() - 1))
+	}
+	headerSize += 4 * size
+
+	if !hasRuns || size >= roaringNoOffsetMinimum {
+		offset := uint32(headerSize + 4*size)
+		for _, c := range rba.containers {
+			bw.uint32(offset)
+			offset += uint32(specContainerSize(c))
+		}
+	}
+
+	bs := make([]block, containerBlocks)
+	for _, c := range rba.containers {
+		if rc, ok := c.(*runContainer); ok {
+			bw.uint16(uint16(len(rc.runs)))
+			for _, r := range rc.runs {
+				bw.uint16(r.start)
+				bw.uint16(r.last - r.start)
+			}
+			continue
+		}
+
+		for i := range bs {
+			bs[i] = 0
+		}
+		c.fill(bs)
+		if c.cardinality() <= arrayMaxSize {
+			for iter := newSetBitIterator(newBlocksIterator(bs)); iter.Next(); {
+				bw.uint16(uint16(iter.Value()))
+			}
+		} else {
+			for _, b := range bs {
+				bw.uint64(uint64(b))
+			}
+		}
+	}
+
+	return bw.err
+}
+
+// specContainerSize returns the number of bytes the container takes up
+// in the RoaringFormatSpec.
+func specContainerSize(c container) int {
+	if rc, ok := c.(*runContainer); ok {
+		return 2 + 4*len(rc.runs)
+	}
+
+	if card := c.cardinality(); card <= arrayMaxSize {
+		return 2 * card
+	}
+
+	return bitmapBytes
+}
+
+func decodeRoaringFormatSpec(r io.Reader) (BitArray, error) {
+	br := &binaryReader{r: r}
+	cookie := br.uint32()
+
+	var size int
+	var runFlags []byte
+	if cookie&0xFFFF == roaringCookie {
+		size = int(cookie>>16) + 1
+		runFlags = make([]byte, (size+7)/8)
+		if _, err := io.ReadFull(r, runFlags); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+	} else {
+		size = int(br.uint32())
+	}
+
+	if br.err != nil {
+		return nil, br.err
+	}
+
+	rba := newRoaringBitArray()
+	cards := make([]int, 0, boundedCapacity(uint64(size)))
+	for i := 0; i < size && br.err == nil; i++ {
+		key := uint64(br.uint16())
+		if i > 0 && key <= rba.keys[i-1] && br.err == nil {
+			return nil, ErrCorrupt
+		}
+
+		rba.keys = append(rba.keys, key)
+		cards = append(cards, int(br.uint16())+1)
+	}
+
+	if runFlags == nil || size >= roaringNoOffsetMinimum {
+		// containers are stored contiguously, so the offsets aren't
+		// needed when reading the stream front to back
+		for i := 0; i < size && br.err == nil; i++ {
+			br.uint32()
+		}
+	}
+
+	for i := 0; i < size && br.err == nil; i++ {
+		var c container
+		switch {
+		case runFlags != nil && runFlags[i/8]&(1<<(uint(i)%8)) != 0:
+			n := int(br.uint16())
+			runs := make([]run, 0, n)
+			for j := 0; j < n && br.err == nil; j++ {
+				start, length := br.uint16(), br.uint16()
+				if uint32(start)+uint32(length) > math.MaxUint16 {
+					return nil, ErrCorrupt
+				}
+				runs = append(runs, run{start: start, last: start + length})
+			}
+			c = &runContainer{runs: runs}
+		case cards[i] <= arrayMaxSize:
+			c = decodeArrayContainer(br, cards[i])
+		default:
+			c = decodeBitmapContainer(br, cards[i])
+		}
+
+		if br.err == nil && (!validContainer(c) || c.cardinality() != cards[i]) {
+			return nil, ErrCorrupt
+		}
+		rba.containers = append(rba.containers, c)
+	}
+
+	if br.err != nil {
+		return nil, br.err
+	}
+
+	return rba, nil
+}
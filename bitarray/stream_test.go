@@ -0,0 +1,326 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecodeNative(t *testing.T) {
+	nums := []uint64{0, 3, 64, 1000, 70000, 1 << 40}
+
+	for name, ba := range constructors(s*2000, nums[:5]) {
+		if name == "sparse" || name == "roaring" {
+			ba.SetBit(nums[5])
+		}
+
+		buf := new(bytes.Buffer)
+		assert.Nil(t, NewEncoder(buf).Encode(ba), name)
+		assert.Equal(t, nativeMagic[:], buf.Bytes()[:4], name)
+
+		result, err := NewDecoder(buf).Decode()
+		assert.Nil(t, err, name)
+		assert.IsType(t, ba, result, name)
+		assert.True(t, ba.Equals(result), name)
+		assert.Equal(t, ba.ToNums(), result.ToNums(), name)
+	}
+}
+
+func TestEncodeDecodeNativeRoaringContainers(t *testing.T) {
+	rba := newRoaringBitArray()
+	rba.SetBit(5)
+	for i := uint64(containerSize); i < containerSize*2; i += 2 {
+		rba.SetBit(i)
+	}
+	rba.SetRange(containerSize*2, containerSize*3)
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, NewEncoder(buf).Encode(rba))
+
+	result, err := NewDecoder(buf).Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, rba, result)
+}
+
+func TestDecodeMultiple(t *testing.T) {
+	dense := newBitArray(100)
+	dense.SetBit(10)
+	rba := newRoaringBitArray()
+	rba.SetBit(20)
+
+	buf := new(bytes.Buffer)
+	e := NewEncoder(buf)
+	assert.Nil(t, e.Encode(dense))
+	assert.Nil(t, e.Encode(rba))
+	assert.Nil(t, NewEncoder(buf, EncodingFormat(RoaringFormatSpec)).Encode(dense))
+
+	d := NewDecoder(buf)
+	result, err := d.Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{10}, result.ToNums())
+
+	result, err = d.Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{20}, result.ToNums())
+
+	result, err = d.Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{10}, result.ToNums())
+
+	_, err = d.Decode()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDecodeChecksumMismatch(t *testing.T) {
+	ba := newSparseBitArray()
+	ba.SetBit(100)
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, NewEncoder(buf).Encode(ba))
+	data := buf.Bytes()
+	data[len(data)-6] ^= 0xFF
+
+	_, err := NewDecoder(bytes.NewReader(data)).Decode()
+	assert.Equal(t, ErrChecksum, err)
+}
+
+func TestDecodeUnsupportedVersion(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.Nil(t, NewEncoder(buf).Encode(newSparseBitArray()))
+	data := buf.Bytes()
+	data[4] = formatVersion + 1
+
+	_, err := NewDecoder(bytes.NewReader(data)).Decode()
+	assert.Equal(t, ErrUnsupportedVersion, err)
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	ba := newBitArray(1000)
+	ba.SetBit(500)
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, NewEncoder(buf).Encode(ba))
+	data := buf.Bytes()
+
+	_, err := NewDecoder(bytes.NewReader(data[:len(data)-10])).Decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = NewDecoder(bytes.NewReader(data[:2])).Decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestDecodeNativeCorrupt(t *testing.T) {
+	large := &arrayContainer{}
+	for i := 0; i <= arrayMaxSize; i++ {
+		large.values = append(large.values, uint16(i))
+	}
+	bitmap := make([]block, containerBlocks)
+	bitmap[0] = 1
+
+	arrays := map[string]BitArray{
+		"emptyArray":      &roaringBitArray{keys: uintSlice{0}, containers: []container{&arrayContainer{}}},
+		"unsortedArray":   &roaringBitArray{keys: uintSlice{0}, containers: []container{&arrayContainer{values: []uint16{3, 1}}}},
+		"largeArray":      &roaringBitArray{keys: uintSlice{0}, containers: []container{large}},
+		"bitmapCard":      &roaringBitArray{keys: uintSlice{0}, containers: []container{&bitmapContainer{blocks: bitmap, card: 2}}},
+		"emptyBitmap":     &roaringBitArray{keys: uintSlice{0}, containers: []container{&bitmapContainer{blocks: make([]block, containerBlocks)}}},
+		"emptyRuns":       &roaringBitArray{keys: uintSlice{0}, containers: []container{&runContainer{}}},
+		"reversedRun":     &roaringBitArray{keys: uintSlice{0}, containers: []container{&runContainer{runs: []run{{start: 5, last: 1}}}}},
+		"overlappingRuns": &roaringBitArray{keys: uintSlice{0}, containers: []container{&runContainer{runs: []run{{start: 1, last: 5}, {start: 5, last: 9}}}}},
+		"adjacentRuns":    &roaringBitArray{keys: uintSlice{0}, containers: []container{&runContainer{runs: []run{{start: 1, last: 5}, {start: 6, last: 9}}}}},
+		"unsortedKeys": &roaringBitArray{keys: uintSlice{1, 0}, containers: []container{
+			&arrayContainer{values: []uint16{1}}, &arrayContainer{values: []uint16{1}},
+		}},
+		"duplicateKeys": &roaringBitArray{keys: uintSlice{0, 0}, containers: []container{
+			&arrayContainer{values: []uint16{1}}, &arrayContainer{values: []uint16{2}},
+		}},
+		"largeKey":       &roaringBitArray{keys: uintSlice{1 << 48}, containers: []container{&arrayContainer{values: []uint16{1}}}},
+		"unsortedSparse": &sparseBitArray{indices: uintSlice{2, 1}, blocks: blocks{1, 1}},
+	}
+
+	for name, ba := range arrays {
+		buf := new(bytes.Buffer)
+		assert.Nil(t, NewEncoder(buf).Encode(ba), name)
+
+		_, err := NewDecoder(buf).Decode()
+		assert.Equal(t, ErrCorrupt, err, name)
+	}
+}
+
+func TestDecodeRoaringFormatSpecCorrupt(t *testing.T) {
+	bitmap := make([]byte, bitmapBytes)
+	bitmap[0] = 1
+
+	payloads := map[string][]byte{
+		"runOverflow": {
+			0x3B, 0x30, 0, 0, // cookie and number of containers - 1
+			1,           // run container flags
+			0, 0, 31, 0, // key and cardinality - 1
+			1, 0, 0xF0, 0xFF, 31, 0, // one run past the end of the container
+		},
+		"runCard": {
+			0x3B, 0x30, 0, 0,
+			1,
+			0, 0, 4, 0,
+			1, 0, 0, 0, 99, 0,
+		},
+		"unsortedKeys": {
+			0x3A, 0x30, 0, 0, // cookie
+			2, 0, 0, 0, // number of containers
+			1, 0, 0, 0, 0, 0, 0, 0, // keys and cardinalities - 1
+			24, 0, 0, 0, 26, 0, 0, 0, // offsets
+			1, 0, 1, 0, // values
+		},
+		"unsortedArray": {
+			0x3A, 0x30, 0, 0,
+			1, 0, 0, 0,
+			0, 0, 1, 0,
+			16, 0, 0, 0,
+			3, 0, 1, 0,
+		},
+		"bitmapCard": append([]byte{
+			0x3A, 0x30, 0, 0,
+			1, 0, 0, 0,
+			0, 0, 0, 0x10, // a bitmap of 4097 values
+			16, 0, 0, 0,
+		}, bitmap...),
+	}
+
+	for name, data := range payloads {
+		_, err := NewDecoder(bytes.NewReader(data)).Decode()
+		assert.Equal(t, ErrCorrupt, err, name)
+	}
+}
+
+func TestDecodeLegacyFormat(t *testing.T) {
+	dense := newBitArray(1280)
+	sparse := newSparseBitArray()
+	for i := uint64(0); i < 1280; i += 3 {
+		dense.SetBit(i)
+		sparse.SetBit(i)
+	}
+
+	for _, ba := range []BitArray{dense, sparse} {
+		data, err := Marshal(ba)
+		assert.Nil(t, err)
+
+		result, err := NewDecoder(bytes.NewReader(data)).Decode()
+		assert.Nil(t, err)
+		assert.True(t, ba.Equals(result))
+	}
+}
+
+func TestMarshalUnmarshalRoaring(t *testing.T) {
+	rba := newRoaringBitArray()
+	rba.SetBit(5)
+	rba.SetBit(1 << 33)
+
+	data, err := Marshal(rba)
+	assert.Nil(t, err)
+
+	result, err := Unmarshal(data)
+	assert.Nil(t, err)
+	assert.Equal(t, rba.ToNums(), result.ToNums())
+}
+
+func TestEncodeRoaringFormatSpecNoRuns(t *testing.T) {
+	ba := newSparseBitArray()
+	for _, i := range []uint64{1, 2, 3, 1000} {
+		ba.SetBit(i)
+	}
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, NewEncoder(buf, EncodingFormat(RoaringFormatSpec)).Encode(ba))
+
+	expected := []byte{
+		0x3A, 0x30, 0, 0, // cookie
+		1, 0, 0, 0, // number of containers
+		0, 0, 3, 0, // key and cardinality - 1
+		16, 0, 0, 0, // offset of the first container
+		1, 0, 2, 0, 3, 0, 0xE8, 0x03, // values
+	}
+	assert.Equal(t, expected, buf.Bytes())
+
+	result, err := Unmarshal(buf.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{1, 2, 3, 1000}, result.ToNums())
+}
+
+func TestEncodeRoaringFormatSpecRuns(t *testing.T) {
+	rba := newRoaringBitArray()
+	rba.SetRange(0, 100)
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, NewEncoder(buf, EncodingFormat(RoaringFormatSpec)).Encode(rba))
+
+	expected := []byte{
+		0x3B, 0x30, 0, 0, // cookie and number of containers - 1
+		1,           // run container flags
+		0, 0, 99, 0, // key and cardinality - 1
+		1, 0, 0, 0, 99, 0, // one run of length 100
+	}
+	assert.Equal(t, expected, buf.Bytes())
+
+	result, err := NewDecoder(buf).Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, rba, result)
+}
+
+func TestEncodeRoaringFormatSpecMixed(t *testing.T) {
+	rba := newRoaringBitArray()
+	for i := uint64(0); i < 5; i++ {
+		rba.SetBit(i * containerSize * 3)
+	}
+	for i := uint64(containerSize); i < containerSize*2; i += 2 {
+		rba.SetBit(i)
+	}
+	rba.SetRange(containerSize*4, containerSize*4+1000)
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, NewEncoder(buf, EncodingFormat(RoaringFormatSpec)).Encode(rba))
+
+	result, err := NewDecoder(buf).Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, rba, result)
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestEncodeRoaringFormatSpecTooLarge(t *testing.T) {
+	ba := newSparseBitArray()
+	ba.SetBit(1 << 32)
+
+	err := NewEncoder(new(bytes.Buffer), EncodingFormat(RoaringFormatSpec)).Encode(ba)
+	assert.Equal(t, ErrUnsupportedPosition, err)
+}
+
+func BenchmarkEncodeNativeDense(b *testing.B) {
+	numItems := uint64(160000)
+	ba := newBitArray(numItems)
+	for i := uint64(0); i < numItems; i += 3 {
+		ba.SetBit(i)
+	}
+
+	e := NewEncoder(io.Discard)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		e.Encode(ba)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+func xorSparseWithSparseBitArray(sba, other *sparseBitArray) BitArray {
+	max := len(sba.indices) + len(other.indices)
+	indices := make(uintSlice, 0, max)
+	blocks := make(blocks, 0, max)
+
+	selfIndex := 0
+	otherIndex := 0
+	for {
+		if selfIndex == len(sba.indices) {
+			indices = append(indices, other.indices[otherIndex:]...)
+			blocks = append(blocks, other.blocks[otherIndex:]...)
+			break
+		} else if otherIndex == len(other.indices) {
+			indices = append(indices, sba.indices[selfIndex:]...)
+			blocks = append(blocks, sba.blocks[selfIndex:]...)
+			break
+		}
+
+		selfValue := sba.indices[selfIndex]
+		otherValue := other.indices[otherIndex]
+
+		switch {
+		case selfValue < otherValue:
+			indices = append(indices, selfValue)
+			blocks = append(blocks, sba.blocks[selfIndex])
+			selfIndex++
+		case selfValue > otherValue:
+			indices = append(indices, otherValue)
+			blocks = append(blocks, other.blocks[otherIndex])
+			otherIndex++
+		default:
+			// matching blocks cancel out where they share bits, so
+			// only keep the result if anything is left
+			resultBlock := sba.blocks[selfIndex].xor(other.blocks[otherIndex])
+			if resultBlock > 0 {
+				indices = append(indices, selfValue)
+				blocks = append(blocks, resultBlock)
+			}
+			selfIndex++
+			otherIndex++
+		}
+	}
+
+	return &sparseBitArray{
+		indices: indices,
+		blocks:  blocks,
+	}
+}
+
+func xorSparseWithDenseBitArray(sba *sparseBitArray, other *bitArray) BitArray {
+	// Like or, xor with a dense array is likely to be dense, so start
+	// with a copy of the dense array and flip the bits of the sparse one.
+	ba := other.copy().(*bitArray)
+	ba.mergeInPlace(sba, block.xor)
+	return ba
+}
+
+func xorDenseWithDenseBitArray(dba, other *bitArray) BitArray {
+	max := maxUint64(uint64(len(dba.blocks)), uint64(len(other.blocks)))
+	ba := newBitArray(max * s)
+	for i := uint64(0); i < max; i++ {
+		if i == uint64(len(dba.blocks)) {
+			copy(ba.blocks[i:], other.blocks[i:])
+			break
+		}
+
+		if i == uint64(len(other.blocks)) {
+			copy(ba.blocks[i:], dba.blocks[i:])
+			break
+		}
+
+		ba.blocks[i] = dba.blocks[i].xor(other.blocks[i])
+	}
+
+	ba.setLowest()
+	ba.setHighest()
+	return ba
+}
+
+func xorRoaringWithRoaringBitArray(rba, other *roaringBitArray) BitArray {
+	return rba.merge(other, block.xor, true, true)
+}
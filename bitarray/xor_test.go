@@ -0,0 +1,123 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bitarray
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXorSparseWithSparseBitArray(t *testing.T) {
+	sba := newSparseBitArray()
+	other := newSparseBitArray()
+
+	sba.SetBit(3)
+	sba.SetBit(280)
+	other.SetBit(9)
+	other.SetBit(1000)
+
+	// bits in both arrays cancel out
+	sba.SetBit(30)
+	other.SetBit(30)
+	sba.SetBit(2680)
+	other.SetBit(2680)
+
+	ba := xorSparseWithSparseBitArray(sba, other)
+
+	assert.Equal(t, []uint64{3, 9, 280, 1000}, ba.ToNums())
+	// the block holding only 2680 cancelled out entirely
+	assert.Len(t, ba.(*sparseBitArray).indices, 3)
+}
+
+func TestXorSparseWithDenseBitArray(t *testing.T) {
+	sba := newSparseBitArray()
+	other := newBitArray(300)
+
+	sba.SetBit(1)
+	sba.SetBit(1000)
+	other.SetBit(1)
+	other.SetBit(150)
+
+	ba := xorSparseWithDenseBitArray(sba, other)
+	assert.Equal(t, []uint64{150, 1000}, ba.ToNums())
+	assert.Equal(t, []uint64{150, 1000}, other.Xor(sba).ToNums())
+
+	// the dense array is left untouched
+	assert.Equal(t, []uint64{1, 150}, other.ToNums())
+}
+
+func TestXorDenseWithDenseBitArray(t *testing.T) {
+	dba := newBitArray(s * 2)
+	other := newBitArray(s * 4)
+
+	dba.SetBit(1)
+	dba.SetBit(s + 1)
+	other.SetBit(1)
+	other.SetBit(s * 3)
+
+	ba := xorDenseWithDenseBitArray(dba, other)
+	assert.Equal(t, []uint64{s + 1, s * 3}, ba.ToNums())
+	assert.Equal(t, s*4, ba.Capacity())
+}
+
+func TestXorDenseWithEmptyDense(t *testing.T) {
+	dba := newBitArray(s)
+	dba.SetBit(5)
+
+	ba := dba.Xor(newBitArray(0))
+	assert.Equal(t, []uint64{5}, ba.ToNums())
+
+	ba = dba.Xor(dba)
+	assert.True(t, ba.IsEmpty())
+}
+
+func TestAndNotMatchesNand(t *testing.T) {
+	sba := newSparseBitArray()
+	dba := newBitArray(300)
+	for _, i := range []uint64{1, 5, 100, 250} {
+		sba.SetBit(i)
+		dba.SetBit(i)
+	}
+	other := newSparseBitArray()
+	other.SetBit(5)
+	other.SetBit(250)
+
+	assert.Equal(t, []uint64{1, 100}, sba.AndNot(other).ToNums())
+	assert.Equal(t, []uint64{1, 100}, dba.AndNot(other).ToNums())
+	assert.True(t, dba.AndNot(other).Equals(dba.Nand(other)))
+}
+
+func BenchmarkXorSparseWithSparse(b *testing.B) {
+	numItems := uint64(160000)
+	sba := newSparseBitArray()
+	other := newSparseBitArray()
+
+	for i := uint64(0); i < numItems; i += s {
+		if i%200 == 0 {
+			sba.SetBit(i)
+		} else if i%300 == 0 {
+			other.SetBit(i)
+		}
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		xorSparseWithSparseBitArray(sba, other)
+	}
+}
@@ -0,0 +1,303 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package bloom implements Bloom filters on top of the bitarray package.  A
+Bloom filter answers approximate membership queries: Test never returns
+false for an item that was added, but may return true for an item that
+was not, with a probability bounded by the configured false positive rate.
+
+Three filters are provided.  Filter is the standard Bloom filter.
+CountingFilter keeps a small counter per position so that items can be
+removed.  ScalableFilter adds layers of standard filters as it fills so
+that the false positive rate holds without knowing the number of items
+up front.
+
+Positions are derived from two base hashes using double hashing, so
+only a single hash of every item is computed.  None of the filters are
+threadsafe.
+*/
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+
+	"github.com/Workiva/go-datastructures/bitarray"
+)
+
+// DefaultFalsePositiveRate is the false positive rate filters are
+// sized for if no FalsePositiveRate option is provided.
+const DefaultFalsePositiveRate = 0.01
+
+// ErrIncompatible is returned when combining filters that differ in
+// size or number of hash functions.
+var ErrIncompatible = errors.New("bloom: incompatible filters")
+
+// HashFunc computes the two base hashes of an item.  The positions of
+// the item in a filter are derived from these using double hashing.
+type HashFunc func(data []byte) (uint64, uint64)
+
+// DefaultHash is the HashFunc used if no Hash option is provided.  It
+// splits the 128 bit FNV-1a hash of the data in two and mixes both
+// halves, as FNV alone distributes short inputs poorly.
+func DefaultHash(data []byte) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write(data)
+	sum := h.Sum(nil)
+	return mix(binary.BigEndian.Uint64(sum[:8])), mix(binary.BigEndian.Uint64(sum[8:]))
+}
+
+// mix is the 64 bit finalizer of MurmurHash3.
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+type config struct {
+	rate       float64
+	hash       HashFunc
+	growth     uint64
+	tightening float64
+}
+
+func newConfig(options []Option) *config {
+	c := &config{
+		rate:       DefaultFalsePositiveRate,
+		hash:       DefaultHash,
+		growth:     defaultGrowth,
+		tightening: defaultTightening,
+	}
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// Option configures a filter.
+type Option func(*config)
+
+// FalsePositiveRate sets the false positive rate the filter is sized
+// for.  The rate must be between 0 and 1, exclusive.
+func FalsePositiveRate(rate float64) Option {
+	return func(c *config) {
+		if rate > 0 && rate < 1 {
+			c.rate = rate
+		}
+	}
+}
+
+// Hash sets the function used to hash items.  Filters that are combined
+// or deserialized must use the same hash function.
+func Hash(fn HashFunc) Option {
+	return func(c *config) {
+		if fn != nil {
+			c.hash = fn
+		}
+	}
+}
+
+// optimalParameters returns the number of positions and hash functions
+// that minimize the size of a filter holding n items at the given
+// false positive rate.
+func optimalParameters(n uint64, rate float64) (uint64, uint64) {
+	if n == 0 {
+		n = 1
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(rate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k == 0 {
+		k = 1
+	}
+
+	return m, k
+}
+
+// locations calls fn with each of the k positions of the item hashed
+// to h1 and h2 in a filter of m positions.
+func locations(h1, h2, m, k uint64, fn func(uint64) bool) bool {
+	for i := uint64(0); i < k; i++ {
+		if !fn((h1 + i*h2) % m) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Filter is a standard Bloom filter.
+type Filter struct {
+	bits     bitarray.BitArray
+	m        uint64
+	k        uint64
+	capacity uint64
+	count    uint64
+	hash     HashFunc
+}
+
+func (f *Filter) hashFunc() HashFunc {
+	if f.hash == nil {
+		return DefaultHash
+	}
+
+	return f.hash
+}
+
+// Add adds the item to the filter.
+func (f *Filter) Add(data []byte) {
+	h1, h2 := f.hashFunc()(data)
+	f.add(h1, h2)
+}
+
+func (f *Filter) add(h1, h2 uint64) {
+	locations(h1, h2, f.m, f.k, func(i uint64) bool {
+		f.bits.SetBit(i)
+		return true
+	})
+	f.count++
+}
+
+// Test returns a bool indicating if the item may have been added to the
+// filter.  A false result means the item was definitely not added.
+func (f *Filter) Test(data []byte) bool {
+	h1, h2 := f.hashFunc()(data)
+	return f.test(h1, h2)
+}
+
+func (f *Filter) test(h1, h2 uint64) bool {
+	return locations(h1, h2, f.m, f.k, func(i uint64) bool {
+		ok, _ := f.bits.GetBit(i)
+		return ok
+	})
+}
+
+// TestAndAdd adds the item to the filter and returns the result Test
+// would have returned before the item was added.
+func (f *Filter) TestAndAdd(data []byte) bool {
+	h1, h2 := f.hashFunc()(data)
+	present := f.test(h1, h2)
+	f.add(h1, h2)
+	return present
+}
+
+// Count returns the number of items added to the filter.  For filters
+// built by Union or Intersection this is an estimate.
+func (f *Filter) Count() uint64 {
+	return f.count
+}
+
+// Capacity returns the number of items the filter was sized for.
+func (f *Filter) Capacity() uint64 {
+	return f.capacity
+}
+
+// Size returns the number of positions in the filter.
+func (f *Filter) Size() uint64 {
+	return f.m
+}
+
+// HashCount returns the number of positions set for every item.
+func (f *Filter) HashCount() uint64 {
+	return f.k
+}
+
+// EstimatedFalsePositiveRate returns the false positive rate of the
+// filter given the fraction of positions currently set.
+func (f *Filter) EstimatedFalsePositiveRate() float64 {
+	return math.Pow(float64(f.bits.Count())/float64(f.m), float64(f.k))
+}
+
+// Reset removes all items from the filter.
+func (f *Filter) Reset() {
+	f.bits.Reset()
+	f.count = 0
+}
+
+// BitArray returns the bit array backing the filter.  Modifying it
+// modifies the filter.
+func (f *Filter) BitArray() bitarray.BitArray {
+	return f.bits
+}
+
+// Union returns a new filter holding the items of both filters.  The
+// filters must have the same size and number of hash functions.
+func (f *Filter) Union(other *Filter) (*Filter, error) {
+	return f.combine(other, f.bits.Or)
+}
+
+// Intersection returns a new filter holding the items found in both
+// filters.  The filters must have the same size and number of hash
+// functions.  The false positive rate of the result may be higher than
+// that of a filter built from the intersecting items directly.
+func (f *Filter) Intersection(other *Filter) (*Filter, error) {
+	return f.combine(other, f.bits.And)
+}
+
+func (f *Filter) combine(other *Filter,
+	op func(bitarray.BitArray) bitarray.BitArray) (*Filter, error) {
+
+	if f.m != other.m || f.k != other.k {
+		return nil, ErrIncompatible
+	}
+
+	result := &Filter{
+		bits:     op(other.bits),
+		m:        f.m,
+		k:        f.k,
+		capacity: f.capacity,
+		hash:     f.hash,
+	}
+	result.count = result.estimateCount()
+	return result, nil
+}
+
+// estimateCount estimates the number of items in the filter from the
+// number of positions set.
+func (f *Filter) estimateCount() uint64 {
+	set := float64(f.bits.Count())
+	m := float64(f.m)
+	if set >= m {
+		return f.capacity
+	}
+
+	return uint64(math.Round(-m / float64(f.k) * math.Log(1-set/m)))
+}
+
+func newFilter(m, k, capacity uint64, hash HashFunc) *Filter {
+	return &Filter{
+		bits:     bitarray.NewBitArray(m),
+		m:        m,
+		k:        k,
+		capacity: capacity,
+		hash:     hash,
+	}
+}
+
+// New returns a Bloom filter sized to hold n items at the configured
+// false positive rate.
+func New(n uint64, options ...Option) *Filter {
+	c := newConfig(options)
+	m, k := optimalParameters(n, c.rate)
+	return newFilter(m, k, n, c.hash)
+}
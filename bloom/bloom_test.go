@@ -0,0 +1,177 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package bloom
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func item(i int) []byte {
+	return []byte(strconv.Itoa(i))
+}
+
+func TestOptimalParameters(t *testing.T) {
+	m, k := optimalParameters(1000, 0.01)
+	assert.Equal(t, uint64(9586), m)
+	assert.Equal(t, uint64(7), k)
+
+	m, k = optimalParameters(0, 0.5)
+	assert.True(t, m > 0)
+	assert.Equal(t, uint64(1), k)
+}
+
+func TestFilterAddTest(t *testing.T) {
+	f := New(1000)
+	for i := 0; i < 1000; i++ {
+		f.Add(item(i))
+	}
+
+	for i := 0; i < 1000; i++ {
+		assert.True(t, f.Test(item(i)))
+	}
+	assert.Equal(t, uint64(1000), f.Count())
+	assert.Equal(t, uint64(1000), f.Capacity())
+	assert.Equal(t, uint64(7), f.HashCount())
+}
+
+func TestFilterFalsePositiveRate(t *testing.T) {
+	for _, rate := range []float64{0.1, 0.01, 0.001} {
+		f := New(10000, FalsePositiveRate(rate))
+		for i := 0; i < 10000; i++ {
+			f.Add(item(i))
+		}
+
+		falsePositives := 0
+		for i := 10000; i < 110000; i++ {
+			if f.Test(item(i)) {
+				falsePositives++
+			}
+		}
+
+		observed := float64(falsePositives) / 100000
+		assert.True(t, observed < rate*1.5, "rate %v observed %v", rate, observed)
+		assert.InDelta(t, rate, f.EstimatedFalsePositiveRate(), rate*0.5)
+	}
+}
+
+func TestFilterTestAndAdd(t *testing.T) {
+	f := New(100)
+	assert.False(t, f.TestAndAdd([]byte("a")))
+	assert.True(t, f.TestAndAdd([]byte("a")))
+	assert.Equal(t, uint64(2), f.Count())
+}
+
+func TestFilterHash(t *testing.T) {
+	calls := 0
+	f := New(100, Hash(func(data []byte) (uint64, uint64) {
+		calls++
+		return uint64(len(data)), 1
+	}))
+
+	f.Add([]byte("abc"))
+	assert.True(t, f.Test([]byte("xyz")))
+	assert.False(t, f.Test([]byte("ab")))
+	assert.Equal(t, 3, calls)
+}
+
+func TestFilterReset(t *testing.T) {
+	f := New(100)
+	f.Add([]byte("a"))
+	f.Reset()
+
+	assert.False(t, f.Test([]byte("a")))
+	assert.Equal(t, uint64(0), f.Count())
+	assert.True(t, f.BitArray().IsEmpty())
+}
+
+func TestFilterUnion(t *testing.T) {
+	f1, f2 := New(1000), New(1000)
+	for i := 0; i < 500; i++ {
+		f1.Add(item(i))
+		f2.Add(item(i + 500))
+	}
+
+	result, err := f1.Union(f2)
+	assert.Nil(t, err)
+	for i := 0; i < 1000; i++ {
+		assert.True(t, result.Test(item(i)))
+	}
+	assert.InDelta(t, 1000, result.Count(), 50)
+
+	// the operands are untouched
+	assert.False(t, f1.Test(item(999)) && f1.Test(item(998)) && f1.Test(item(997)))
+}
+
+func TestFilterIntersection(t *testing.T) {
+	f1, f2 := New(1000), New(1000)
+	for i := 0; i < 600; i++ {
+		f1.Add(item(i))
+		f2.Add(item(i + 400))
+	}
+
+	result, err := f1.Intersection(f2)
+	assert.Nil(t, err)
+	for i := 400; i < 600; i++ {
+		assert.True(t, result.Test(item(i)))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 400; i++ {
+		if result.Test(item(i)) {
+			falsePositives++
+		}
+	}
+	assert.True(t, falsePositives < 40)
+}
+
+func TestFilterIncompatible(t *testing.T) {
+	f1, f2 := New(1000), New(2000)
+
+	_, err := f1.Union(f2)
+	assert.Equal(t, ErrIncompatible, err)
+
+	_, err = f1.Intersection(f2)
+	assert.Equal(t, ErrIncompatible, err)
+}
+
+func BenchmarkFilterAdd(b *testing.B) {
+	f := New(uint64(b.N))
+	data := make([][]byte, b.N)
+	for i := range data {
+		data[i] = item(i)
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		f.Add(data[i])
+	}
+}
+
+func BenchmarkFilterTest(b *testing.B) {
+	numItems := 100000
+	f := New(uint64(numItems))
+	for i := 0; i < numItems; i++ {
+		f.Add(item(i))
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		f.Test(item(i % (numItems * 2)))
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bloom
+
+import "github.com/Workiva/go-datastructures/bitarray"
+
+const (
+	// counterBits is the number of bits of every counter.  Four bits
+	// make overflow vanishingly unlikely for properly sized filters.
+	counterBits = 4
+	// counterMax is the value at which a counter saturates.  Saturated
+	// counters are never decremented as their true value is unknown.
+	counterMax = 1<<counterBits - 1
+)
+
+// CountingFilter is a Bloom filter that keeps a counter for every
+// position instead of a single bit, which allows items to be removed.
+// The counters are packed into a bit array, four bits each.
+type CountingFilter struct {
+	counters bitarray.BitArray
+	m        uint64
+	k        uint64
+	capacity uint64
+	count    uint64
+	hash     HashFunc
+}
+
+func (cf *CountingFilter) hashFunc() HashFunc {
+	if cf.hash == nil {
+		return DefaultHash
+	}
+
+	return cf.hash
+}
+
+// counter returns the value of the counter at position i.
+func (cf *CountingFilter) counter(i uint64) uint64 {
+	var value uint64
+	for j := uint64(0); j < counterBits; j++ {
+		if ok, _ := cf.counters.GetBit(i*counterBits + j); ok {
+			value |= 1 << j
+		}
+	}
+
+	return value
+}
+
+// setCounter sets the counter at position i to value.
+func (cf *CountingFilter) setCounter(i, value uint64) {
+	for j := uint64(0); j < counterBits; j++ {
+		if value&(1<<j) != 0 {
+			cf.counters.SetBit(i*counterBits + j)
+		} else {
+			cf.counters.ClearBit(i*counterBits + j)
+		}
+	}
+}
+
+// Add adds the item to the filter.
+func (cf *CountingFilter) Add(data []byte) {
+	h1, h2 := cf.hashFunc()(data)
+	locations(h1, h2, cf.m, cf.k, func(i uint64) bool {
+		if value := cf.counter(i); value < counterMax {
+			cf.setCounter(i, value+1)
+		}
+		return true
+	})
+	cf.count++
+}
+
+// Test returns a bool indicating if the item may have been added to the
+// filter.  A false result means the item was definitely not added.
+func (cf *CountingFilter) Test(data []byte) bool {
+	h1, h2 := cf.hashFunc()(data)
+	return cf.test(h1, h2)
+}
+
+func (cf *CountingFilter) test(h1, h2 uint64) bool {
+	return locations(h1, h2, cf.m, cf.k, func(i uint64) bool {
+		return cf.counter(i) > 0
+	})
+}
+
+// Remove removes the item from the filter.  It returns false, and
+// leaves the filter untouched, if the item was definitely not added.
+// Removing an item that was never added, but tests as present, removes
+// a false positive and may introduce false negatives for other items.
+func (cf *CountingFilter) Remove(data []byte) bool {
+	h1, h2 := cf.hashFunc()(data)
+	if !cf.test(h1, h2) {
+		return false
+	}
+
+	locations(h1, h2, cf.m, cf.k, func(i uint64) bool {
+		if value := cf.counter(i); value < counterMax {
+			cf.setCounter(i, value-1)
+		}
+		return true
+	})
+	if cf.count > 0 {
+		cf.count--
+	}
+
+	return true
+}
+
+// Count returns the number of items in the filter.
+func (cf *CountingFilter) Count() uint64 {
+	return cf.count
+}
+
+// Capacity returns the number of items the filter was sized for.
+func (cf *CountingFilter) Capacity() uint64 {
+	return cf.capacity
+}
+
+// Size returns the number of counters in the filter.
+func (cf *CountingFilter) Size() uint64 {
+	return cf.m
+}
+
+// HashCount returns the number of counters incremented for every item.
+func (cf *CountingFilter) HashCount() uint64 {
+	return cf.k
+}
+
+// Reset removes all items from the filter.
+func (cf *CountingFilter) Reset() {
+	cf.counters.Reset()
+	cf.count = 0
+}
+
+// NewCounting returns a counting Bloom filter sized to hold n items at
+// the configured false positive rate.
+func NewCounting(n uint64, options ...Option) *CountingFilter {
+	c := newConfig(options)
+	m, k := optimalParameters(n, c.rate)
+	return &CountingFilter{
+		counters: bitarray.NewBitArray(m * counterBits),
+		m:        m,
+		k:        k,
+		capacity: n,
+		hash:     c.hash,
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package bloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountingAddTestRemove(t *testing.T) {
+	cf := NewCounting(1000)
+	for i := 0; i < 1000; i++ {
+		cf.Add(item(i))
+	}
+	assert.Equal(t, uint64(1000), cf.Count())
+
+	for i := 0; i < 1000; i++ {
+		assert.True(t, cf.Test(item(i)))
+	}
+
+	for i := 0; i < 500; i++ {
+		assert.True(t, cf.Remove(item(i)))
+	}
+	assert.Equal(t, uint64(500), cf.Count())
+
+	// removal never introduces false negatives for remaining items
+	for i := 500; i < 1000; i++ {
+		assert.True(t, cf.Test(item(i)))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 500; i++ {
+		if cf.Test(item(i)) {
+			falsePositives++
+		}
+	}
+	assert.True(t, falsePositives < 25)
+}
+
+func TestCountingRemoveMissing(t *testing.T) {
+	cf := NewCounting(100)
+	cf.Add([]byte("a"))
+
+	assert.False(t, cf.Remove([]byte("b")))
+	assert.Equal(t, uint64(1), cf.Count())
+	assert.True(t, cf.Test([]byte("a")))
+}
+
+func TestCountingDuplicates(t *testing.T) {
+	cf := NewCounting(100)
+	cf.Add([]byte("a"))
+	cf.Add([]byte("a"))
+
+	assert.True(t, cf.Remove([]byte("a")))
+	assert.True(t, cf.Test([]byte("a")))
+	assert.True(t, cf.Remove([]byte("a")))
+	assert.False(t, cf.Test([]byte("a")))
+}
+
+func TestCountingSaturation(t *testing.T) {
+	cf := NewCounting(100)
+	for i := 0; i < counterMax+5; i++ {
+		cf.Add([]byte("a"))
+	}
+	for i := 0; i < counterMax+5; i++ {
+		cf.Remove([]byte("a"))
+	}
+
+	// saturated counters are never decremented
+	assert.True(t, cf.Test([]byte("a")))
+}
+
+func TestCountingReset(t *testing.T) {
+	cf := NewCounting(100, FalsePositiveRate(0.001))
+	cf.Add([]byte("a"))
+	cf.Reset()
+
+	assert.False(t, cf.Test([]byte("a")))
+	assert.Equal(t, uint64(0), cf.Count())
+	assert.Equal(t, uint64(100), cf.Capacity())
+	assert.Equal(t, uint64(10), cf.HashCount())
+}
+
+func BenchmarkCountingAdd(b *testing.B) {
+	cf := NewCounting(uint64(b.N))
+	data := make([][]byte, b.N)
+	for i := range data {
+		data[i] = item(i)
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		cf.Add(data[i])
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/Workiva/go-datastructures/bitarray"
+)
+
+const (
+	filterIdentifier   = 'F'
+	countingIdentifier = 'C'
+	scalableIdentifier = 'L'
+)
+
+var errInvalidData = errors.New("bloom: invalid data for filter")
+
+// header holds the parameters of a standard or counting filter.
+type header struct {
+	Identifier uint8
+	M          uint64
+	K          uint64
+	Capacity   uint64
+	Count      uint64
+}
+
+// scalableHeader holds the parameters of a scalable filter.
+type scalableHeader struct {
+	Identifier uint8
+	Rate       float64
+	Growth     uint64
+	Tightening float64
+	Layers     uint64
+}
+
+func serialize(h header, ba bitarray.BitArray) ([]byte, error) {
+	w := new(bytes.Buffer)
+	if err := binary.Write(w, binary.LittleEndian, h); err != nil {
+		return nil, err
+	}
+
+	bits, err := bitarray.Marshal(ba)
+	if err != nil {
+		return nil, err
+	}
+	w.Write(bits)
+
+	return w.Bytes(), nil
+}
+
+func deserialize(identifier uint8, input []byte) (header, bitarray.BitArray, error) {
+	var h header
+	r := bytes.NewReader(input)
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return h, nil, err
+	}
+	if h.Identifier != identifier || h.M == 0 || h.K == 0 {
+		return h, nil, errInvalidData
+	}
+
+	rest, _ := ioutil.ReadAll(r)
+	ba, err := bitarray.Unmarshal(rest)
+	if err != nil {
+		return h, nil, err
+	}
+
+	return h, ba, nil
+}
+
+// Serialize converts the filter to a byte slice.  The bits of the
+// filter are written with bitarray.Marshal.  The hash function is not
+// part of the output.
+func (f *Filter) Serialize() ([]byte, error) {
+	return serialize(header{
+		Identifier: filterIdentifier,
+		M:          f.m,
+		K:          f.k,
+		Capacity:   f.capacity,
+		Count:      f.count,
+	}, f.bits)
+}
+
+// Deserialize replaces the contents of the filter with the filter in
+// the provided byte slice, as produced by Serialize.  The filter keeps
+// its hash function, which must match the one the data was built with.
+func (f *Filter) Deserialize(input []byte) error {
+	h, ba, err := deserialize(filterIdentifier, input)
+	if err != nil {
+		return err
+	}
+	if ba.Capacity() < h.M {
+		return errInvalidData
+	}
+
+	f.bits, f.m, f.k, f.capacity, f.count = ba, h.M, h.K, h.Capacity, h.Count
+	return nil
+}
+
+// Serialize converts the counting filter to a byte slice.  The counters
+// of the filter are written with bitarray.Marshal.  The hash function is
+// not part of the output.
+func (cf *CountingFilter) Serialize() ([]byte, error) {
+	return serialize(header{
+		Identifier: countingIdentifier,
+		M:          cf.m,
+		K:          cf.k,
+		Capacity:   cf.capacity,
+		Count:      cf.count,
+	}, cf.counters)
+}
+
+// Deserialize replaces the contents of the counting filter with the
+// filter in the provided byte slice, as produced by Serialize.  The
+// filter keeps its hash function, which must match the one the data was
+// built with.
+func (cf *CountingFilter) Deserialize(input []byte) error {
+	h, ba, err := deserialize(countingIdentifier, input)
+	if err != nil {
+		return err
+	}
+	if ba.Capacity() < h.M*counterBits {
+		return errInvalidData
+	}
+
+	cf.counters, cf.m, cf.k, cf.capacity, cf.count = ba, h.M, h.K, h.Capacity, h.Count
+	return nil
+}
+
+// Serialize converts the scalable filter to a byte slice holding its
+// parameters followed by every serialized layer.  The hash function is
+// not part of the output.
+func (sf *ScalableFilter) Serialize() ([]byte, error) {
+	w := new(bytes.Buffer)
+	err := binary.Write(w, binary.LittleEndian, scalableHeader{
+		Identifier: scalableIdentifier,
+		Rate:       sf.rate,
+		Growth:     sf.growth,
+		Tightening: sf.tightening,
+		Layers:     uint64(len(sf.layers)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, layer := range sf.layers {
+		data, err := layer.Serialize()
+		if err != nil {
+			return nil, err
+		}
+
+		binary.Write(w, binary.LittleEndian, uint64(len(data)))
+		w.Write(data)
+	}
+
+	return w.Bytes(), nil
+}
+
+// Deserialize replaces the contents of the scalable filter with the
+// filter in the provided byte slice, as produced by Serialize.  The
+// filter keeps its hash function, which must match the one the data was
+// built with.
+func (sf *ScalableFilter) Deserialize(input []byte) error {
+	var h scalableHeader
+	r := bytes.NewReader(input)
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return err
+	}
+	if h.Identifier != scalableIdentifier || h.Layers == 0 || h.Growth == 0 {
+		return errInvalidData
+	}
+
+	var layers []*Filter
+	for i := uint64(0); i < h.Layers; i++ {
+		var length uint64
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return err
+		}
+		if length > uint64(r.Len()) {
+			return io.ErrUnexpectedEOF
+		}
+
+		data := make([]byte, length)
+		r.Read(data)
+		layer := &Filter{hash: sf.hash}
+		if err := layer.Deserialize(data); err != nil {
+			return err
+		}
+		layers = append(layers, layer)
+	}
+
+	sf.layers, sf.rate, sf.growth, sf.tightening = layers, h.Rate, h.Growth, h.Tightening
+	return nil
+}
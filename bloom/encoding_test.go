@@ -0,0 +1,107 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package bloom
+
+import (
+	"testing"
+
+	"github.com/Workiva/go-datastructures/bitarray"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterSerialize(t *testing.T) {
+	f := New(1000)
+	for i := 0; i < 500; i++ {
+		f.Add(item(i))
+	}
+
+	data, err := f.Serialize()
+	assert.Nil(t, err)
+
+	result := &Filter{}
+	assert.Nil(t, result.Deserialize(data))
+	assert.Equal(t, f.Count(), result.Count())
+	assert.Equal(t, f.Capacity(), result.Capacity())
+	assert.True(t, f.BitArray().Equals(result.BitArray()))
+	for i := 0; i < 500; i++ {
+		assert.True(t, result.Test(item(i)))
+	}
+}
+
+func TestFilterSerializeUsesBitArrayMarshal(t *testing.T) {
+	f := New(100)
+	f.Add([]byte("a"))
+
+	data, err := f.Serialize()
+	assert.Nil(t, err)
+
+	bits, err := bitarray.Marshal(f.BitArray())
+	assert.Nil(t, err)
+	assert.Equal(t, bits, data[len(data)-len(bits):])
+}
+
+func TestCountingSerialize(t *testing.T) {
+	cf := NewCounting(100)
+	cf.Add([]byte("a"))
+	cf.Add([]byte("a"))
+	cf.Add([]byte("b"))
+
+	data, err := cf.Serialize()
+	assert.Nil(t, err)
+
+	result := &CountingFilter{}
+	assert.Nil(t, result.Deserialize(data))
+	assert.Equal(t, uint64(3), result.Count())
+	assert.True(t, result.Remove([]byte("a")))
+	assert.True(t, result.Test([]byte("a")))
+	assert.True(t, result.Test([]byte("b")))
+}
+
+func TestScalableSerialize(t *testing.T) {
+	sf := NewScalable(10)
+	for i := 0; i < 100; i++ {
+		sf.Add(item(i))
+	}
+
+	data, err := sf.Serialize()
+	assert.Nil(t, err)
+
+	result := &ScalableFilter{}
+	assert.Nil(t, result.Deserialize(data))
+	assert.Equal(t, sf.Layers(), result.Layers())
+	assert.Equal(t, sf.Count(), result.Count())
+	for i := 0; i < 100; i++ {
+		assert.True(t, result.Test(item(i)))
+	}
+
+	// the deserialized filter keeps growing like the original
+	for i := 100; i < 1000; i++ {
+		result.Add(item(i))
+	}
+	assert.True(t, result.Layers() > sf.Layers())
+}
+
+func TestDeserializeErrors(t *testing.T) {
+	f := New(100)
+	data, err := f.Serialize()
+	assert.Nil(t, err)
+
+	assert.NotNil(t, (&CountingFilter{}).Deserialize(data))
+	assert.NotNil(t, (&ScalableFilter{}).Deserialize(data))
+	assert.NotNil(t, (&Filter{}).Deserialize(data[:10]))
+	assert.NotNil(t, (&Filter{}).Deserialize(data[:len(data)-8]))
+	assert.NotNil(t, (&Filter{}).Deserialize(nil))
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bloom
+
+import "math"
+
+const (
+	// defaultGrowth is the factor by which the capacity of every new
+	// layer of a scalable filter exceeds that of the previous layer.
+	defaultGrowth = 2
+	// defaultTightening is the factor applied to the false positive
+	// rate of every new layer of a scalable filter.  This keeps the
+	// compound false positive rate below the configured rate.
+	defaultTightening = 0.8
+)
+
+// GrowthFactor sets the factor by which the capacity of every new layer
+// of a scalable filter exceeds that of the previous layer.  It has no
+// effect on other filters.
+func GrowthFactor(growth uint64) Option {
+	return func(c *config) {
+		if growth > 0 {
+			c.growth = growth
+		}
+	}
+}
+
+// TighteningRatio sets the factor applied to the false positive rate of
+// every new layer of a scalable filter.  The ratio must be between 0 and
+// 1, exclusive.  It has no effect on other filters.
+func TighteningRatio(ratio float64) Option {
+	return func(c *config) {
+		if ratio > 0 && ratio < 1 {
+			c.tightening = ratio
+		}
+	}
+}
+
+// ScalableFilter is a Bloom filter that adds a new, larger layer
+// whenever the current layer reaches its capacity.  Every layer is a
+// standard filter with a tighter false positive rate than the last, so
+// the false positive rate of the scalable filter as a whole stays below
+// the configured rate however many items are added.
+type ScalableFilter struct {
+	layers     []*Filter
+	rate       float64
+	growth     uint64
+	tightening float64
+	hash       HashFunc
+}
+
+func (sf *ScalableFilter) hashFunc() HashFunc {
+	if sf.hash == nil {
+		return DefaultHash
+	}
+
+	return sf.hash
+}
+
+// addLayer appends a new layer with room for capacity items at the
+// given false positive rate.
+func (sf *ScalableFilter) addLayer(capacity uint64, rate float64) {
+	m, k := optimalParameters(capacity, rate)
+	sf.layers = append(sf.layers, newFilter(m, k, capacity, sf.hash))
+}
+
+// Add adds the item to the filter.  Items that test as present are not
+// added again so they don't take up room in the current layer.
+func (sf *ScalableFilter) Add(data []byte) {
+	sf.TestAndAdd(data)
+}
+
+// Test returns a bool indicating if the item may have been added to the
+// filter.  A false result means the item was definitely not added.
+func (sf *ScalableFilter) Test(data []byte) bool {
+	h1, h2 := sf.hashFunc()(data)
+	return sf.test(h1, h2)
+}
+
+func (sf *ScalableFilter) test(h1, h2 uint64) bool {
+	for i := len(sf.layers) - 1; i >= 0; i-- {
+		if sf.layers[i].test(h1, h2) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TestAndAdd adds the item to the filter and returns the result Test
+// would have returned before the item was added.
+func (sf *ScalableFilter) TestAndAdd(data []byte) bool {
+	h1, h2 := sf.hashFunc()(data)
+	if sf.test(h1, h2) {
+		return true
+	}
+
+	last := sf.layers[len(sf.layers)-1]
+	if last.count >= last.capacity {
+		n := len(sf.layers)
+		sf.addLayer(last.capacity*sf.growth, sf.rate*math.Pow(sf.tightening, float64(n)))
+		last = sf.layers[n]
+	}
+
+	last.add(h1, h2)
+	return false
+}
+
+// Count returns the number of distinct items added to the filter.
+func (sf *ScalableFilter) Count() uint64 {
+	var count uint64
+	for _, layer := range sf.layers {
+		count += layer.count
+	}
+
+	return count
+}
+
+// Layers returns the number of layers in the filter.
+func (sf *ScalableFilter) Layers() int {
+	return len(sf.layers)
+}
+
+// Reset removes all items and all but the first layer from the filter.
+func (sf *ScalableFilter) Reset() {
+	for i := 1; i < len(sf.layers); i++ {
+		sf.layers[i] = nil
+	}
+	sf.layers = sf.layers[:1]
+	sf.layers[0].Reset()
+}
+
+// NewScalable returns a scalable Bloom filter whose first layer holds n
+// items.  The rate of the first layer is chosen so that the compound
+// false positive rate of all layers stays below the configured rate.
+func NewScalable(n uint64, options ...Option) *ScalableFilter {
+	c := newConfig(options)
+	if n == 0 {
+		n = 1
+	}
+
+	sf := &ScalableFilter{
+		rate:       c.rate * (1 - c.tightening),
+		growth:     c.growth,
+		tightening: c.tightening,
+		hash:       c.hash,
+	}
+	sf.addLayer(n, sf.rate)
+	return sf
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package bloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScalableGrows(t *testing.T) {
+	sf := NewScalable(100)
+	assert.Equal(t, 1, sf.Layers())
+
+	for i := 0; i < 1000; i++ {
+		sf.Add(item(i))
+	}
+
+	// 100 + 200 + 400 < 1000 <= 100 + 200 + 400 + 800
+	assert.Equal(t, 4, sf.Layers())
+	for i := 0; i < 1000; i++ {
+		assert.True(t, sf.Test(item(i)))
+	}
+	assert.InDelta(t, 1000, sf.Count(), 10)
+}
+
+func TestScalableFalsePositiveRate(t *testing.T) {
+	sf := NewScalable(100, FalsePositiveRate(0.01))
+	for i := 0; i < 20000; i++ {
+		sf.Add(item(i))
+	}
+
+	falsePositives := 0
+	for i := 20000; i < 120000; i++ {
+		if sf.Test(item(i)) {
+			falsePositives++
+		}
+	}
+
+	assert.True(t, float64(falsePositives)/100000 < 0.01)
+}
+
+func TestScalableTestAndAdd(t *testing.T) {
+	sf := NewScalable(10)
+	assert.False(t, sf.TestAndAdd([]byte("a")))
+	assert.True(t, sf.TestAndAdd([]byte("a")))
+	assert.Equal(t, uint64(1), sf.Count())
+}
+
+func TestScalableOptions(t *testing.T) {
+	sf := NewScalable(10, GrowthFactor(4), TighteningRatio(0.5))
+	for i := 0; i < 50; i++ {
+		sf.Add(item(i))
+	}
+
+	assert.Equal(t, 2, sf.Layers())
+	assert.Equal(t, uint64(40), sf.layers[1].Capacity())
+}
+
+func TestScalableReset(t *testing.T) {
+	sf := NewScalable(10)
+	for i := 0; i < 100; i++ {
+		sf.Add(item(i))
+	}
+	sf.Reset()
+
+	assert.Equal(t, 1, sf.Layers())
+	assert.Equal(t, uint64(0), sf.Count())
+	assert.False(t, sf.Test(item(1)))
+}
@@ -0,0 +1,127 @@
+package cache
+
+// Adaptive replacement policy (ARC), measured in bytes rather than items.
+// Resident items are split between a list of items used once (t1) and a list
+// of items used more than once (t2). Ghost lists (b1, b2) remember the keys
+// recently evicted from each, and a hit on a ghost shifts the target size of t1
+// towards the list that would have kept the item.
+type arcPolicy struct {
+	cap     uint64            // Capacity bound
+	target  uint64            // Target size of t1
+	t1      *lruList          // Resident items used once
+	t2      *lruList          // Resident items used more than once
+	b1      *lruList          // Ghosts of items evicted from t1
+	b2      *lruList          // Ghosts of items evicted from t2
+	entries map[string]*entry // Map from keys to resident items and ghosts
+}
+
+func newARCPolicy(capacity uint64) *arcPolicy {
+	return &arcPolicy{
+		cap:     capacity,
+		t1:      newLRUList(),
+		t2:      newLRUList(),
+		b1:      newLRUList(),
+		b2:      newLRUList(),
+		entries: map[string]*entry{},
+	}
+}
+
+func (p *arcPolicy) add(key string, size uint64) []string {
+	if size > p.cap {
+		return []string{key}
+	}
+
+	var evicted []string
+	e, ok := p.entries[key]
+	switch {
+	case ok && e.list == p.b1:
+		// t1 was too small to keep this item, grow its target
+		p.target = minUint64(p.cap, p.target+p.delta(size, p.b2, p.b1))
+		p.b1.remove(e)
+		e.size = size
+		evicted = p.replace(size, false)
+		p.t2.pushFront(e)
+	case ok && e.list == p.b2:
+		// t2 was too small to keep this item, shrink the target of t1
+		delta := p.delta(size, p.b1, p.b2)
+		if delta > p.target {
+			delta = p.target
+		}
+		p.target -= delta
+		p.b2.remove(e)
+		e.size = size
+		evicted = p.replace(size, true)
+		p.t2.pushFront(e)
+	default:
+		e = &entry{key: key, size: size}
+		p.entries[key] = e
+		evicted = p.replace(size, false)
+		p.t1.pushFront(e)
+	}
+
+	// Bound the ghosts to the capacity of the lists they shadow
+	for p.t1.size+p.b1.size > p.cap && p.b1.back() != nil {
+		delete(p.entries, p.b1.popBack().key)
+	}
+	for p.t1.size+p.t2.size+p.b1.size+p.b2.size > 2*p.cap && p.b2.back() != nil {
+		delete(p.entries, p.b2.popBack().key)
+	}
+
+	return evicted
+}
+
+// Returns how far to move the target of t1 for a ghost hit of the given size,
+// scaled by how much larger the other ghost list is than the one hit.
+func (p *arcPolicy) delta(size uint64, other, hit *lruList) uint64 {
+	if hit.size > 0 && other.size > hit.size {
+		return size * (other.size / hit.size)
+	}
+	return size
+}
+
+// Evicts resident items to ghost lists until an item of the given size fits.
+func (p *arcPolicy) replace(size uint64, hitB2 bool) []string {
+	var evicted []string
+	for p.t1.size+p.t2.size+size > p.cap {
+		var e *entry
+		if p.t1.back() != nil && (p.t1.size > p.target || (hitB2 && p.t1.size == p.target) || p.t2.back() == nil) {
+			e = p.t1.popBack()
+			p.b1.pushFront(e)
+		} else {
+			e = p.t2.popBack()
+			p.b2.pushFront(e)
+		}
+		evicted = append(evicted, e.key)
+	}
+
+	return evicted
+}
+
+func (p *arcPolicy) access(key string) {
+	e, ok := p.entries[key]
+	if !ok {
+		return
+	}
+
+	switch e.list {
+	case p.t1:
+		p.t1.remove(e)
+		p.t2.pushFront(e)
+	case p.t2:
+		p.t2.moveToFront(e)
+	}
+}
+
+func (p *arcPolicy) remove(key string) {
+	if e, ok := p.entries[key]; ok && (e.list == p.t1 || e.list == p.t2) {
+		e.list.remove(e)
+		delete(p.entries, key)
+	}
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
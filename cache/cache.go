@@ -3,6 +3,7 @@ package cache
 import (
 	"container/list"
 	"sync"
+	"time"
 )
 
 // Cache is a bounded-size in-memory cache of sized items with a configurable eviction policy
@@ -12,13 +13,32 @@ type Cache interface {
 	Get(keys ...string) []Item
 
 	// Put adds an item to the cache.
+	// The item expires after the default TTL, if one is configured.
 	Put(key string, item Item)
 
+	// PutWithTTL adds an item to the cache that expires after the given TTL.
+	// A TTL of zero or less means the item never expires.
+	PutWithTTL(key string, item Item, ttl time.Duration)
+
 	// Remove clears items with the given keys from the cache
 	Remove(keys ...string)
 
 	// Size returns the size of all items currently in the cache.
 	Size() uint64
+
+	// Dispose stops the background sweeper, if any.
+	Dispose()
+
+	// GetOrLoad retrieves an item from the cache by key, loading and adding it
+	// with the configured Loader if it is not found.
+	// Concurrent calls for the same key share a single load.
+	GetOrLoad(key string) (Item, error)
+
+	// GetAllOrLoad retrieves items from the cache by key, loading and adding the
+	// ones not found, with the configured BulkLoader if any, or else the Loader.
+	// If an item can't be loaded its position in the result will be nil,
+	// and the first error encountered is returned.
+	GetAllOrLoad(keys ...string) ([]Item, error)
 }
 
 // Item is an item in a cache
@@ -27,12 +47,18 @@ type Item interface {
 	Size() uint64
 }
 
-// A tuple tracking a cached item and a reference to its node in the eviction list
+// A tuple tracking a cached item, its expiry time and a reference to its node in the eviction list
 type cached struct {
 	item    Item
+	expires time.Time // Zero if the item never expires
 	element *list.Element
 }
 
+// Returns whether the item has expired at the given time
+func (c *cached) expired(now time.Time) bool {
+	return !c.expires.IsZero() && !now.Before(c.expires)
+}
+
 // Sets the provided list element on the cached item if it is not nil
 func (c *cached) setElementIfNotNil(element *list.Element) {
 	if element != nil {
@@ -49,6 +75,43 @@ type cache struct {
 	keyList      *list.List                     // List of cached items in order of increasing evictability
 	recordAdd    func(key string) *list.Element // Function called to indicate that an item with the given key was added
 	recordAccess func(key string) *list.Element // Function called to indicate that an item with the given key was accessed
+	policy       policy                         // Policy tracking items instead of the eviction list, if any
+	ttl          time.Duration                  // Default TTL of items added by Put
+	onEvict      EvictionCallback               // Function called for every evicted item
+	evictions    []eviction                     // Evictions not yet passed to onEvict
+	now          func() time.Time               // Clock used for expiry
+	sweep        time.Duration                  // Interval of the background sweeper
+	stop         chan struct{}                  // Closed to stop the background sweeper
+	loader       Loader                         // Function loading missing items, if any
+	bulkLoader   BulkLoader                     // Function loading several missing items at once, if any
+	negativeTTL  time.Duration                  // Time to remember failed loads for
+	failures     map[string]*failure            // Map from keys to remembered failed loads
+	loads        map[string]*load               // Map from keys to loads in progress
+}
+
+// EvictionReason is the reason an item was evicted from a cache.
+type EvictionReason uint8
+
+const (
+	// Capacity indicates an item was evicted to make room for another.
+	Capacity EvictionReason = iota
+	// Expired indicates an item's TTL passed.
+	Expired
+	// Removed indicates an item was removed with Remove.
+	Removed
+	// Replaced indicates an item was replaced by another with the same key.
+	Replaced
+)
+
+// EvictionCallback is called with every item evicted from a cache,
+// after the cache lock has been released.
+type EvictionCallback func(key string, item Item, reason EvictionReason)
+
+// An evicted item waiting to be passed to the eviction callback
+type eviction struct {
+	key    string
+	item   Item
+	reason EvictionReason
 }
 
 // CacheOption configures a cache.
@@ -62,12 +125,23 @@ const (
 	LeastRecentlyAdded Policy = iota
 	// LeastRecentlyUsed indicates a least-recently-used eviction policy.
 	LeastRecentlyUsed
+	// LeastFrequentlyUsed indicates a least-frequently-used eviction policy,
+	// breaking ties by evicting the least recently used item.
+	LeastFrequentlyUsed
+	// AdaptiveReplacement indicates an adaptive replacement (ARC) eviction policy,
+	// which balances recency and frequency based on the items it recently evicted.
+	AdaptiveReplacement
+	// WindowTinyLFU indicates a W-TinyLFU eviction policy, which only admits items
+	// leaving a small LRU window if a count-min sketch estimates they are used
+	// more often than the item they would replace in a segmented LRU.
+	WindowTinyLFU
 )
 
 // EvictionPolicy sets the eviction policy to be used to make room for new items.
 // If not provided, default is LeastRecentlyUsed.
 func EvictionPolicy(policy Policy) CacheOption {
 	return func(c *cache) {
+		c.policy = nil
 		switch policy {
 		case LeastRecentlyAdded:
 			c.recordAccess = c.noop
@@ -75,18 +149,54 @@ func EvictionPolicy(policy Policy) CacheOption {
 		case LeastRecentlyUsed:
 			c.recordAccess = c.record
 			c.recordAdd = c.noop
+		case LeastFrequentlyUsed:
+			c.policy = newLFUPolicy(c.cap)
+		case AdaptiveReplacement:
+			c.policy = newARCPolicy(c.cap)
+		case WindowTinyLFU:
+			c.policy = newTinyLFUPolicy(c.cap)
 		}
 	}
 }
 
+// DefaultTTL sets the TTL of items added with Put.
+// If not provided, items added with Put never expire.
+func DefaultTTL(ttl time.Duration) CacheOption {
+	return func(c *cache) {
+		c.ttl = ttl
+	}
+}
+
+// OnEvict sets a function to be called with every item evicted
+// to make room for another, because it expired, or because it was
+// removed or replaced.
+func OnEvict(callback EvictionCallback) CacheOption {
+	return func(c *cache) {
+		c.onEvict = callback
+	}
+}
+
+// SweepInterval starts a background sweeper that removes expired items
+// at the given interval, so they don't hold on to capacity until they
+// are next looked up. The sweeper runs until Dispose is called.
+// If not provided, expired items are only removed when looked up or evicted.
+func SweepInterval(interval time.Duration) CacheOption {
+	return func(c *cache) {
+		c.sweep = interval
+	}
+}
+
 // New returns a cache with the requested options configured.
 // The cache consumes memory bounded by a fixed capacity,
 // plus tracking overhead linear in the number of items.
 func New(capacity uint64, options ...CacheOption) Cache {
 	c := &cache{
-		cap:     capacity,
-		keyList: list.New(),
-		items:   map[string]*cached{},
+		cap:      capacity,
+		keyList:  list.New(),
+		items:    map[string]*cached{},
+		now:      time.Now,
+		failures: map[string]*failure{},
+		loads:    map[string]*load{},
 	}
 	// Default LRU eviction policy
 	EvictionPolicy(LeastRecentlyUsed)(c)
@@ -95,39 +205,94 @@ func New(capacity uint64, options ...CacheOption) Cache {
 		option(c)
 	}
 
+	if c.sweep > 0 {
+		c.stop = make(chan struct{})
+		go c.sweeper(c.stop)
+	}
+
 	return c
 }
 
 func (c *cache) Get(keys ...string) []Item {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlock()
 
+	now := c.now()
 	items := make([]Item, len(keys))
 	for i, key := range keys {
-		cached := c.items[key]
-		if cached == nil {
-			items[i] = nil
-		} else {
-			c.recordAccess(key)
-			items[i] = cached.item
-		}
+		items[i] = c.get(key, now)
 	}
 
 	return items
 }
 
+// Retrieve the item with the given key, or nil if it is not found.
+// The caller should hold the cache lock.
+func (c *cache) get(key string, now time.Time) Item {
+	cached := c.items[key]
+	if cached != nil && cached.expired(now) {
+		// Lazily expire the item
+		c.evict(key, Expired)
+		cached = nil
+	}
+	if cached == nil {
+		return nil
+	}
+
+	if c.policy != nil {
+		c.policy.access(key)
+	} else {
+		c.recordAccess(key)
+	}
+	return cached.item
+}
+
 func (c *cache) Put(key string, item Item) {
+	c.PutWithTTL(key, item, c.ttl)
+}
+
+func (c *cache) PutWithTTL(key string, item Item, ttl time.Duration) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlock()
 
-	// Remove the item currently with this key (if any)
-	c.remove(key)
+	c.forget(key)
+	c.put(key, item, ttl)
+}
+
+// Add an item to the cache, replacing the item currently with this key (if any).
+// The caller should hold the cache lock.
+func (c *cache) put(key string, item Item, ttl time.Duration) {
+	if _, ok := c.items[key]; ok {
+		c.evict(key, Replaced)
+	}
+
+	cached := &cached{item: item}
+	if ttl > 0 {
+		cached.expires = c.now().Add(ttl)
+	}
+
+	if c.policy != nil {
+		// The policy decides what to evict, possibly the new item itself
+		c.items[key] = cached
+		c.size += item.Size()
+		for _, evicted := range c.policy.add(key, item.Size()) {
+			c.evictUntracked(evicted, Capacity)
+		}
+		return
+	}
+
+	if item.Size() > c.cap {
+		// The item can never fit, evict it right away as the policies do
+		if c.onEvict != nil {
+			c.evictions = append(c.evictions, eviction{key: key, item: item, reason: Capacity})
+		}
+		return
+	}
 
 	// Make sure there's room to add this item
 	c.ensureCapacity(item.Size())
 
 	// Actually add the new item
-	cached := &cached{item: item}
 	cached.setElementIfNotNil(c.recordAdd(key))
 	cached.setElementIfNotNil(c.recordAccess(key))
 	c.items[key] = cached
@@ -136,36 +301,116 @@ func (c *cache) Put(key string, item Item) {
 
 func (c *cache) Remove(keys ...string) {
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlock()
 
 	for _, key := range keys {
-		c.remove(key)
+		c.forget(key)
+		if _, ok := c.items[key]; ok {
+			c.evict(key, Removed)
+		}
 	}
 }
 
 func (c *cache) Size() uint64 {
+	c.Lock()
+	defer c.Unlock()
+
 	return c.size
 }
 
+func (c *cache) Dispose() {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+// Releases the cache lock, then passes any evictions made while it was
+// held to the eviction callback.
+func (c *cache) unlock() {
+	evictions := c.evictions
+	c.evictions = nil
+	c.Unlock()
+
+	for _, e := range evictions {
+		c.onEvict(e.key, e.item, e.reason)
+	}
+}
+
+// Periodically removes expired items until the cache is disposed.
+func (c *cache) sweeper(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.sweep)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Lock()
+			c.expire()
+			c.unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Removes all expired items.
+// The caller should hold the cache lock.
+func (c *cache) expire() {
+	now := c.now()
+	for key, cached := range c.items {
+		if cached.expired(now) {
+			c.evict(key, Expired)
+		}
+	}
+}
+
 // Given the need to add some number of new bytes to the cache,
 // evict items according to the eviction policy until there is room.
 // The caller should hold the cache lock.
 func (c *cache) ensureCapacity(toAdd uint64) {
 	mustRemove := int64(c.size+toAdd) - int64(c.cap)
-	for mustRemove > 0 {
+	for mustRemove > 0 && c.keyList.Len() > 0 {
 		key := c.keyList.Back().Value.(string)
 		mustRemove -= int64(c.items[key].item.Size())
-		c.remove(key)
+		c.evict(key, Capacity)
 	}
 }
 
+// Remove the item associated with the given key and record its eviction.
+// The caller should hold the cache lock.
+func (c *cache) evict(key string, reason EvictionReason) {
+	if c.onEvict != nil {
+		c.evictions = append(c.evictions, eviction{key: key, item: c.items[key].item, reason: reason})
+	}
+	c.remove(key)
+}
+
+// Record the eviction of an item the policy already stopped tracking and remove it.
+// The caller should hold the cache lock.
+func (c *cache) evictUntracked(key string, reason EvictionReason) {
+	cached := c.items[key]
+	if c.onEvict != nil {
+		c.evictions = append(c.evictions, eviction{key: key, item: cached.item, reason: reason})
+	}
+	delete(c.items, key)
+	c.size -= cached.item.Size()
+}
+
 // Remove the item associated with the given key.
 // The caller should hold the cache lock.
 func (c *cache) remove(key string) {
 	if cached, ok := c.items[key]; ok {
 		delete(c.items, key)
 		c.size -= cached.item.Size()
-		c.keyList.Remove(cached.element)
+		if c.policy != nil {
+			c.policy.remove(key)
+		} else {
+			c.keyList.Remove(cached.element)
+		}
 	}
 }
 
@@ -3,6 +3,7 @@ package cache
 import (
 	"container/list"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -120,3 +121,122 @@ func TestPutGetRemoveSize(t *testing.T) {
 		assert.Equal(t, testCase.expectedItems, testCase.cache.Get(keys...))
 	}
 }
+
+// A clock that only moves when told to
+type testClock struct {
+	now time.Time
+}
+
+func (tc *testClock) Now() time.Time {
+	return tc.now
+}
+
+func (tc *testClock) Advance(d time.Duration) {
+	tc.now = tc.now.Add(d)
+}
+
+type evicted struct {
+	key    string
+	reason EvictionReason
+}
+
+func TestTTL(t *testing.T) {
+	for _, policy := range []Policy{LeastRecentlyAdded, LeastRecentlyUsed} {
+		var evictions []evicted
+		c := New(10, EvictionPolicy(policy), DefaultTTL(time.Minute), OnEvict(func(key string, item Item, reason EvictionReason) {
+			evictions = append(evictions, evicted{key, reason})
+		})).(*cache)
+		clock := &testClock{now: time.Now()}
+		c.now = clock.Now
+
+		c.Put("foo", testItem(1))
+		c.PutWithTTL("bar", testItem(1), time.Hour)
+		c.PutWithTTL("baz", testItem(1), 0)
+
+		clock.Advance(time.Minute - time.Nanosecond)
+		assert.Equal(t, []Item{testItem(1), testItem(1), testItem(1)}, c.Get("foo", "bar", "baz"))
+		assert.Empty(t, evictions)
+
+		clock.Advance(time.Nanosecond)
+		assert.Equal(t, []Item{nil, testItem(1), testItem(1)}, c.Get("foo", "bar", "baz"))
+		assert.Equal(t, uint64(2), c.Size())
+
+		clock.Advance(time.Hour)
+		assert.Equal(t, []Item{nil, nil, testItem(1)}, c.Get("foo", "bar", "baz"))
+		assert.Equal(t, uint64(1), c.Size())
+		assert.Equal(t, []evicted{{"foo", Expired}, {"bar", Expired}}, evictions)
+	}
+}
+
+func TestTTLReplaced(t *testing.T) {
+	c := New(10, DefaultTTL(time.Minute)).(*cache)
+	clock := &testClock{now: time.Now()}
+	c.now = clock.Now
+
+	c.Put("foo", testItem(1))
+	clock.Advance(30 * time.Second)
+	c.Put("foo", testItem(2))
+	clock.Advance(45 * time.Second)
+	assert.Equal(t, []Item{testItem(2)}, c.Get("foo"))
+}
+
+func TestCapacityEvictionCallback(t *testing.T) {
+	var evictions []evicted
+	c := New(2, OnEvict(func(key string, item Item, reason EvictionReason) {
+		evictions = append(evictions, evicted{key, reason})
+	}))
+
+	c.Put("foo", testItem(1))
+	c.Put("bar", testItem(1))
+	c.Remove("bar")
+	c.Put("baz", testItem(2))
+	assert.Equal(t, []evicted{{"bar", Removed}, {"foo", Capacity}}, evictions)
+}
+
+func TestEvictionCallbackUnlocked(t *testing.T) {
+	var c Cache
+	c = New(1, OnEvict(func(key string, item Item, reason EvictionReason) {
+		// Would deadlock if called with the lock held
+		c.Get(key)
+	}))
+
+	c.Put("foo", testItem(1))
+	c.Put("bar", testItem(1))
+	assert.Equal(t, []Item{testItem(1)}, c.Get("bar"))
+}
+
+func TestSweeper(t *testing.T) {
+	evictions := make(chan string, 1)
+	c := New(10, SweepInterval(time.Millisecond), OnEvict(func(key string, item Item, reason EvictionReason) {
+		evictions <- key
+	})).(*cache)
+	defer c.Dispose()
+
+	c.PutWithTTL("foo", testItem(1), time.Millisecond)
+	c.Put("bar", testItem(1))
+
+	select {
+	case key := <-evictions:
+		assert.Equal(t, "foo", key)
+	case <-time.After(time.Second):
+		t.Fatal("expired item not swept")
+	}
+
+	c.Lock()
+	assert.Len(t, c.items, 1)
+	c.Unlock()
+}
+
+func TestDispose(t *testing.T) {
+	c := New(10, SweepInterval(time.Millisecond)).(*cache)
+	stop := c.stop
+	c.Dispose()
+	c.Dispose()
+
+	_, ok := <-stop
+	assert.False(t, ok)
+	assert.Nil(t, c.stop)
+
+	// Disposing a cache without a sweeper does nothing
+	New(10).Dispose()
+}
@@ -0,0 +1,99 @@
+package cache
+
+import "container/heap"
+
+// An item tracked by the LFU policy
+type lfuEntry struct {
+	key   string
+	size  uint64
+	hits  uint64 // Number of times the item was added or accessed
+	tick  uint64 // Logical time of the item's last use, breaking ties between equal hits
+	index int    // Position in the heap
+}
+
+// A min-heap of entries ordered by hits, then by last use
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].hits != h[j].hits {
+		return h[i].hits < h[j].hits
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	e := x.(*lfuEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+// Least-frequently-used policy: evicts the items used the fewest times,
+// and among those the least recently used.
+type lfuPolicy struct {
+	cap     uint64               // Capacity bound
+	size    uint64               // Cumulative size
+	tick    uint64               // Logical clock
+	entries map[string]*lfuEntry // Map from keys to tracked items
+	heap    lfuHeap              // Items in order of increasing frequency
+}
+
+func newLFUPolicy(capacity uint64) *lfuPolicy {
+	return &lfuPolicy{
+		cap:     capacity,
+		entries: map[string]*lfuEntry{},
+	}
+}
+
+func (p *lfuPolicy) add(key string, size uint64) []string {
+	if size > p.cap {
+		return []string{key}
+	}
+
+	var evicted []string
+	for p.size+size > p.cap {
+		e := heap.Pop(&p.heap).(*lfuEntry)
+		delete(p.entries, e.key)
+		p.size -= e.size
+		evicted = append(evicted, e.key)
+	}
+
+	p.tick++
+	e := &lfuEntry{key: key, size: size, hits: 1, tick: p.tick}
+	heap.Push(&p.heap, e)
+	p.entries[key] = e
+	p.size += size
+	return evicted
+}
+
+func (p *lfuPolicy) access(key string) {
+	if e, ok := p.entries[key]; ok {
+		p.tick++
+		e.hits++
+		e.tick = p.tick
+		heap.Fix(&p.heap, e.index)
+	}
+}
+
+func (p *lfuPolicy) remove(key string) {
+	if e, ok := p.entries[key]; ok {
+		heap.Remove(&p.heap, e.index)
+		delete(p.entries, key)
+		p.size -= e.size
+	}
+}
@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNoLoader is returned by GetOrLoad and GetAllOrLoad for a cache configured without a loader.
+var ErrNoLoader = errors.New("cache: no loader configured")
+
+// Loader loads the item for a key missing from a cache.
+// If it returns a nil item and no error, nothing is added to the cache.
+// If it panics, the callers waiting on the load get an error instead.
+type Loader func(key string) (Item, error)
+
+// BulkLoader loads the items for several keys missing from a cache.
+// Keys missing from the returned map are not added to the cache.
+type BulkLoader func(keys []string) (map[string]Item, error)
+
+// Loading sets the function used by GetOrLoad and GetAllOrLoad to load missing items.
+func Loading(loader Loader) CacheOption {
+	return func(c *cache) {
+		c.loader = loader
+	}
+}
+
+// BulkLoading sets the function used by GetAllOrLoad to load several missing items at once.
+// It is also used by GetOrLoad if no Loader is configured.
+func BulkLoading(loader BulkLoader) CacheOption {
+	return func(c *cache) {
+		c.bulkLoader = loader
+	}
+}
+
+// NegativeTTL sets how long a failed load is remembered for. Until then, getting
+// the key returns the same error without loading it again, unless an item is put
+// under the key in the meantime. If not provided, failed loads are not remembered.
+func NegativeTTL(ttl time.Duration) CacheOption {
+	return func(c *cache) {
+		c.negativeTTL = ttl
+	}
+}
+
+// A remembered failed load
+type failure struct {
+	err     error
+	expires time.Time
+}
+
+// A load in progress that callers getting the same key wait on
+type load struct {
+	done  chan struct{} // Closed once the load completed
+	item  Item
+	err   error
+	stale bool // Whether the key was put or removed while loading, so the result must not be added
+}
+
+func (c *cache) GetOrLoad(key string) (Item, error) {
+	items, err := c.GetAllOrLoad(key)
+	if items == nil {
+		return nil, err
+	}
+	return items[0], err
+}
+
+func (c *cache) GetAllOrLoad(keys ...string) ([]Item, error) {
+	if c.loader == nil && c.bulkLoader == nil {
+		return nil, ErrNoLoader
+	}
+
+	var (
+		items    = make([]Item, len(keys))
+		err      error
+		started  = map[string]*load{} // Loads started by this call
+		waiting  []int                // Positions of keys being loaded
+		awaiting []*load              // Loads of the keys at those positions
+	)
+
+	c.Lock()
+	now := c.now()
+	for i, key := range keys {
+		if items[i] = c.get(key, now); items[i] != nil {
+			continue
+		}
+
+		if f, ok := c.failures[key]; ok {
+			if now.Before(f.expires) {
+				if err == nil {
+					err = f.err
+				}
+				continue
+			}
+			delete(c.failures, key)
+		}
+
+		l, ok := c.loads[key]
+		if !ok {
+			l = &load{done: make(chan struct{})}
+			c.loads[key] = l
+			started[key] = l
+		}
+		waiting = append(waiting, i)
+		awaiting = append(awaiting, l)
+	}
+	c.unlock()
+
+	if len(started) > 0 {
+		c.load(started)
+	}
+
+	for j, l := range awaiting {
+		<-l.done
+		items[waiting[j]] = l.item
+		if l.err != nil && err == nil {
+			err = l.err
+		}
+	}
+
+	return items, err
+}
+
+// Loads the items of the given keys, adds them to the cache and completes their loads.
+func (c *cache) load(loads map[string]*load) {
+	defer c.complete(loads)
+
+	switch {
+	case c.bulkLoader != nil && (len(loads) > 1 || c.loader == nil):
+		keys := make([]string, 0, len(loads))
+		for key := range loads {
+			keys = append(keys, key)
+		}
+
+		found, err := c.loadBulk(keys)
+		for key, l := range loads {
+			if err != nil {
+				l.err = err
+			} else {
+				l.item = found[key]
+			}
+		}
+	case len(loads) == 1:
+		for key, l := range loads {
+			l.item, l.err = c.loadOne(key)
+		}
+	default:
+		var wg sync.WaitGroup
+		wg.Add(len(loads))
+		for key, l := range loads {
+			go func(key string, l *load) {
+				defer wg.Done()
+				l.item, l.err = c.loadOne(key)
+			}(key, l)
+		}
+		wg.Wait()
+	}
+}
+
+// Calls the Loader, turning a panic into an error.
+func (c *cache) loadOne(key string) (item Item, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			item, err = nil, fmt.Errorf("cache: loader panicked: %v", r)
+		}
+	}()
+	return c.loader(key)
+}
+
+// Calls the BulkLoader, turning a panic into an error.
+func (c *cache) loadBulk(keys []string) (items map[string]Item, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			items, err = nil, fmt.Errorf("cache: bulk loader panicked: %v", r)
+		}
+	}()
+	return c.bulkLoader(keys)
+}
+
+// Adds the results of the given loads to the cache and releases the callers waiting on them.
+func (c *cache) complete(loads map[string]*load) {
+	defer func() {
+		for _, l := range loads {
+			close(l.done)
+		}
+	}()
+
+	c.Lock()
+	defer c.unlock()
+
+	for key := range loads {
+		delete(c.loads, key)
+	}
+
+	now := c.now()
+	for key, l := range loads {
+		switch {
+		case l.stale:
+		case l.err != nil:
+			l.item = nil
+			if c.negativeTTL > 0 {
+				c.failures[key] = &failure{err: l.err, expires: now.Add(c.negativeTTL)}
+			}
+		case l.item != nil:
+			c.put(key, l.item, c.ttl)
+		}
+	}
+}
+
+// Forget any failed load of the given key, and keep a load in progress from adding its result.
+// The caller should hold the cache lock.
+func (c *cache) forget(key string) {
+	delete(c.failures, key)
+	if l, ok := c.loads[key]; ok {
+		l.stale = true
+	}
+}
@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	c := New(10, Loading(func(key string) (Item, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return testItem(len(key)), nil
+	}))
+
+	var wg sync.WaitGroup
+	items := make([]Item, 10)
+	for i := range items {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			item, err := c.GetOrLoad("foo")
+			assert.NoError(t, err)
+			items[i] = item
+		}(i)
+	}
+
+	// Let every caller reach the load before it completes
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for _, item := range items {
+		assert.Equal(t, testItem(3), item)
+	}
+	assert.Equal(t, []Item{testItem(3)}, c.Get("foo"))
+
+	// Cached items aren't loaded again
+	item, err := c.GetOrLoad("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, testItem(3), item)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestGetAllOrLoad(t *testing.T) {
+	var loaded [][]string
+	c := New(10, BulkLoading(func(keys []string) (map[string]Item, error) {
+		sort.Strings(keys)
+		loaded = append(loaded, keys)
+		return map[string]Item{"bar": testItem(2), "baz": testItem(3)}, nil
+	}))
+	c.Put("foo", testItem(1))
+
+	items, err := c.GetAllOrLoad("foo", "bar", "baz", "qux")
+	assert.NoError(t, err)
+	assert.Equal(t, []Item{testItem(1), testItem(2), testItem(3), nil}, items)
+	assert.Equal(t, [][]string{{"bar", "baz", "qux"}}, loaded)
+	assert.Equal(t, []Item{testItem(2), testItem(3), nil}, c.Get("bar", "baz", "qux"))
+
+	// Without a Loader, GetOrLoad uses the BulkLoader
+	item, err := c.GetOrLoad("qux")
+	assert.NoError(t, err)
+	assert.Nil(t, item)
+	assert.Equal(t, []string{"qux"}, loaded[1])
+}
+
+func TestGetAllOrLoadPerKey(t *testing.T) {
+	var calls int32
+	c := New(10, Loading(func(key string) (Item, error) {
+		atomic.AddInt32(&calls, 1)
+		if key == "bad" {
+			return nil, errors.New(key)
+		}
+		return testItem(len(key)), nil
+	}))
+
+	items, err := c.GetAllOrLoad("foo", "bad", "quux")
+	assert.EqualError(t, err, "bad")
+	assert.Equal(t, []Item{testItem(3), nil, testItem(4)}, items)
+	assert.Equal(t, int32(3), calls)
+}
+
+func TestNegativeTTL(t *testing.T) {
+	var calls int
+	failing := errors.New("failing")
+	c := New(10, NegativeTTL(time.Minute), Loading(func(key string) (Item, error) {
+		calls++
+		return nil, failing
+	})).(*cache)
+	clock := &testClock{now: time.Now()}
+	c.now = clock.Now
+
+	for i := 0; i < 2; i++ {
+		item, err := c.GetOrLoad("foo")
+		assert.Equal(t, failing, err)
+		assert.Nil(t, item)
+	}
+	assert.Equal(t, 1, calls)
+
+	// The failure is forgotten once expired
+	clock.Advance(time.Minute)
+	_, err := c.GetOrLoad("foo")
+	assert.Equal(t, failing, err)
+	assert.Equal(t, 2, calls)
+
+	// or once an item is put
+	c.Put("foo", testItem(1))
+	c.Remove("foo")
+	_, err = c.GetOrLoad("foo")
+	assert.Equal(t, failing, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestLoadFailuresNotRemembered(t *testing.T) {
+	var calls int
+	c := New(10, Loading(func(key string) (Item, error) {
+		calls++
+		return nil, errors.New("failing")
+	}))
+
+	c.GetOrLoad("foo")
+	c.GetOrLoad("foo")
+	assert.Equal(t, 2, calls)
+}
+
+func TestStaleLoad(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	c := New(10, Loading(func(key string) (Item, error) {
+		close(started)
+		<-release
+		return testItem(1), nil
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		item, err := c.GetOrLoad("foo")
+		assert.NoError(t, err)
+		assert.Equal(t, testItem(1), item)
+	}()
+
+	// An item put while loading isn't overwritten by the load
+	<-started
+	c.Put("foo", testItem(2))
+	close(release)
+	<-done
+	assert.Equal(t, []Item{testItem(2)}, c.Get("foo"))
+}
+
+func TestLoaderPanic(t *testing.T) {
+	var calls int
+	c := New(10, Loading(func(key string) (Item, error) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		return testItem(1), nil
+	}))
+
+	item, err := c.GetOrLoad("foo")
+	assert.EqualError(t, err, "cache: loader panicked: boom")
+	assert.Nil(t, item)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		item, err := c.GetOrLoad("foo")
+		assert.NoError(t, err)
+		assert.Equal(t, testItem(1), item)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GetOrLoad blocked after the loader panicked")
+	}
+}
+
+func TestBulkLoaderPanic(t *testing.T) {
+	c := New(10, BulkLoading(func(keys []string) (map[string]Item, error) {
+		panic("boom")
+	}))
+
+	items, err := c.GetAllOrLoad("foo", "bar")
+	assert.EqualError(t, err, "cache: bulk loader panicked: boom")
+	assert.Equal(t, []Item{nil, nil}, items)
+	assert.Empty(t, c.(*cache).loads)
+}
+
+func TestNoLoader(t *testing.T) {
+	c := New(10)
+	_, err := c.GetOrLoad("foo")
+	assert.Equal(t, ErrNoLoader, err)
+	_, err = c.GetAllOrLoad("foo")
+	assert.Equal(t, ErrNoLoader, err)
+}
+
+func TestRemovedReplacedCallback(t *testing.T) {
+	var evictions []evicted
+	c := New(10, OnEvict(func(key string, item Item, reason EvictionReason) {
+		evictions = append(evictions, evicted{key, reason})
+	}))
+
+	c.Put("foo", testItem(1))
+	c.Put("foo", testItem(2))
+	c.Remove("foo", "bar")
+	assert.Equal(t, []evicted{{"foo", Replaced}, {"foo", Removed}}, evictions)
+}
+
+func TestShardedGetAllOrLoad(t *testing.T) {
+	var lock sync.Mutex
+	var loaded []string
+	c := NewSharded(100, 4, BulkLoading(func(keys []string) (map[string]Item, error) {
+		lock.Lock()
+		loaded = append(loaded, keys...)
+		lock.Unlock()
+
+		items := map[string]Item{}
+		for _, key := range keys {
+			items[key] = testItem(len(key))
+		}
+		return items, nil
+	}))
+
+	keys := []string{"a", "bb", "ccc", "dddd", "eeeee", "ffffff"}
+	items, err := c.GetAllOrLoad(keys...)
+	assert.NoError(t, err)
+	for i, key := range keys {
+		assert.Equal(t, testItem(len(key)), items[i])
+	}
+	sort.Strings(loaded)
+	assert.Equal(t, keys, loaded)
+
+	item, err := c.GetOrLoad("a")
+	assert.NoError(t, err)
+	assert.Equal(t, testItem(1), item)
+	assert.Len(t, loaded, len(keys))
+}
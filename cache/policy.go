@@ -0,0 +1,74 @@
+package cache
+
+import "container/list"
+
+// A size-aware eviction policy that decides on its own which items to evict.
+// The simple recency policies are tracked by the cache's eviction list instead,
+// the policies that need more bookkeeping implement this.
+type policy interface {
+	// Records that an item of the given size was added under a key not currently tracked,
+	// and returns the keys of the items to evict to stay within capacity.
+	// The added key is among them if the policy declines to admit it.
+	add(key string, size uint64) []string
+
+	// Records that the item with the given key was accessed
+	access(key string)
+
+	// Stops tracking the item with the given key, if tracked
+	remove(key string)
+}
+
+// An item tracked by a policy and its position in one of the policy's lists
+type entry struct {
+	key     string
+	size    uint64
+	list    *lruList
+	element *list.Element
+}
+
+// A list of entries in order of recency that keeps track of their cumulative size
+type lruList struct {
+	entries *list.List
+	size    uint64
+}
+
+func newLRUList() *lruList {
+	return &lruList{entries: list.New()}
+}
+
+// Adds the entry as most recently used
+func (l *lruList) pushFront(e *entry) {
+	e.list = l
+	e.element = l.entries.PushFront(e)
+	l.size += e.size
+}
+
+// Marks the entry, which must be in this list, as most recently used
+func (l *lruList) moveToFront(e *entry) {
+	l.entries.MoveToFront(e.element)
+}
+
+// Removes the entry, which must be in this list
+func (l *lruList) remove(e *entry) {
+	l.entries.Remove(e.element)
+	l.size -= e.size
+	e.list = nil
+	e.element = nil
+}
+
+// Returns the least recently used entry, or nil if the list is empty
+func (l *lruList) back() *entry {
+	if element := l.entries.Back(); element != nil {
+		return element.Value.(*entry)
+	}
+	return nil
+}
+
+// Removes and returns the least recently used entry, or nil if the list is empty
+func (l *lruList) popBack() *entry {
+	e := l.back()
+	if e != nil {
+		l.remove(e)
+	}
+	return e
+}
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var policies = map[string]Policy{
+	"LRA":      LeastRecentlyAdded,
+	"LRU":      LeastRecentlyUsed,
+	"LFU":      LeastFrequentlyUsed,
+	"ARC":      AdaptiveReplacement,
+	"WTinyLFU": WindowTinyLFU,
+}
+
+func TestLFUPolicy(t *testing.T) {
+	c := New(3, EvictionPolicy(LeastFrequentlyUsed))
+	c.Put("foo", testItem(1))
+	c.Put("bar", testItem(1))
+	c.Put("baz", testItem(1))
+	c.Get("foo", "foo", "bar")
+
+	// baz has the fewest hits
+	c.Put("qux", testItem(1))
+	assert.Equal(t, []Item{testItem(1), testItem(1), nil, testItem(1)}, c.Get("foo", "bar", "baz", "qux"))
+
+	// qux has the fewest hits, then foo ties with bar but was used longer ago
+	c.Get("bar")
+	c.Put("quux", testItem(2))
+	assert.Equal(t, []Item{nil, testItem(1), nil, testItem(2)}, c.Get("foo", "bar", "qux", "quux"))
+	assert.Equal(t, uint64(3), c.Size())
+}
+
+func TestARCPolicy(t *testing.T) {
+	p := newARCPolicy(4)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		assert.Empty(t, p.add(key, 1))
+	}
+	p.access("a")
+	p.access("b")
+
+	// Items used once are evicted first
+	assert.Equal(t, []string{"c"}, p.add("e", 1))
+	assert.Equal(t, p.b1, p.entries["c"].list)
+
+	// A hit on a ghost of t1 grows its target and enters t2
+	assert.Equal(t, []string{"d"}, p.add("c", 1))
+	assert.Equal(t, uint64(1), p.target)
+	assert.Equal(t, p.t2, p.entries["c"].list)
+	assert.Equal(t, uint64(4), p.t1.size+p.t2.size)
+
+	p.remove("c")
+	_, ok := p.entries["c"]
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"big"}, p.add("big", 5))
+}
+
+func TestARCGhostsBounded(t *testing.T) {
+	p := newARCPolicy(10)
+	for i := 0; i < 1000; i++ {
+		p.add(strconv.Itoa(i), 1)
+		if i%3 == 0 {
+			p.access(strconv.Itoa(i))
+		}
+	}
+
+	assert.True(t, p.t1.size+p.t2.size <= 10)
+	assert.True(t, p.t1.size+p.b1.size <= 10)
+	assert.True(t, p.t1.size+p.t2.size+p.b1.size+p.b2.size <= 20)
+	assert.Equal(t, p.t1.entries.Len()+p.t2.entries.Len()+p.b1.entries.Len()+p.b2.entries.Len(), len(p.entries))
+}
+
+func TestSketch(t *testing.T) {
+	s := newSketch(1)
+	assert.Equal(t, uint64(sketchMinWidth), s.width())
+	assert.Equal(t, uint64(64), newSketch(33).width())
+
+	for i := 0; i < 20; i++ {
+		s.increment("foo")
+	}
+	assert.Equal(t, uint8(sketchMaxCount), s.estimate("foo"))
+	assert.True(t, s.estimate("bar") < s.estimate("foo"))
+
+	// Counters are halved once enough increments are made
+	s.additions = s.reset - 1
+	s.increment("bar")
+	assert.Equal(t, uint8(sketchMaxCount/2), s.estimate("foo"))
+	assert.Equal(t, s.reset/2, s.additions)
+}
+
+func TestTinyLFUPolicy(t *testing.T) {
+	p := newTinyLFUPolicy(100)
+	assert.Equal(t, uint64(1), p.windowCap)
+	assert.Equal(t, uint64(79), p.protectedCap)
+
+	for i := 0; i < 99; i++ {
+		assert.Empty(t, p.add(strconv.Itoa(i), 1))
+		p.access(strconv.Itoa(i))
+	}
+	assert.Equal(t, uint64(98), p.probation.size+p.protected.size)
+
+	// The window makes room for the last key, which still fits the main space
+	assert.Empty(t, p.add("scan", 1))
+	assert.Equal(t, uint64(99), p.probation.size+p.protected.size)
+
+	// A key seen once doesn't displace the popular ones when it leaves the window
+	assert.Equal(t, []string{"scan"}, p.add("new", 1))
+	_, ok := p.entries["scan"]
+	assert.False(t, ok)
+
+	// A key seen often does
+	for i := 0; i < sketchMaxCount; i++ {
+		p.sketch.increment("hot")
+	}
+	assert.Equal(t, []string{"new"}, p.add("hot", 1))
+	evicted := p.add("next", 1)
+	assert.Len(t, evicted, 1)
+	assert.NotEqual(t, "hot", evicted[0])
+	assert.Equal(t, p.probation, p.entries["hot"].list)
+
+	p.access("hot")
+	assert.Equal(t, p.protected, p.entries["hot"].list)
+	assert.True(t, p.protected.size <= p.protectedCap)
+
+	p.remove("hot")
+	_, ok = p.entries["hot"]
+	assert.False(t, ok)
+}
+
+func TestPolicyCapacity(t *testing.T) {
+	for name, policy := range policies {
+		var evictions int
+		c := New(100, EvictionPolicy(policy), OnEvict(func(string, Item, EvictionReason) {
+			evictions++
+		})).(*cache)
+
+		r := rand.New(rand.NewSource(1))
+		var added int
+		for i := 0; i < 10000; i++ {
+			key := strconv.Itoa(r.Intn(500))
+			switch r.Intn(10) {
+			case 0:
+				c.Remove(key)
+			case 1, 2, 3:
+				c.Put(key, testItem(1+r.Intn(20)))
+				added++
+			default:
+				c.Get(key)
+			}
+
+			assert.True(t, c.Size() <= 100, name)
+		}
+
+		var size uint64
+		for _, cached := range c.items {
+			size += cached.item.Size()
+		}
+		assert.Equal(t, size, c.Size(), name)
+		assert.Equal(t, added, len(c.items)+evictions, name)
+	}
+}
+
+func TestEvictionPolicyReset(t *testing.T) {
+	c := New(10, EvictionPolicy(WindowTinyLFU), EvictionPolicy(LeastRecentlyUsed)).(*cache)
+	assert.Nil(t, c.policy)
+}
+
+// Runs a trace of keys against a cache of the given policy and capacity,
+// putting every missed key, and reports the hit ratio.
+func benchmarkHitRatio(b *testing.B, policy Policy, capacity uint64, trace func(r *rand.Rand) func() string) {
+	c := New(capacity, EvictionPolicy(policy))
+	next := trace(rand.New(rand.NewSource(1)))
+	hits := 0
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := next()
+		if c.Get(key)[0] != nil {
+			hits++
+		} else {
+			c.Put(key, testItem(1))
+		}
+	}
+
+	b.ReportMetric(float64(hits)/float64(b.N)*100, "%hit")
+}
+
+// A trace of keys following a Zipf distribution
+func zipfTrace(r *rand.Rand) func() string {
+	zipf := rand.NewZipf(r, 1.01, 1, 100000)
+	return func() string {
+		return strconv.FormatUint(zipf.Uint64(), 10)
+	}
+}
+
+// A Zipf trace interrupted by scans over keys never seen again
+func zipfScanTrace(r *rand.Rand) func() string {
+	next := zipfTrace(r)
+	scan, i := 0, 0
+	return func() string {
+		i++
+		if i%10000 < 2000 {
+			scan++
+			return "scan" + strconv.Itoa(scan)
+		}
+		return next()
+	}
+}
+
+func BenchmarkZipfHitRatio(b *testing.B) {
+	for name, policy := range policies {
+		b.Run(name, func(b *testing.B) {
+			benchmarkHitRatio(b, policy, 1000, zipfTrace)
+		})
+	}
+}
+
+func BenchmarkZipfScanHitRatio(b *testing.B) {
+	for name, policy := range policies {
+		b.Run(name, func(b *testing.B) {
+			benchmarkHitRatio(b, policy, 1000, zipfScanTrace)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"runtime"
+	"time"
+)
+
+// A cache split into independently locked shards, each holding the items
+// of the keys that hash to it under its own eviction policy and a share of the capacity
+type shardedCache struct {
+	shards []*cache
+}
+
+// NewSharded returns a cache that splits keys across the given number of shards,
+// so operations on keys of different shards don't contend for the same lock.
+// Every shard is configured with the provided options and an equal share of the capacity,
+// so eviction decisions are made per shard rather than across the whole cache.
+// If shards is zero or less, the number of CPUs is used.
+func NewSharded(capacity uint64, shards int, options ...CacheOption) Cache {
+	if shards <= 0 {
+		shards = runtime.NumCPU()
+	}
+
+	sc := &shardedCache{shards: make([]*cache, shards)}
+	for i := range sc.shards {
+		// Spread the remainder over the first shards
+		share := capacity / uint64(shards)
+		if uint64(i) < capacity%uint64(shards) {
+			share++
+		}
+		sc.shards[i] = New(share, options...).(*cache)
+	}
+
+	return sc
+}
+
+// Returns the index of the shard holding the given key
+func (sc *shardedCache) shard(key string) int {
+	return int(hashKey(key) % uint64(len(sc.shards)))
+}
+
+// Groups the positions of the given keys by the shard holding them
+func (sc *shardedCache) group(keys []string) map[int][]int {
+	groups := make(map[int][]int, len(sc.shards))
+	for i, key := range keys {
+		shard := sc.shard(key)
+		groups[shard] = append(groups[shard], i)
+	}
+	return groups
+}
+
+// Returns the keys at the given positions
+func keysAt(keys []string, positions []int) []string {
+	selected := make([]string, len(positions))
+	for i, position := range positions {
+		selected[i] = keys[position]
+	}
+	return selected
+}
+
+func (sc *shardedCache) Get(keys ...string) []Item {
+	if len(keys) == 1 {
+		return sc.shards[sc.shard(keys[0])].Get(keys[0])
+	}
+
+	items := make([]Item, len(keys))
+	for shard, positions := range sc.group(keys) {
+		for i, item := range sc.shards[shard].Get(keysAt(keys, positions)...) {
+			items[positions[i]] = item
+		}
+	}
+
+	return items
+}
+
+func (sc *shardedCache) Put(key string, item Item) {
+	sc.shards[sc.shard(key)].Put(key, item)
+}
+
+func (sc *shardedCache) PutWithTTL(key string, item Item, ttl time.Duration) {
+	sc.shards[sc.shard(key)].PutWithTTL(key, item, ttl)
+}
+
+func (sc *shardedCache) Remove(keys ...string) {
+	for shard, positions := range sc.group(keys) {
+		sc.shards[shard].Remove(keysAt(keys, positions)...)
+	}
+}
+
+func (sc *shardedCache) Size() uint64 {
+	var size uint64
+	for _, shard := range sc.shards {
+		size += shard.Size()
+	}
+	return size
+}
+
+func (sc *shardedCache) GetOrLoad(key string) (Item, error) {
+	return sc.shards[sc.shard(key)].GetOrLoad(key)
+}
+
+// GetAllOrLoad loads the missing items of every shard separately,
+// so a BulkLoader is called once per shard with missing items.
+func (sc *shardedCache) GetAllOrLoad(keys ...string) ([]Item, error) {
+	items := make([]Item, len(keys))
+	var err error
+	for shard, positions := range sc.group(keys) {
+		shardItems, shardErr := sc.shards[shard].GetAllOrLoad(keysAt(keys, positions)...)
+		if shardErr == ErrNoLoader {
+			return nil, shardErr
+		}
+		if shardErr != nil && err == nil {
+			err = shardErr
+		}
+		for i, item := range shardItems {
+			items[positions[i]] = item
+		}
+	}
+
+	return items, err
+}
+
+func (sc *shardedCache) Dispose() {
+	for _, shard := range sc.shards {
+		shard.Dispose()
+	}
+}
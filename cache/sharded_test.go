@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSharded(t *testing.T) {
+	c := NewSharded(10, 4, EvictionPolicy(LeastFrequentlyUsed)).(*shardedCache)
+	assert.Len(t, c.shards, 4)
+
+	caps := make([]uint64, 0, 4)
+	for _, shard := range c.shards {
+		caps = append(caps, shard.cap)
+		assert.NotNil(t, shard.policy)
+	}
+	assert.Equal(t, []uint64{3, 3, 2, 2}, caps)
+
+	assert.Len(t, NewSharded(10, 0).(*shardedCache).shards, runtime.NumCPU())
+}
+
+func TestShardedPutGetRemoveSize(t *testing.T) {
+	c := NewSharded(1000, 8)
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		c.Put(keys[i], testItem(i%5+1))
+	}
+	c.PutWithTTL("ttl", testItem(1), time.Hour)
+
+	items := c.Get(keys...)
+	for i, item := range items {
+		assert.Equal(t, testItem(i%5+1), item)
+	}
+	assert.Equal(t, []Item{testItem(1), nil}, c.Get("ttl", "missing"))
+	assert.Equal(t, uint64(301), c.Size())
+
+	c.Remove(keys[:50]...)
+	c.Remove("ttl")
+	assert.Equal(t, uint64(150), c.Size())
+	assert.Equal(t, []Item{nil, testItem(1)}, c.Get("0", "50"))
+
+	c.Dispose()
+}
+
+func TestShardedEviction(t *testing.T) {
+	var evicted []string
+	c := NewSharded(2, 2, OnEvict(func(key string, item Item, reason EvictionReason) {
+		evicted = append(evicted, key)
+	})).(*shardedCache)
+
+	// Fill one shard past its share
+	var keys []string
+	for i := 0; len(keys) < 2; i++ {
+		key := strconv.Itoa(i)
+		if c.shard(key) == 0 {
+			keys = append(keys, key)
+		}
+	}
+	c.Put(keys[0], testItem(1))
+	c.Put(keys[1], testItem(1))
+	assert.Equal(t, []string{keys[0]}, evicted)
+}
+
+func TestShardedItemLargerThanShard(t *testing.T) {
+	for _, policy := range []Policy{LeastRecentlyUsed, LeastRecentlyAdded} {
+		var evictions []evicted
+		c := NewSharded(100, 8, EvictionPolicy(policy), OnEvict(func(key string, item Item, reason EvictionReason) {
+			evictions = append(evictions, evicted{key, reason})
+		})).(*shardedCache)
+		shard := c.shards[c.shard("a")]
+		for i := 0; ; i++ {
+			key := strconv.Itoa(i)
+			if c.shards[c.shard(key)] == shard {
+				c.Put(key, testItem(1))
+				break
+			}
+		}
+
+		// Larger than the shard's share, smaller than the whole cache
+		c.Put("a", testItem(20))
+		assert.Equal(t, []Item{nil}, c.Get("a"))
+		assert.Equal(t, uint64(1), shard.Size())
+		assert.Equal(t, []evicted{{"a", Capacity}}, evictions)
+	}
+}
+
+func TestShardedConcurrent(t *testing.T) {
+	c := NewSharded(1000, 4, EvictionPolicy(WindowTinyLFU))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := strconv.Itoa((g*31 + i) % 200)
+				if c.Get(key)[0] == nil {
+					c.Put(key, testItem(1))
+				}
+				if i%10 == 0 {
+					c.Remove(key)
+				}
+				c.Size()
+			}
+		}(g)
+	}
+	wg.Wait()
+	assert.True(t, c.Size() <= 1000)
+}
+
+func benchmarkParallel(b *testing.B, c Cache) {
+	for i := 0; i < 1000; i++ {
+		c.Put(strconv.Itoa(i), testItem(1))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := strconv.Itoa(i % 2000)
+			if c.Get(key)[0] == nil {
+				c.Put(key, testItem(1))
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkParallelCache(b *testing.B) {
+	benchmarkParallel(b, New(1000))
+}
+
+func BenchmarkParallelShardedCache(b *testing.B) {
+	benchmarkParallel(b, NewSharded(1000, 0))
+}
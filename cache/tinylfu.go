@@ -0,0 +1,218 @@
+package cache
+
+const (
+	windowPercent    = 1  // Share of the capacity given to the admission window, in percent
+	protectedPercent = 80 // Share of the main space given to the protected segment, in percent
+	sketchDepth      = 4  // Number of rows of the count-min sketch
+	sketchMinWidth   = 16 // Smallest number of counters per row of the count-min sketch
+	sketchMaxCount   = 15 // Counters saturate here, as in a 4-bit counter
+	sketchSamples    = 10 // Increments per counter in a row after which all counters are halved
+)
+
+// A count-min sketch estimating how often keys were seen recently.
+// Counters are halved periodically so old popularity fades.
+type sketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions uint64 // Increments since the counters were last halved
+	reset     uint64 // Number of increments at which the counters are halved
+}
+
+func newSketch(width uint64) *sketch {
+	n := uint64(sketchMinWidth)
+	for n < width {
+		n <<= 1
+	}
+
+	s := &sketch{mask: n - 1, reset: n * sketchSamples}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, n)
+	}
+	return s
+}
+
+// Returns the number of counters per row
+func (s *sketch) width() uint64 {
+	return s.mask + 1
+}
+
+// Calls fn with the counter of the key in every row
+func (s *sketch) counters(key string, fn func(*uint8)) {
+	h := hashKey(key)
+	h1, h2 := h, h>>32|1
+	for i := range s.rows {
+		fn(&s.rows[i][(h1+uint64(i)*h2)&s.mask])
+	}
+}
+
+// Records that the key was seen
+func (s *sketch) increment(key string) {
+	s.counters(key, func(c *uint8) {
+		if *c < sketchMaxCount {
+			*c++
+		}
+	})
+
+	s.additions++
+	if s.additions >= s.reset {
+		for _, row := range s.rows {
+			for i := range row {
+				row[i] >>= 1
+			}
+		}
+		s.additions /= 2
+	}
+}
+
+// Raises the counters of the key to at least the given count
+func (s *sketch) seed(key string, count uint8) {
+	s.counters(key, func(c *uint8) {
+		if *c < count {
+			*c = count
+		}
+	})
+}
+
+// Returns an upper bound of how often the key was seen recently
+func (s *sketch) estimate(key string) uint8 {
+	estimate := uint8(sketchMaxCount)
+	s.counters(key, func(c *uint8) {
+		if *c < estimate {
+			estimate = *c
+		}
+	})
+	return estimate
+}
+
+// Hashes a key with FNV-1a, mixed with the MurmurHash3 finalizer so the
+// halves of the hash are usable on their own.
+func hashKey(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// W-TinyLFU policy. New items enter a small LRU window. Items leaving the
+// window compete for a place in the main space, a segmented LRU, with the item
+// that would be evicted from it: the candidate is only admitted if the sketch
+// estimates it is used more often. This keeps one-off items, such as those of a
+// scan, from flushing popular ones, while the window lets bursts of new items
+// build up popularity before they have to compete.
+type tinyLFUPolicy struct {
+	cap          uint64            // Capacity bound
+	windowCap    uint64            // Capacity of the window
+	protectedCap uint64            // Capacity of the protected segment
+	window       *lruList          // Recently added items
+	probation    *lruList          // Items admitted to the main space but not used since
+	protected    *lruList          // Items used while in the main space
+	entries      map[string]*entry // Map from keys to tracked items
+	sketch       *sketch           // Popularity of recently seen keys
+}
+
+func newTinyLFUPolicy(capacity uint64) *tinyLFUPolicy {
+	windowCap := capacity * windowPercent / 100
+	return &tinyLFUPolicy{
+		cap:          capacity,
+		windowCap:    windowCap,
+		protectedCap: (capacity - windowCap) * protectedPercent / 100,
+		window:       newLRUList(),
+		probation:    newLRUList(),
+		protected:    newLRUList(),
+		entries:      map[string]*entry{},
+		sketch:       newSketch(sketchMinWidth),
+	}
+}
+
+func (p *tinyLFUPolicy) add(key string, size uint64) []string {
+	p.sketch.increment(key)
+	if size > p.cap {
+		return []string{key}
+	}
+
+	e := &entry{key: key, size: size}
+	p.entries[key] = e
+	p.window.pushFront(e)
+	if uint64(len(p.entries)) > p.sketch.width() {
+		// Too many keys share counters, move to a wider sketch,
+		// carrying over the popularity of the tracked keys
+		old := p.sketch
+		p.sketch = newSketch(old.width() * 2)
+		for key := range p.entries {
+			p.sketch.seed(key, old.estimate(key))
+		}
+	}
+
+	var evicted []string
+	for p.window.size > p.windowCap {
+		candidate := p.window.popBack()
+		if !p.admit(candidate) {
+			delete(p.entries, candidate.key)
+			evicted = append(evicted, candidate.key)
+			continue
+		}
+
+		for p.probation.size+p.protected.size+candidate.size > p.cap-p.windowCap {
+			victim := p.probation.popBack()
+			if victim == nil {
+				victim = p.protected.popBack()
+			}
+			delete(p.entries, victim.key)
+			evicted = append(evicted, victim.key)
+		}
+		p.probation.pushFront(candidate)
+	}
+
+	return evicted
+}
+
+// Returns whether an item leaving the window should enter the main space
+func (p *tinyLFUPolicy) admit(candidate *entry) bool {
+	mainCap := p.cap - p.windowCap
+	if candidate.size > mainCap {
+		return false
+	}
+	if p.probation.size+p.protected.size+candidate.size <= mainCap {
+		return true
+	}
+
+	victim := p.probation.back()
+	if victim == nil {
+		victim = p.protected.back()
+	}
+	return p.sketch.estimate(candidate.key) > p.sketch.estimate(victim.key)
+}
+
+func (p *tinyLFUPolicy) access(key string) {
+	p.sketch.increment(key)
+	e, ok := p.entries[key]
+	if !ok {
+		return
+	}
+
+	switch e.list {
+	case p.window, p.protected:
+		e.list.moveToFront(e)
+	case p.probation:
+		p.probation.remove(e)
+		p.protected.pushFront(e)
+		for p.protected.size > p.protectedCap {
+			p.probation.pushFront(p.protected.popBack())
+		}
+	}
+}
+
+func (p *tinyLFUPolicy) remove(key string) {
+	if e, ok := p.entries[key]; ok {
+		e.list.remove(e)
+		delete(p.entries, key)
+	}
+}
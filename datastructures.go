@@ -14,6 +14,7 @@ package datastructures
 import (
 	_ "github.com/Workiva/go-datastructures/augmentedtree"
 	_ "github.com/Workiva/go-datastructures/bitarray"
+	_ "github.com/Workiva/go-datastructures/bloom"
 	_ "github.com/Workiva/go-datastructures/btree/palm"
 	_ "github.com/Workiva/go-datastructures/btree/plus"
 	_ "github.com/Workiva/go-datastructures/fibheap"
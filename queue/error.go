@@ -29,4 +29,8 @@ var (
 	// ErrEmptyQueue is returned when an non-applicable queue operation was called
 	// due to the queue's empty item state
 	ErrEmptyQueue = errors.New(`queue: empty queue`)
+
+	// ErrNotQueued is returned when an item referred to by a handle is
+	// no longer in the queue.
+	ErrNotQueued = errors.New(`queue: item not queued`)
 )
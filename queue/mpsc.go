@@ -0,0 +1,182 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// MPSCRingBuffer is a ring buffer for any number of threads putting items
+// and a single thread getting them.  Puts claim positions with CAS
+// operations like RingBuffer, but gets need none as the consumer owns the
+// dequeue position.  Calling Get and Poll from more than one thread is not
+// safe.  A put on full or get on empty call waits with the buffer's wait
+// strategy until an item is retrieved or put, or Dispose is called.
+type MPSCRingBuffer struct {
+	_padding0      [8]uint64
+	queue          uint64
+	_padding1      [8]uint64
+	dequeue        uint64 // Position of the next get, only written by the consumer
+	_padding2      [8]uint64
+	mask, disposed uint64
+	_padding3      [8]uint64
+	nodes          nodes
+	wait           WaitStrategy
+}
+
+// Put adds the provided item to the queue.  If the queue is full, this
+// call will wait until an item is retrieved from the queue or Dispose is
+// called on the queue.  An error will be returned if the queue is disposed.
+func (rb *MPSCRingBuffer) Put(item interface{}) error {
+	_, err := rb.put(item, false)
+	return err
+}
+
+// Offer adds the provided item to the queue if there is space.  If the
+// queue is full, this call will return false.  An error will be returned
+// if the queue is disposed.
+func (rb *MPSCRingBuffer) Offer(item interface{}) (bool, error) {
+	return rb.put(item, true)
+}
+
+func (rb *MPSCRingBuffer) put(item interface{}, offer bool) (bool, error) {
+	var n *node
+	pos := atomic.LoadUint64(&rb.queue)
+L:
+	for {
+		if atomic.LoadUint64(&rb.disposed) == 1 {
+			return false, ErrDisposed
+		}
+
+		n = rb.nodes[pos&rb.mask]
+		seq := atomic.LoadUint64(&n.position)
+		switch dif := int64(seq - pos); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&rb.queue, pos, pos+1) {
+				break L
+			}
+			pos = atomic.LoadUint64(&rb.queue)
+		case dif < 0:
+			// the item put here last time around is yet to be retrieved
+			if offer {
+				return false, nil
+			}
+
+			full, last, claimed := n, seq, pos
+			err := await(rb.wait, &rb.disposed, 0, func() bool {
+				return atomic.LoadUint64(&full.position) != last || atomic.LoadUint64(&rb.queue) != claimed
+			})
+			if err != nil {
+				return false, err
+			}
+			pos = atomic.LoadUint64(&rb.queue)
+		default:
+			pos = atomic.LoadUint64(&rb.queue)
+		}
+	}
+
+	n.data = item
+	atomic.StoreUint64(&n.position, pos+1)
+	rb.wait.Signal()
+	return true, nil
+}
+
+// Get will return the next item in the queue.  This call will wait if the
+// queue is empty until an item is added to the queue or Dispose is called
+// on the queue.  An error will be returned if the queue is disposed.
+func (rb *MPSCRingBuffer) Get() (interface{}, error) {
+	return rb.Poll(0)
+}
+
+// Poll will return the next item in the queue.  This call will wait if the
+// queue is empty until an item is added to the queue, Dispose is called on
+// the queue, or the timeout is reached.  An error will be returned if the
+// queue is disposed or a timeout occurs.  A non-positive timeout will wait
+// indefinitely.
+func (rb *MPSCRingBuffer) Poll(timeout time.Duration) (interface{}, error) {
+	if atomic.LoadUint64(&rb.disposed) == 1 {
+		return nil, ErrDisposed
+	}
+
+	pos := rb.dequeue
+	n := rb.nodes[pos&rb.mask]
+	if atomic.LoadUint64(&n.position) != pos+1 {
+		err := await(rb.wait, &rb.disposed, timeout, func() bool {
+			return atomic.LoadUint64(&n.position) == pos+1
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data := n.data
+	n.data = nil
+	atomic.StoreUint64(&n.position, pos+rb.mask+1)
+	atomic.StoreUint64(&rb.dequeue, pos+1)
+	rb.wait.Signal()
+	return data, nil
+}
+
+// Len returns the number of items in the queue.
+func (rb *MPSCRingBuffer) Len() uint64 {
+	return atomic.LoadUint64(&rb.queue) - atomic.LoadUint64(&rb.dequeue)
+}
+
+// Cap returns the capacity of this ring buffer.
+func (rb *MPSCRingBuffer) Cap() uint64 {
+	return uint64(len(rb.nodes))
+}
+
+// Dispose will dispose of this queue and free any waiting threads
+// in the Put and/or Get methods.  Calling those methods on a disposed
+// queue will return an error.
+func (rb *MPSCRingBuffer) Dispose() {
+	atomic.CompareAndSwapUint64(&rb.disposed, 0, 1)
+	rb.wait.Signal()
+}
+
+// IsDisposed will return a bool indicating if this queue has been
+// disposed.
+func (rb *MPSCRingBuffer) IsDisposed() bool {
+	return atomic.LoadUint64(&rb.disposed) == 1
+}
+
+// NewMPSCRingBuffer will allocate, initialize, and return a multiple
+// producer, single consumer ring buffer with the specified size, which is
+// at least two, waiting with the provided wait strategy.  If the wait
+// strategy is nil, waiting threads yield like those of RingBuffer.
+func NewMPSCRingBuffer(size uint64, wait WaitStrategy) *MPSCRingBuffer {
+	if wait == nil {
+		wait = NewYieldingWait()
+	}
+
+	size = roundUp(size)
+	if size < 2 {
+		// with a single node, a put couldn't tell a full buffer from an empty one
+		size = 2
+	}
+	rb := &MPSCRingBuffer{
+		nodes: make(nodes, size),
+		mask:  size - 1,
+		wait:  wait,
+	}
+	for i := uint64(0); i < size; i++ {
+		rb.nodes[i] = &node{position: i}
+	}
+	return rb
+}
@@ -24,7 +24,11 @@ would be easier to solve.
 
 package queue
 
-import "sync"
+import (
+	"context"
+	"sync"
+	"time"
+)
 
 // Item is an item that can be added to the priority queue.
 type Item interface {
@@ -37,30 +41,67 @@ type Item interface {
 	Compare(other Item) int
 }
 
-type priorityItems []Item
+// Handle refers to an item put to a priority queue, so the item
+// can be updated or removed while it is in the queue.
+type Handle struct {
+	item  Item
+	index int            // Position of the item in the heap, or -1 once it left the queue
+	queue *PriorityQueue // Queue the item was put to
+}
+
+// Item returns the item this handle refers to.
+func (h *Handle) Item() Item {
+	return h.item
+}
+
+type priorityItems []*Handle
 
 func (items *priorityItems) swap(i, j int) {
 	(*items)[i], (*items)[j] = (*items)[j], (*items)[i]
+	(*items)[i].index = i
+	(*items)[j].index = j
 }
 
 func (items *priorityItems) pop() Item {
+	return items.remove(0).item
+}
+
+// remove takes the item at the provided index out of the heap.
+func (items *priorityItems) remove(index int) *Handle {
 	size := len(*items)
 
-	// Move last leaf to root, and 'pop' the last item.
-	items.swap(size-1, 0)
-	item := (*items)[size-1] // Item to return.
+	// Move last leaf to the removed position, and 'pop' the last item.
+	items.swap(size-1, index)
+	handle := (*items)[size-1] // Item to return.
 	(*items)[size-1], *items = nil, (*items)[:size-1]
+	handle.index = -1
+
+	if index < len(*items) {
+		items.fix(index)
+	}
+
+	return handle
+}
 
-	// 'Bubble down' to restore heap property.
-	index := 0
+// fix restores the heap property after the priority of the item
+// at the provided index changed.
+func (items *priorityItems) fix(index int) {
+	if !items.down(index) {
+		items.up(index)
+	}
+}
+
+// 'Bubble down' to restore heap property, returning whether the item moved.
+func (items *priorityItems) down(index int) bool {
+	start := index
 	childL, childR := 2*index+1, 2*index+2
 	for len(*items) > childL {
 		child := childL
-		if len(*items) > childR && (*items)[childR].Compare((*items)[childL]) < 0 {
+		if len(*items) > childR && (*items)[childR].item.Compare((*items)[childL].item) < 0 {
 			child = childR
 		}
 
-		if (*items)[child].Compare((*items)[index]) < 0 {
+		if (*items)[child].item.Compare((*items)[index].item) < 0 {
 			items.swap(index, child)
 
 			index = child
@@ -70,13 +111,24 @@ func (items *priorityItems) pop() Item {
 		}
 	}
 
-	return item
+	return index != start
+}
+
+// 'Bubble up' to restore heap property.
+func (items *priorityItems) up(index int) {
+	parent := int((index - 1) / 2)
+	for index > 0 && (*items)[parent].item.Compare((*items)[index].item) > 0 {
+		items.swap(index, parent)
+
+		index = parent
+		parent = int((index - 1) / 2)
+	}
 }
 
 func (items *priorityItems) get(number int) []Item {
 	returnItems := make([]Item, 0, number)
 	for i := 0; i < number; i++ {
-		if i >= len(*items) {
+		if len(*items) == 0 {
 			break
 		}
 
@@ -86,19 +138,12 @@ func (items *priorityItems) get(number int) []Item {
 	return returnItems
 }
 
-func (items *priorityItems) push(item Item) {
+func (items *priorityItems) push(handle *Handle) {
 	// Stick the item as the end of the last level.
-	*items = append(*items, item)
-
-	// 'Bubble up' to restore heap property.
-	index := len(*items) - 1
-	parent := int((index - 1) / 2)
-	for parent >= 0 && (*items)[parent].Compare(item) > 0 {
-		items.swap(index, parent)
+	handle.index = len(*items)
+	*items = append(*items, handle)
 
-		index = parent
-		parent = int((index - 1) / 2)
-	}
+	items.up(handle.index)
 }
 
 // PriorityQueue is similar to queue except that it takes
@@ -107,33 +152,41 @@ func (items *priorityItems) push(item Item) {
 type PriorityQueue struct {
 	waiters         waiters
 	items           priorityItems
-	itemMap         map[Item]struct{}
+	itemMap         map[Item]*Handle
 	lock            sync.Mutex
 	disposeLock     sync.Mutex
 	disposed        bool
 	allowDuplicates bool
 }
 
-// Put adds items to the queue.
-func (pq *PriorityQueue) Put(items ...Item) error {
+// Put adds items to the queue, returning a handle for each of the
+// items, in order, to update or remove it while it is in the queue.
+// Unless duplicates are allowed, putting an item already in the queue
+// returns the handle it was put with before.
+func (pq *PriorityQueue) Put(items ...Item) ([]*Handle, error) {
 	if len(items) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	pq.lock.Lock()
 	defer pq.lock.Unlock()
 
 	if pq.disposed {
-		return ErrDisposed
+		return nil, ErrDisposed
 	}
 
-	for _, item := range items {
-		if pq.allowDuplicates {
-			pq.items.push(item)
-		} else if _, ok := pq.itemMap[item]; !ok {
-			pq.itemMap[item] = struct{}{}
-			pq.items.push(item)
+	handles := make([]*Handle, len(items))
+	for i, item := range items {
+		if handle, ok := pq.itemMap[item]; ok && !pq.allowDuplicates {
+			handles[i] = handle
+			continue
 		}
+
+		handles[i] = &Handle{item: item, queue: pq}
+		if !pq.allowDuplicates {
+			pq.itemMap[item] = handles[i]
+		}
+		pq.items.push(handles[i])
 	}
 
 	for {
@@ -143,20 +196,56 @@ func (pq *PriorityQueue) Put(items ...Item) error {
 		}
 
 		sema.response.Add(1)
-		sema.ready <- true
-		sema.response.Wait()
+		select {
+		case sema.ready <- true:
+			sema.response.Wait()
+		default:
+			// This semaphore timed out.
+		}
 		if len(pq.items) == 0 {
 			break
 		}
 	}
 
-	return nil
+	return handles, nil
+}
+
+// PutContext adds items to the queue like Put.  An error will be returned
+// if the context is done before the items are added.
+func (pq *PriorityQueue) PutContext(ctx context.Context, items ...Item) ([]*Handle, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return pq.Put(items...)
 }
 
 // Get retrieves items from the queue.  If the queue is empty,
 // this call blocks until the next item is added to the queue.  This
 // will attempt to retrieve number of items.
 func (pq *PriorityQueue) Get(number int) ([]Item, error) {
+	return pq.Poll(number, 0)
+}
+
+// GetContext retrieves items from the queue like Get, except that it
+// stops waiting for items and returns the context's error once the
+// context is done.
+func (pq *PriorityQueue) GetContext(ctx context.Context, number int) ([]Item, error) {
+	return pq.PollContext(ctx, number, 0)
+}
+
+// Poll retrieves items from the queue.  If the queue is empty, this call
+// blocks until the next item is added to the queue or the provided timeout
+// is reached.  A non-positive timeout will block until items are added.
+// If a timeout occurs, ErrTimeout is returned.
+func (pq *PriorityQueue) Poll(number int, timeout time.Duration) ([]Item, error) {
+	return pq.PollContext(context.Background(), number, timeout)
+}
+
+// PollContext retrieves items from the queue like Poll, except that it
+// stops waiting for items and returns the context's error once the
+// context is done.
+func (pq *PriorityQueue) PollContext(ctx context.Context, number int, timeout time.Duration) ([]Item, error) {
 	if number < 1 {
 		return nil, nil
 	}
@@ -182,7 +271,9 @@ func (pq *PriorityQueue) Get(number int) ([]Item, error) {
 		pq.waiters.put(sema)
 		pq.lock.Unlock()
 
-		<-sema.ready
+		if err := sema.wait(ctx, deadline(timeout), &pq.lock, &pq.waiters); err != nil {
+			return nil, err
+		}
 
 		if pq.Disposed() {
 			return nil, ErrDisposed
@@ -207,11 +298,57 @@ func (pq *PriorityQueue) Peek() Item {
 	pq.lock.Lock()
 	defer pq.lock.Unlock()
 	if len(pq.items) > 0 {
-		return pq.items[0]
+		return pq.items[0].item
 	}
 	return nil
 }
 
+// Update restores the order of the queue after the priority of the
+// item the handle refers to changed.  ErrNotQueued is returned if the
+// item is no longer in the queue.
+func (pq *PriorityQueue) Update(handle *Handle) error {
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+
+	if err := pq.check(handle); err != nil {
+		return err
+	}
+
+	pq.items.fix(handle.index)
+	return nil
+}
+
+// Remove takes the item the handle refers to out of the queue.
+// ErrNotQueued is returned if the item is no longer in the queue.
+func (pq *PriorityQueue) Remove(handle *Handle) error {
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+
+	if err := pq.check(handle); err != nil {
+		return err
+	}
+
+	pq.items.remove(handle.index)
+	if !pq.allowDuplicates {
+		delete(pq.itemMap, handle.item)
+	}
+	return nil
+}
+
+// check returns an error if the item the handle refers to is not in
+// this queue.  The caller should hold the lock.
+func (pq *PriorityQueue) check(handle *Handle) error {
+	if pq.disposed {
+		return ErrDisposed
+	}
+
+	if handle == nil || handle.queue != pq || handle.index < 0 {
+		return ErrNotQueued
+	}
+
+	return nil
+}
+
 // Empty returns a bool indicating if there are any items left
 // in the queue.
 func (pq *PriorityQueue) Empty() bool {
@@ -249,7 +386,16 @@ func (pq *PriorityQueue) Dispose() {
 	pq.disposed = true
 	for _, waiter := range pq.waiters {
 		waiter.response.Add(1)
-		waiter.ready <- true
+		select {
+		case waiter.ready <- true:
+			// release Get immediately
+		default:
+			// ignore if it's a timeout or in the get
+		}
+	}
+
+	for _, handle := range pq.items {
+		handle.index = -1
 	}
 
 	pq.items = nil
@@ -260,7 +406,7 @@ func (pq *PriorityQueue) Dispose() {
 func NewPriorityQueue(hint int, allowDuplicates bool) *PriorityQueue {
 	return &PriorityQueue{
 		items:           make(priorityItems, 0, hint),
-		itemMap:         make(map[Item]struct{}, hint),
+		itemMap:         make(map[Item]*Handle, hint),
 		allowDuplicates: allowDuplicates,
 	}
 }
@@ -17,8 +17,10 @@ limitations under the License.
 package queue
 
 import (
+	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -29,15 +31,15 @@ func TestPriorityPut(t *testing.T) {
 	q.Put(mockItem(2))
 
 	assert.Len(t, q.items, 1)
-	assert.Equal(t, mockItem(2), q.items[0])
+	assert.Equal(t, mockItem(2), q.items[0].item)
 
 	q.Put(mockItem(1))
 
 	if !assert.Len(t, q.items, 2) {
 		return
 	}
-	assert.Equal(t, mockItem(1), q.items[0])
-	assert.Equal(t, mockItem(2), q.items[1])
+	assert.Equal(t, mockItem(1), q.items[0].item)
+	assert.Equal(t, mockItem(2), q.items[1].item)
 }
 
 func TestPriorityGet(t *testing.T) {
@@ -196,6 +198,34 @@ func TestEmptyPriorityGetWithDispose(t *testing.T) {
 	assert.IsType(t, ErrDisposed, err)
 }
 
+func TestPriorityPollContext(t *testing.T) {
+	q := NewPriorityQueue(1, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		cancel()
+	}()
+
+	result, err := q.GetContext(ctx, 1)
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, result)
+	assert.Len(t, q.waiters, 0)
+
+	_, err = q.Poll(1, time.Millisecond)
+	assert.Equal(t, ErrTimeout, err)
+	assert.Len(t, q.waiters, 0)
+
+	// a later put isn't held up by the abandoned waiters
+	_, err = q.PutContext(ctx, mockItem(1))
+	assert.Equal(t, context.Canceled, err)
+	_, err = q.PutContext(context.Background(), mockItem(1))
+	assert.Nil(t, err)
+	result, err = q.PollContext(ctx, 1, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []Item{mockItem(1)}, result)
+}
+
 func TestPriorityGetPutDisposed(t *testing.T) {
 	q := NewPriorityQueue(1, false)
 	q.Dispose()
@@ -203,7 +233,7 @@ func TestPriorityGetPutDisposed(t *testing.T) {
 	_, err := q.Get(1)
 	assert.IsType(t, ErrDisposed, err)
 
-	err = q.Put(mockItem(1))
+	_, err = q.Put(mockItem(1))
 	assert.IsType(t, ErrDisposed, err)
 }
 
@@ -240,10 +270,11 @@ func TestPriorityPeek(t *testing.T) {
 
 func TestInsertDuplicate(t *testing.T) {
 	q := NewPriorityQueue(1, false)
-	q.Put(mockItem(1))
-	q.Put(mockItem(1))
+	first, _ := q.Put(mockItem(1))
+	second, _ := q.Put(mockItem(1))
 
 	assert.Equal(t, 1, q.Len())
+	assert.Equal(t, first, second)
 }
 
 func TestAllowDuplicates(t *testing.T) {
@@ -253,3 +284,128 @@ func TestAllowDuplicates(t *testing.T) {
 
 	assert.Equal(t, 2, q.Len())
 }
+
+type job struct {
+	priority int
+}
+
+func (j *job) Compare(other Item) int {
+	oj := other.(*job)
+	if j.priority > oj.priority {
+		return 1
+	} else if j.priority == oj.priority {
+		return 0
+	}
+	return -1
+}
+
+func TestPriorityPutHandles(t *testing.T) {
+	q := NewPriorityQueue(3, false)
+	handles, err := q.Put(mockItem(3), mockItem(1), mockItem(2))
+	assert.Nil(t, err)
+	if !assert.Len(t, handles, 3) {
+		return
+	}
+
+	for i, item := range []Item{mockItem(3), mockItem(1), mockItem(2)} {
+		assert.Equal(t, item, handles[i].Item())
+		assert.Equal(t, handles[i], q.items[handles[i].index])
+	}
+
+	handles, err = q.Put()
+	assert.Nil(t, err)
+	assert.Nil(t, handles)
+}
+
+func TestPriorityUpdate(t *testing.T) {
+	q := NewPriorityQueue(4, false)
+	jobs := []*job{{1}, {2}, {3}, {4}}
+	handles, _ := q.Put(jobs[0], jobs[1], jobs[2], jobs[3])
+
+	jobs[3].priority = 0
+	assert.Nil(t, q.Update(handles[3]))
+	assert.Equal(t, jobs[3], q.Peek())
+
+	jobs[3].priority = 5
+	jobs[1].priority = -1
+	assert.Nil(t, q.Update(handles[3]))
+	assert.Nil(t, q.Update(handles[1]))
+
+	result, err := q.Get(4)
+	assert.Nil(t, err)
+	assert.Equal(t, []Item{jobs[1], jobs[0], jobs[2], jobs[3]}, result)
+
+	// the items left the queue
+	assert.Equal(t, ErrNotQueued, q.Update(handles[0]))
+}
+
+func TestPriorityRemove(t *testing.T) {
+	q := NewPriorityQueue(5, false)
+	handles, _ := q.Put(mockItem(1), mockItem(2), mockItem(3), mockItem(4), mockItem(5))
+
+	assert.Nil(t, q.Remove(handles[0]))
+	assert.Nil(t, q.Remove(handles[3]))
+	assert.Equal(t, ErrNotQueued, q.Remove(handles[3]))
+	assert.Equal(t, 3, q.Len())
+
+	// a removed item can be put again
+	handle, _ := q.Put(mockItem(1))
+	assert.NotEqual(t, handles[0], handle[0])
+
+	result, err := q.Get(5)
+	assert.Nil(t, err)
+	assert.Equal(t, []Item{mockItem(1), mockItem(2), mockItem(3), mockItem(5)}, result)
+
+	other := NewPriorityQueue(1, false)
+	handles, _ = other.Put(mockItem(1))
+	assert.Equal(t, ErrNotQueued, q.Remove(handles[0]))
+	assert.Equal(t, ErrNotQueued, q.Remove(nil))
+
+	other.Dispose()
+	assert.Equal(t, ErrDisposed, other.Remove(handles[0]))
+}
+
+func TestPriorityRemoveAllowDuplicates(t *testing.T) {
+	q := NewPriorityQueue(2, true)
+	first, _ := q.Put(mockItem(1))
+	second, _ := q.Put(mockItem(1))
+	assert.NotEqual(t, first[0], second[0])
+
+	assert.Nil(t, q.Remove(first[0]))
+	assert.Equal(t, 1, q.Len())
+	assert.Nil(t, q.Update(second[0]))
+}
+
+func TestPriorityHeapProperty(t *testing.T) {
+	q := NewPriorityQueue(100, false)
+	jobs := make([]*job, 100)
+	items := make([]Item, len(jobs))
+	for i := range jobs {
+		jobs[i] = &job{(i * 37) % 101}
+		items[i] = jobs[i]
+	}
+	handles, _ := q.Put(items...)
+
+	for i := 0; i < 100; i += 3 {
+		jobs[i].priority = (i * 53) % 97
+		q.Update(handles[i])
+		if i%2 == 0 {
+			q.Remove(handles[i+1])
+		}
+	}
+
+	for i, handle := range q.items {
+		assert.Equal(t, i, handle.index)
+		if i > 0 {
+			assert.True(t, q.items[(i-1)/2].item.Compare(handle.item) <= 0)
+		}
+	}
+
+	last := -1
+	for !q.Empty() {
+		result, _ := q.Get(1)
+		priority := result[0].(*job).priority
+		assert.True(t, priority >= last)
+		last = priority
+	}
+}
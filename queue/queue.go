@@ -22,7 +22,9 @@ calls Dispose on the queue, any listeners are immediately returned
 with an error.  Any subsequent put to the queue will return an error
 as opposed to panicking as with channels.  Queues will grow with unbounded
 behavior as opposed to channels which can be buffered but will pause
-while a thread attempts to put to a full channel.
+while a thread attempts to put to a full channel.  Queues created with
+NewBounded hold a maximum number of items instead, and pause threads
+putting to a full queue the same way listening threads are paused.
 
 Recently added is a lockless ring buffer using the same basic C design as
 found here:
@@ -35,6 +37,12 @@ and gets, either will return an error if they are blocked and the buffer
 is disposed.  This could serve as a signal to kill a goroutine.  All threadsafety
 is acheived using CAS operations, making this buffer pretty quick.
 
+SPSCRingBuffer and MPSCRingBuffer are variants of the ring buffer for a single
+producer and a single consumer, or many producers and a single consumer, that
+skip the CAS operations their single threads don't need.  How their threads
+wait on a full or empty buffer is decided by a pluggable WaitStrategy, from
+busy spinning for the lowest latency to blocking for the lowest CPU use.
+
 Benchmarks:
 BenchmarkPriorityQueue-8	 		2000000	       782 ns/op
 BenchmarkQueue-8	 		 		2000000	       671 ns/op
@@ -52,6 +60,7 @@ TODO: Unify the types of queue to the same interface.
 package queue
 
 import (
+	"context"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -157,21 +166,116 @@ func newSema() *sema {
 	}
 }
 
+// deadline returns the time at which the provided timeout is reached,
+// or the zero time for a non-positive timeout.
+func deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+// wait blocks until the sema is readied by the other side of the queue or
+// a dispose, the deadline is reached or the context is done.  A zero deadline
+// never expires.  If an error is returned, the sema has either been removed
+// from the waiters or released back to the call that got it, so nothing is
+// left waiting on it.
+func (sema *sema) wait(ctx context.Context, deadline time.Time, lock *sync.Mutex, waiters *waiters) error {
+	var timeoutC <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+
+	var err error
+	select {
+	case <-sema.ready:
+		return nil
+	case <-timeoutC:
+		err = ErrTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// cleanup the sema that was added to waiters
+	select {
+	case sema.ready <- true:
+		// we called this before Put() could
+		// Remove sema from waiters.
+		lock.Lock()
+		waiters.remove(sema)
+		lock.Unlock()
+	default:
+		// Put() got it already, we need to call Done() so Put() can move on
+		// (or Get() for a waiting put)
+		sema.response.Done()
+	}
+	return err
+}
+
 // Queue is the struct responsible for tracking the state
 // of the queue.
 type Queue struct {
-	waiters  waiters
-	items    items
-	lock     sync.Mutex
-	disposed bool
+	waiters    waiters
+	putWaiters waiters
+	items      items
+	maxLen     int64
+	lock       sync.Mutex
+	disposed   bool
 }
 
-// Put will add the specified items to the queue.
+// Put will add the specified items to the queue.  If the queue is bounded
+// and there is no room for all the items, this call will block until there
+// is room or Dispose is called on the queue.  More items than the maximum
+// length are added once the queue is empty.
 func (q *Queue) Put(items ...interface{}) error {
+	return q.put(context.Background(), time.Time{}, items)
+}
+
+// PutContext will add the specified items to the queue like Put, except
+// that it stops waiting for room and returns the context's error once the
+// context is done.  No items are added if an error is returned.
+func (q *Queue) PutContext(ctx context.Context, items ...interface{}) error {
+	return q.put(ctx, time.Time{}, items)
+}
+
+// PutTimeout will add the specified items to the queue like Put, except
+// that it stops waiting for room once the provided timeout is reached.
+// A non-positive timeout will block until there is room.  If a timeout
+// occurs, ErrTimeout is returned and no items are added.
+func (q *Queue) PutTimeout(timeout time.Duration, items ...interface{}) error {
+	return q.put(context.Background(), deadline(timeout), items)
+}
+
+// Offer will add the specified items to the queue if there is room for all
+// of them, without waiting.  If there is not, this call will return false.
+// An error will be returned if the queue is disposed.
+func (q *Queue) Offer(items ...interface{}) (bool, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.disposed {
+		return false, ErrDisposed
+	}
+
+	if len(q.putWaiters) > 0 || !q.hasRoom(len(items)) {
+		return false, nil
+	}
+
+	q.add(items)
+	return true, nil
+}
+
+func (q *Queue) put(ctx context.Context, deadline time.Time, items []interface{}) error {
 	if len(items) == 0 {
 		return nil
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	q.lock.Lock()
 
 	if q.disposed {
@@ -179,6 +283,51 @@ func (q *Queue) Put(items ...interface{}) error {
 		return ErrDisposed
 	}
 
+	// Puts already waiting for room go first
+	if len(q.putWaiters) == 0 && q.hasRoom(len(items)) {
+		q.add(items)
+		q.lock.Unlock()
+		return nil
+	}
+
+	sema := newSema()
+	q.putWaiters.put(sema)
+	q.lock.Unlock()
+
+	for {
+		if err := sema.wait(ctx, deadline, &q.lock, &q.putWaiters); err != nil {
+			// the puts waiting behind this one may fit now
+			q.lock.Lock()
+			q.releasePuts()
+			q.lock.Unlock()
+			return err
+		}
+
+		// we are now inside the get's lock
+		if q.disposed {
+			return ErrDisposed
+		}
+		if q.hasRoom(len(items)) {
+			q.add(items)
+			sema.response.Done()
+			return nil
+		}
+
+		// still no room, wait at the front of the line for the next get
+		q.putWaiters = append(waiters{sema}, q.putWaiters...)
+		sema.response.Done()
+	}
+}
+
+// hasRoom returns a bool indicating if the provided number of items
+// can be added to the queue.  The caller should hold the lock.
+func (q *Queue) hasRoom(number int) bool {
+	return q.maxLen <= 0 || len(q.items) == 0 || int64(len(q.items)+number) <= q.maxLen
+}
+
+// add appends the provided items to the queue and hands them to the
+// waiting gets.  The caller should hold the lock.
+func (q *Queue) add(items []interface{}) {
 	q.items = append(q.items, items...)
 	for {
 		sema := q.waiters.get()
@@ -196,9 +345,29 @@ func (q *Queue) Put(items ...interface{}) error {
 			break
 		}
 	}
+}
 
-	q.lock.Unlock()
-	return nil
+// releasePuts hands the room in the queue to the waiting puts in order,
+// until the items of one of them don't fit.  The caller should hold the lock.
+func (q *Queue) releasePuts() {
+	for {
+		sema := q.putWaiters.get()
+		if sema == nil {
+			return
+		}
+		sema.response.Add(1)
+		select {
+		case sema.ready <- true:
+			sema.response.Wait()
+		default:
+			// This semaphore timed out.
+			continue
+		}
+		if len(q.putWaiters) > 0 && q.putWaiters[0] == sema {
+			// it went back to the front as its items don't fit
+			return
+		}
+	}
 }
 
 // Get retrieves items from the queue.  If there are some items in the
@@ -209,12 +378,26 @@ func (q *Queue) Get(number int64) ([]interface{}, error) {
 	return q.Poll(number, 0)
 }
 
+// GetContext retrieves items from the queue like Get, except that it
+// stops waiting for items and returns the context's error once the
+// context is done.
+func (q *Queue) GetContext(ctx context.Context, number int64) ([]interface{}, error) {
+	return q.PollContext(ctx, number, 0)
+}
+
 // Poll retrieves items from the queue.  If there are some items in the queue,
 // Poll will return a number UP TO the number passed in as a parameter.  If no
 // items are in the queue, this method will pause until items are added to the
 // queue or the provided timeout is reached.  A non-positive timeout will block
 // until items are added.  If a timeout occurs, ErrTimeout is returned.
 func (q *Queue) Poll(number int64, timeout time.Duration) ([]interface{}, error) {
+	return q.PollContext(context.Background(), number, timeout)
+}
+
+// PollContext retrieves items from the queue like Poll, except that it
+// stops waiting for items and returns the context's error once the
+// context is done.
+func (q *Queue) PollContext(ctx context.Context, number int64, timeout time.Duration) ([]interface{}, error) {
 	if number < 1 {
 		// thanks again go
 		return []interface{}{}, nil
@@ -234,37 +417,22 @@ func (q *Queue) Poll(number int64, timeout time.Duration) ([]interface{}, error)
 		q.waiters.put(sema)
 		q.lock.Unlock()
 
-		var timeoutC <-chan time.Time
-		if timeout > 0 {
-			timeoutC = time.After(timeout)
+		if err := sema.wait(ctx, deadline(timeout), &q.lock, &q.waiters); err != nil {
+			return nil, err
 		}
-		select {
-		case <-sema.ready:
-			// we are now inside the put's lock
-			if q.disposed {
-				return nil, ErrDisposed
-			}
-			items = q.items.get(number)
-			sema.response.Done()
-			return items, nil
-		case <-timeoutC:
-			// cleanup the sema that was added to waiters
-			select {
-			case sema.ready <- true:
-				// we called this before Put() could
-				// Remove sema from waiters.
-				q.lock.Lock()
-				q.waiters.remove(sema)
-				q.lock.Unlock()
-			default:
-				// Put() got it already, we need to call Done() so Put() can move on
-				sema.response.Done()
-			}
-			return nil, ErrTimeout
+
+		// we are now inside the put's lock
+		if q.disposed {
+			return nil, ErrDisposed
 		}
+		items = q.items.get(number)
+		q.releasePuts()
+		sema.response.Done()
+		return items, nil
 	}
 
 	items = q.items.get(number)
+	q.releasePuts()
 	q.lock.Unlock()
 	return items, nil
 }
@@ -303,6 +471,7 @@ func (q *Queue) TakeUntil(checker func(item interface{}) bool) ([]interface{}, e
 	}
 
 	result := q.items.getUntil(checker)
+	q.releasePuts()
 	q.lock.Unlock()
 	return result, nil
 }
@@ -340,11 +509,11 @@ func (q *Queue) Dispose() []interface{} {
 	defer q.lock.Unlock()
 
 	q.disposed = true
-	for _, waiter := range q.waiters {
+	for _, waiter := range append(q.waiters, q.putWaiters...) {
 		waiter.response.Add(1)
 		select {
 		case waiter.ready <- true:
-			// release Poll immediately
+			// release Poll or Put immediately
 		default:
 			// ignore if it's a timeout or in the get
 		}
@@ -354,6 +523,7 @@ func (q *Queue) Dispose() []interface{} {
 
 	q.items = nil
 	q.waiters = nil
+	q.putWaiters = nil
 
 	return disposedItems
 }
@@ -365,6 +535,16 @@ func New(hint int64) *Queue {
 	}
 }
 
+// NewBounded is a constructor for a new threadsafe queue holding at most
+// maxLen items.  Puts to a full queue block until gets make room for them.
+// A non-positive maxLen makes the queue unbounded, like New.
+func NewBounded(hint, maxLen int64) *Queue {
+	return &Queue{
+		items:  make([]interface{}, 0, hint),
+		maxLen: maxLen,
+	}
+}
+
 // ExecuteInParallel will (in parallel) call the provided function
 // with each item in the queue until the queue is exhausted.  When the queue
 // is exhausted execution is complete and all goroutines will be killed.
@@ -17,6 +17,7 @@ limitations under the License.
 package queue
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -137,6 +138,196 @@ func TestPollNoMemoryLeak(t *testing.T) {
 	}
 }
 
+func TestPollContext(t *testing.T) {
+	q := New(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		cancel()
+	}()
+
+	result, err := q.GetContext(ctx, 1)
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, result)
+	assert.Len(t, q.waiters, 0)
+
+	// the timeout still applies
+	_, err = q.PollContext(context.Background(), 1, time.Millisecond)
+	assert.Equal(t, ErrTimeout, err)
+
+	// a done context doesn't keep available items from being returned
+	q.Put(`test`)
+	result, err = q.PollContext(ctx, 1, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{`test`}, result)
+
+	assert.Equal(t, context.Canceled, q.PutContext(ctx, `test`))
+	assert.Nil(t, q.PutContext(context.Background(), `test`))
+	assert.Equal(t, int64(1), q.Len())
+}
+
+func TestGetContextConcurrentPut(t *testing.T) {
+	q := New(10)
+	var wg sync.WaitGroup
+	var received int64
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			defer cancel()
+			if result, err := q.GetContext(ctx, 1); err == nil {
+				atomic.AddInt64(&received, int64(len(result)))
+			}
+		}()
+		q.Put(i)
+	}
+	wg.Wait()
+
+	// every item was either received or is still queued, and no waiter leaked
+	assert.Equal(t, int64(100), received+q.Len())
+	assert.Len(t, q.waiters, 0)
+}
+
+func TestBoundedPut(t *testing.T) {
+	q := NewBounded(0, 2)
+	assert.Nil(t, q.Put(1, 2))
+
+	ok, err := q.Offer(3)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	before := time.Now()
+	err = q.PutTimeout(5*time.Millisecond, 3)
+	assert.InDelta(t, 5, time.Since(before).Seconds()*1000, 10)
+	assert.Equal(t, ErrTimeout, err)
+	assert.Len(t, q.putWaiters, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, q.PutContext(ctx, 3))
+
+	done := make(chan error)
+	go func() {
+		done <- q.Put(3)
+	}()
+
+	// the put waits until a get makes room
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, int64(2), q.Len())
+	result, err := q.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{1}, result)
+	assert.Nil(t, <-done)
+
+	result, err = q.Get(3)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{2, 3}, result)
+}
+
+func TestBoundedPutOrder(t *testing.T) {
+	q := NewBounded(0, 3)
+	assert.Nil(t, q.Put(0, 0, 0))
+
+	// a put of two items waits ahead of a put of one
+	first := make(chan error)
+	go func() {
+		first <- q.Put(1, 1)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	second := make(chan error)
+	go func() {
+		second <- q.Put(2)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	ok, err := q.Offer(3)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	q.Get(1)
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, int64(2), q.Len())
+
+	q.Get(1)
+	assert.Nil(t, <-first)
+	q.Get(1)
+	assert.Nil(t, <-second)
+
+	result, err := q.Get(3)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{1, 1, 2}, result)
+}
+
+func TestBoundedPutLargerThanMax(t *testing.T) {
+	q := NewBounded(0, 2)
+	assert.Nil(t, q.Put(1, 2, 3))
+	assert.Equal(t, int64(3), q.Len())
+
+	ok, err := q.Offer(4)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	result, _ := q.Get(3)
+	assert.Len(t, result, 3)
+	ok, err = q.Offer(4)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestBoundedPutDisposed(t *testing.T) {
+	q := NewBounded(0, 1)
+	q.Put(1)
+
+	done := make(chan error)
+	go func() {
+		done <- q.Put(2)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	q.Dispose()
+	assert.Equal(t, ErrDisposed, <-done)
+
+	_, err := q.Offer(3)
+	assert.Equal(t, ErrDisposed, err)
+}
+
+func TestBoundedConcurrent(t *testing.T) {
+	q := NewBounded(0, 10)
+	var wg sync.WaitGroup
+	wg.Add(8)
+	for i := 0; i < 4; i++ {
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 250; j++ {
+				if j%2 == 0 {
+					assert.Nil(t, q.Put(i))
+				} else {
+					for q.PutTimeout(time.Microsecond, i) == ErrTimeout {
+					}
+				}
+			}
+		}(i)
+	}
+
+	var received int64
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt64(&received) < 1000 {
+				result, _ := q.Poll(3, time.Millisecond)
+				assert.True(t, q.Len() <= 10)
+				atomic.AddInt64(&received, int64(len(result)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1000), received)
+	assert.Len(t, q.putWaiters, 0)
+}
+
 func TestAddEmptyPut(t *testing.T) {
 	q := New(10)
 
@@ -17,6 +17,7 @@ limitations under the License.
 package queue
 
 import (
+	"context"
 	"runtime"
 	"sync/atomic"
 	"time"
@@ -73,7 +74,15 @@ func (rb *RingBuffer) init(size uint64) {
 // call will block until an item is added to the queue or Dispose is called
 // on the queue.  An error will be returned if the queue is disposed.
 func (rb *RingBuffer) Put(item interface{}) error {
-	_, err := rb.put(item, false)
+	_, err := rb.put(context.Background(), item, false)
+	return err
+}
+
+// PutContext adds the provided item to the queue like Put, except that it
+// stops waiting for space and returns the context's error once the
+// context is done.
+func (rb *RingBuffer) PutContext(ctx context.Context, item interface{}) error {
+	_, err := rb.put(ctx, item, false)
 	return err
 }
 
@@ -81,12 +90,13 @@ func (rb *RingBuffer) Put(item interface{}) error {
 // is full, this call will return false.  An error will be returned if the
 // queue is disposed.
 func (rb *RingBuffer) Offer(item interface{}) (bool, error) {
-	return rb.put(item, true)
+	return rb.put(context.Background(), item, true)
 }
 
-func (rb *RingBuffer) put(item interface{}, offer bool) (bool, error) {
+func (rb *RingBuffer) put(ctx context.Context, item interface{}, offer bool) (bool, error) {
 	var n *node
 	pos := atomic.LoadUint64(&rb.queue)
+	done := ctx.Done()
 L:
 	for {
 		if atomic.LoadUint64(&rb.disposed) == 1 {
@@ -110,6 +120,12 @@ L:
 			return false, nil
 		}
 
+		select {
+		case <-done:
+			return false, ctx.Err()
+		default:
+		}
+
 		runtime.Gosched() // free up the cpu before the next iteration
 	}
 
@@ -126,16 +142,31 @@ func (rb *RingBuffer) Get() (interface{}, error) {
 	return rb.Poll(0)
 }
 
+// GetContext will return the next item in the queue like Get, except that
+// it stops waiting for an item and returns the context's error once the
+// context is done.
+func (rb *RingBuffer) GetContext(ctx context.Context) (interface{}, error) {
+	return rb.PollContext(ctx, 0)
+}
+
 // Poll will return the next item in the queue.  This call will block
 // if the queue is empty.  This call will unblock when an item is added
 // to the queue, Dispose is called on the queue, or the timeout is reached. An
 // error will be returned if the queue is disposed or a timeout occurs. A
 // non-positive timeout will block indefinitely.
 func (rb *RingBuffer) Poll(timeout time.Duration) (interface{}, error) {
+	return rb.PollContext(context.Background(), timeout)
+}
+
+// PollContext will return the next item in the queue like Poll, except that
+// it stops waiting for an item and returns the context's error once the
+// context is done.
+func (rb *RingBuffer) PollContext(ctx context.Context, timeout time.Duration) (interface{}, error) {
 	var (
 		n     *node
 		pos   = atomic.LoadUint64(&rb.dequeue)
 		start time.Time
+		done  = ctx.Done()
 	)
 	if timeout > 0 {
 		start = time.Now()
@@ -163,6 +194,12 @@ L:
 			return nil, ErrTimeout
 		}
 
+		select {
+		case <-done:
+			return nil, ctx.Err()
+		default:
+		}
+
 		runtime.Gosched() // free up the cpu before the next iteration
 	}
 	data := n.data
@@ -171,6 +208,131 @@ L:
 	return data, nil
 }
 
+// PutMany adds the provided items to the queue in order.  Rather than
+// paying a CAS per item, every free slot available, up to the number of
+// items left, is claimed with a single CAS.  If the queue is full, this call
+// will block until items are retrieved from the queue or Dispose is called
+// on the queue.  An error will be returned if the queue is disposed, in which
+// case only some of the items may have been added.
+func (rb *RingBuffer) PutMany(items []interface{}) error {
+	for len(items) > 0 {
+		if atomic.LoadUint64(&rb.disposed) == 1 {
+			return ErrDisposed
+		}
+
+		pos := atomic.LoadUint64(&rb.queue)
+		dequeue := atomic.LoadUint64(&rb.dequeue)
+		if dequeue > pos { // pos is stale
+			continue
+		}
+
+		free := rb.Cap() - (pos - dequeue)
+		if free == 0 {
+			runtime.Gosched() // free up the cpu before the next iteration
+			continue
+		}
+		if free > uint64(len(items)) {
+			free = uint64(len(items))
+		}
+		if !atomic.CompareAndSwapUint64(&rb.queue, pos, pos+free) {
+			continue
+		}
+
+		for i := uint64(0); i < free; i++ {
+			n := rb.nodes[(pos+i)&rb.mask]
+			// the get that claimed this slot last time around may still be
+			// reading it
+			for atomic.LoadUint64(&n.position) != pos+i {
+				runtime.Gosched()
+			}
+			n.data = items[i]
+			atomic.StoreUint64(&n.position, pos+i+1)
+		}
+		items = items[free:]
+	}
+
+	return nil
+}
+
+// GetMany will return up to max of the next items in the queue, claiming
+// all of them with a single CAS.  This call will block if the queue is
+// empty.  This call will unblock when an item is added to the queue or
+// Dispose is called on the queue.  An error will be returned if the queue
+// is disposed.
+func (rb *RingBuffer) GetMany(max uint64) ([]interface{}, error) {
+	if max == 0 {
+		return nil, nil
+	}
+
+	pos, count, err := rb.claim(max, true)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]interface{}, 0, count)
+	rb.take(pos, count, func(item interface{}) {
+		items = append(items, item)
+	})
+	return items, nil
+}
+
+// DrainTo will call fn with every item currently in the queue, in order,
+// after claiming all of them with a single CAS.  This call does not wait if
+// there are no items in the queue.  The number of items drained is returned,
+// and an error will be returned if the queue is disposed.
+func (rb *RingBuffer) DrainTo(fn func(item interface{})) (uint64, error) {
+	pos, count, err := rb.claim(rb.Cap(), false)
+	if err != nil {
+		return 0, err
+	}
+
+	rb.take(pos, count, fn)
+	return count, nil
+}
+
+// claim reserves up to max of the items put to the queue with a single CAS,
+// returning the position of the first one and the number reserved.  If block
+// is set and the queue is empty, it waits until an item is put.
+func (rb *RingBuffer) claim(max uint64, block bool) (uint64, uint64, error) {
+	for {
+		if atomic.LoadUint64(&rb.disposed) == 1 {
+			return 0, 0, ErrDisposed
+		}
+
+		pos := atomic.LoadUint64(&rb.dequeue)
+		count := atomic.LoadUint64(&rb.queue) - pos
+		if count == 0 {
+			if !block {
+				return pos, 0, nil
+			}
+			runtime.Gosched() // free up the cpu before the next iteration
+			continue
+		}
+		if count > max {
+			count = max
+		}
+		if atomic.CompareAndSwapUint64(&rb.dequeue, pos, pos+count) {
+			return pos, count, nil
+		}
+	}
+}
+
+// take calls fn with the count items claimed from the provided position,
+// freeing their slots for puts as it goes.
+func (rb *RingBuffer) take(pos, count uint64, fn func(item interface{})) {
+	for i := uint64(0); i < count; i++ {
+		n := rb.nodes[(pos+i)&rb.mask]
+		// the put that claimed this slot may still be writing it
+		for atomic.LoadUint64(&n.position) != pos+i+1 {
+			runtime.Gosched()
+		}
+		data := n.data
+		n.data = nil
+		atomic.StoreUint64(&n.position, pos+i+rb.mask+1)
+		fn(data)
+	}
+}
+
 // Len returns the number of items in the queue.
 func (rb *RingBuffer) Len() uint64 {
 	return atomic.LoadUint64(&rb.queue) - atomic.LoadUint64(&rb.dequeue)
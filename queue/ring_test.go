@@ -17,6 +17,8 @@ limitations under the License.
 package queue
 
 import (
+	"context"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -300,6 +302,169 @@ func TestDisposeOnPut(t *testing.T) {
 	assert.True(t, rb.IsDisposed())
 }
 
+func TestRingContext(t *testing.T) {
+	rb := NewRingBuffer(2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	result, err := rb.GetContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Nil(t, result)
+
+	_, err = rb.PollContext(context.Background(), time.Millisecond)
+	assert.Equal(t, ErrTimeout, err)
+
+	// put to full
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, rb.PutContext(context.Background(), i))
+	}
+	assert.Equal(t, context.DeadlineExceeded, rb.PutContext(ctx, 2))
+	assert.Equal(t, uint64(2), rb.Len())
+
+	result, err = rb.GetContext(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, result)
+}
+
+func TestRingPutGetMany(t *testing.T) {
+	rb := NewRingBuffer(4)
+	assert.Nil(t, rb.PutMany([]interface{}{1, 2, 3}))
+	assert.Equal(t, uint64(3), rb.Len())
+
+	result, err := rb.GetMany(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{1, 2}, result)
+
+	// wraps around the end of the buffer
+	assert.Nil(t, rb.PutMany([]interface{}{4, 5, 6}))
+	assert.Equal(t, uint64(4), rb.Len())
+	ok, err := rb.Offer(7)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	result, err = rb.GetMany(10)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{3, 4, 5, 6}, result)
+
+	result, err = rb.GetMany(0)
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+}
+
+func TestRingPutManyToFull(t *testing.T) {
+	rb := NewRingBuffer(2)
+	done := make(chan error)
+	go func() {
+		done <- rb.PutMany([]interface{}{1, 2, 3, 4, 5})
+	}()
+
+	var result []interface{}
+	for len(result) < 5 {
+		items, err := rb.GetMany(2)
+		assert.Nil(t, err)
+		result = append(result, items...)
+	}
+
+	assert.Nil(t, <-done)
+	assert.Equal(t, []interface{}{1, 2, 3, 4, 5}, result)
+}
+
+func TestRingDrainTo(t *testing.T) {
+	rb := NewRingBuffer(4)
+
+	var drained []interface{}
+	drain := func(item interface{}) {
+		drained = append(drained, item)
+	}
+	count, err := rb.DrainTo(drain)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), count)
+
+	rb.Put(1)
+	rb.PutMany([]interface{}{2, 3})
+	count, err = rb.DrainTo(drain)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), count)
+	assert.Equal(t, []interface{}{1, 2, 3}, drained)
+	assert.Equal(t, uint64(0), rb.Len())
+
+	rb.Dispose()
+	_, err = rb.DrainTo(drain)
+	assert.Equal(t, ErrDisposed, err)
+	_, err = rb.GetMany(1)
+	assert.Equal(t, ErrDisposed, err)
+	assert.Equal(t, ErrDisposed, rb.PutMany([]interface{}{1}))
+}
+
+func TestRingManyConcurrent(t *testing.T) {
+	rb := NewRingBuffer(16)
+	const producers, perProducer = 4, 1000
+
+	var wg sync.WaitGroup
+	wg.Add(producers)
+	for i := 0; i < producers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perProducer; j += 10 {
+				batch := make([]interface{}, 10)
+				for k := range batch {
+					batch[k] = i*perProducer + j + k
+				}
+				if j%20 == 0 {
+					assert.Nil(t, rb.PutMany(batch))
+				} else {
+					for _, item := range batch {
+						assert.Nil(t, rb.Put(item))
+					}
+				}
+			}
+		}(i)
+	}
+
+	var lock sync.Mutex
+	seen := make(map[interface{}]bool)
+	var received uint64
+	var cwg sync.WaitGroup
+	cwg.Add(4)
+	for i := 0; i < 4; i++ {
+		go func(i int) {
+			defer cwg.Done()
+			record := func(item interface{}) {
+				lock.Lock()
+				assert.False(t, seen[item])
+				seen[item] = true
+				lock.Unlock()
+				atomic.AddUint64(&received, 1)
+			}
+			for !rb.IsDisposed() {
+				switch i {
+				case 0:
+					if count, _ := rb.DrainTo(record); count == 0 {
+						runtime.Gosched()
+					}
+				case 1:
+					if item, err := rb.Poll(time.Millisecond); err == nil {
+						record(item)
+					}
+				default:
+					items, _ := rb.GetMany(5)
+					for _, item := range items {
+						record(item)
+					}
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	for atomic.LoadUint64(&received) < producers*perProducer {
+		time.Sleep(time.Millisecond)
+	}
+	rb.Dispose()
+	cwg.Wait()
+	assert.Len(t, seen, producers*perProducer)
+}
+
 func BenchmarkRBLifeCycle(b *testing.B) {
 	rb := NewRingBuffer(64)
 
@@ -396,3 +561,42 @@ func BenchmarkRBGet(b *testing.B) {
 		rb.Get()
 	}
 }
+
+func BenchmarkRBLifeCycleContentionMany(b *testing.B) {
+	rb := NewRingBuffer(64)
+
+	var wwg sync.WaitGroup
+	var rwg sync.WaitGroup
+	wwg.Add(10)
+	rwg.Add(10)
+
+	for i := 0; i < 10; i++ {
+		go func() {
+			for {
+				_, err := rb.GetMany(16)
+				if err == ErrDisposed {
+					rwg.Done()
+					return
+				} else {
+					assert.Nil(b, err)
+				}
+			}
+		}()
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < 10; i++ {
+		go func() {
+			batch := make([]interface{}, 16)
+			for j := 0; j < b.N; j += len(batch) {
+				rb.PutMany(batch)
+			}
+			wwg.Done()
+		}()
+	}
+
+	wwg.Wait()
+	rb.Dispose()
+	rwg.Wait()
+}
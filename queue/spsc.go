@@ -0,0 +1,163 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SPSCRingBuffer is a ring buffer for a single thread putting items and
+// a single thread getting them.  As each position is only ever written by
+// one thread, no CAS operations are needed and items are handed over with
+// an atomic store of the position alone.  Calling Put from more than one
+// thread, or Get and Poll from more than one thread, is not safe.  A put on
+// full or get on empty call waits with the buffer's wait strategy until an
+// item is retrieved or put, or Dispose is called.
+type SPSCRingBuffer struct {
+	_padding0      [8]uint64
+	queue          uint64 // Position of the next put, only written by the producer
+	_padding1      [8]uint64
+	dequeue        uint64 // Position of the next get, only written by the consumer
+	_padding2      [8]uint64
+	mask, disposed uint64
+	_padding3      [8]uint64
+	items          []interface{}
+	wait           WaitStrategy
+}
+
+// Put adds the provided item to the queue.  If the queue is full, this
+// call will wait until an item is retrieved from the queue or Dispose is
+// called on the queue.  An error will be returned if the queue is disposed.
+func (rb *SPSCRingBuffer) Put(item interface{}) error {
+	if atomic.LoadUint64(&rb.disposed) == 1 {
+		return ErrDisposed
+	}
+
+	pos := rb.queue
+	if !rb.canPut(pos) {
+		if err := await(rb.wait, &rb.disposed, 0, func() bool { return rb.canPut(pos) }); err != nil {
+			return err
+		}
+	}
+
+	rb.add(pos, item)
+	return nil
+}
+
+// Offer adds the provided item to the queue if there is space.  If the
+// queue is full, this call will return false.  An error will be returned
+// if the queue is disposed.
+func (rb *SPSCRingBuffer) Offer(item interface{}) (bool, error) {
+	if atomic.LoadUint64(&rb.disposed) == 1 {
+		return false, ErrDisposed
+	}
+
+	pos := rb.queue
+	if !rb.canPut(pos) {
+		return false, nil
+	}
+
+	rb.add(pos, item)
+	return true, nil
+}
+
+func (rb *SPSCRingBuffer) canPut(pos uint64) bool {
+	return pos-atomic.LoadUint64(&rb.dequeue) < uint64(len(rb.items))
+}
+
+func (rb *SPSCRingBuffer) add(pos uint64, item interface{}) {
+	rb.items[pos&rb.mask] = item
+	atomic.StoreUint64(&rb.queue, pos+1)
+	rb.wait.Signal()
+}
+
+// Get will return the next item in the queue.  This call will wait if the
+// queue is empty until an item is added to the queue or Dispose is called
+// on the queue.  An error will be returned if the queue is disposed.
+func (rb *SPSCRingBuffer) Get() (interface{}, error) {
+	return rb.Poll(0)
+}
+
+// Poll will return the next item in the queue.  This call will wait if the
+// queue is empty until an item is added to the queue, Dispose is called on
+// the queue, or the timeout is reached.  An error will be returned if the
+// queue is disposed or a timeout occurs.  A non-positive timeout will wait
+// indefinitely.
+func (rb *SPSCRingBuffer) Poll(timeout time.Duration) (interface{}, error) {
+	if atomic.LoadUint64(&rb.disposed) == 1 {
+		return nil, ErrDisposed
+	}
+
+	pos := rb.dequeue
+	if !rb.canGet(pos) {
+		if err := await(rb.wait, &rb.disposed, timeout, func() bool { return rb.canGet(pos) }); err != nil {
+			return nil, err
+		}
+	}
+
+	item := rb.items[pos&rb.mask]
+	rb.items[pos&rb.mask] = nil
+	atomic.StoreUint64(&rb.dequeue, pos+1)
+	rb.wait.Signal()
+	return item, nil
+}
+
+func (rb *SPSCRingBuffer) canGet(pos uint64) bool {
+	return atomic.LoadUint64(&rb.queue) != pos
+}
+
+// Len returns the number of items in the queue.
+func (rb *SPSCRingBuffer) Len() uint64 {
+	return atomic.LoadUint64(&rb.queue) - atomic.LoadUint64(&rb.dequeue)
+}
+
+// Cap returns the capacity of this ring buffer.
+func (rb *SPSCRingBuffer) Cap() uint64 {
+	return uint64(len(rb.items))
+}
+
+// Dispose will dispose of this queue and free any waiting threads
+// in the Put and/or Get methods.  Calling those methods on a disposed
+// queue will return an error.
+func (rb *SPSCRingBuffer) Dispose() {
+	atomic.CompareAndSwapUint64(&rb.disposed, 0, 1)
+	rb.wait.Signal()
+}
+
+// IsDisposed will return a bool indicating if this queue has been
+// disposed.
+func (rb *SPSCRingBuffer) IsDisposed() bool {
+	return atomic.LoadUint64(&rb.disposed) == 1
+}
+
+// NewSPSCRingBuffer will allocate, initialize, and return a single
+// producer, single consumer ring buffer with the specified size, waiting
+// with the provided wait strategy.  If the wait strategy is nil, waiting
+// threads yield like those of RingBuffer.
+func NewSPSCRingBuffer(size uint64, wait WaitStrategy) *SPSCRingBuffer {
+	if wait == nil {
+		wait = NewYieldingWait()
+	}
+
+	size = roundUp(size)
+	return &SPSCRingBuffer{
+		items: make([]interface{}, size),
+		mask:  size - 1,
+		wait:  wait,
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSPSCPutGet(t *testing.T) {
+	rb := NewSPSCRingBuffer(3, nil)
+	assert.Equal(t, uint64(4), rb.Cap())
+
+	for i := 0; i < 4; i++ {
+		ok, err := rb.Offer(i)
+		assert.True(t, ok)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, uint64(4), rb.Len())
+
+	ok, err := rb.Offer(4)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	for i := 0; i < 4; i++ {
+		result, err := rb.Get()
+		assert.Nil(t, err)
+		assert.Equal(t, i, result)
+	}
+
+	_, err = rb.Poll(time.Millisecond)
+	assert.Equal(t, ErrTimeout, err)
+}
+
+func TestSPSCStrategies(t *testing.T) {
+	for name, strategy := range waitStrategies {
+		if name == "BusySpin" && (runtime.NumCPU() < 2 || runtime.GOMAXPROCS(0) < 2) {
+			// a spinning thread only gives way to the other side when preempted
+			continue
+		}
+
+		rb := NewSPSCRingBuffer(4, strategy())
+		const count = 1000
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				assert.Nil(t, rb.Put(i), name)
+			}
+		}()
+
+		for i := 0; i < count; i++ {
+			result, err := rb.Get()
+			assert.Nil(t, err, name)
+			assert.Equal(t, i, result, name)
+		}
+		wg.Wait()
+		assert.Equal(t, uint64(0), rb.Len(), name)
+	}
+}
+
+func TestSPSCDispose(t *testing.T) {
+	for name, strategy := range waitStrategies {
+		empty, full := NewSPSCRingBuffer(1, strategy()), NewSPSCRingBuffer(1, strategy())
+		full.Put(1)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := empty.Get()
+			assert.Equal(t, ErrDisposed, err, name)
+		}()
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, ErrDisposed, full.Put(2), name)
+		}()
+
+		time.Sleep(5 * time.Millisecond)
+		empty.Dispose()
+		full.Dispose()
+		wg.Wait()
+
+		assert.True(t, full.IsDisposed(), name)
+		_, err := full.Offer(3)
+		assert.Equal(t, ErrDisposed, err, name)
+	}
+}
+
+func BenchmarkSPSCLifeCycle(b *testing.B) {
+	for name, strategy := range waitStrategies {
+		b.Run(name, func(b *testing.B) {
+			rb := NewSPSCRingBuffer(64, strategy())
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < b.N; i++ {
+					rb.Get()
+				}
+			}()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rb.Put(i)
+			}
+			wg.Wait()
+		})
+	}
+}
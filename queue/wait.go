@@ -0,0 +1,166 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WaitStrategy decides how a thread waits on a ring buffer that is full
+// on a put or empty on a get, similar to the wait strategies of the LMAX
+// disruptor.  Strategies that wait more eagerly react faster to the other
+// side of the buffer at the cost of burning more CPU.
+type WaitStrategy interface {
+	// Wait pauses the calling thread before its next attempt at a put or
+	// get.  ready reports whether that attempt could succeed, and attempt
+	// is the number of times the thread paused already in this wait.
+	Wait(attempt int, ready func() bool)
+	// Signal is called after a put, get or dispose to wake up threads
+	// paused in Wait.
+	Signal()
+}
+
+type busySpinWait struct{}
+
+// NewBusySpinWait returns a wait strategy that retries immediately.  This
+// gives the lowest latency but burns a whole CPU per waiting thread, so
+// it should only be used when every thread has a CPU of its own.
+func NewBusySpinWait() WaitStrategy {
+	return busySpinWait{}
+}
+
+func (busySpinWait) Wait(int, func() bool) {}
+
+func (busySpinWait) Signal() {}
+
+type yieldingWait struct{}
+
+// NewYieldingWait returns a wait strategy that yields the processor to
+// other goroutines before retrying.  This is what RingBuffer does.
+func NewYieldingWait() WaitStrategy {
+	return yieldingWait{}
+}
+
+func (yieldingWait) Wait(int, func() bool) {
+	runtime.Gosched()
+}
+
+func (yieldingWait) Signal() {}
+
+type sleepingWait struct {
+	spins, yields int
+	maxSleep      time.Duration
+}
+
+// NewSleepingWait returns a wait strategy that first retries immediately
+// the given number of times, then yields the processor the given number of
+// times, and then sleeps, doubling the sleep from a microsecond up to
+// maxSleep.  This trades latency for little CPU use on a buffer that is
+// often idle.
+func NewSleepingWait(spins, yields int, maxSleep time.Duration) WaitStrategy {
+	return &sleepingWait{spins: spins, yields: yields, maxSleep: maxSleep}
+}
+
+func (sw *sleepingWait) Wait(attempt int, _ func() bool) {
+	switch {
+	case attempt < sw.spins:
+	case attempt < sw.spins+sw.yields:
+		runtime.Gosched()
+	default:
+		sleep := sw.maxSleep
+		if shift := uint(attempt - sw.spins - sw.yields); shift < 32 && time.Microsecond<<shift < sleep {
+			sleep = time.Microsecond << shift
+		}
+		time.Sleep(sleep)
+	}
+}
+
+func (sw *sleepingWait) Signal() {}
+
+type blockingWait struct {
+	waiters int64 // Number of threads paused in Wait
+	lock    sync.Mutex
+	cond    *sync.Cond
+}
+
+// NewBlockingWait returns a wait strategy that blocks on a condition
+// variable until signaled by the other side of the buffer.  Waiting
+// threads use no CPU, but every put and get pays to signal them and a
+// woken thread takes longest to react.
+func NewBlockingWait() WaitStrategy {
+	bw := &blockingWait{}
+	bw.cond = sync.NewCond(&bw.lock)
+	return bw
+}
+
+func (bw *blockingWait) Wait(_ int, ready func() bool) {
+	bw.lock.Lock()
+	// Signal checks the waiters after changing what ready reports, and
+	// ready is checked after adding to the waiters, so no signal is missed
+	atomic.AddInt64(&bw.waiters, 1)
+	for !ready() {
+		bw.cond.Wait()
+	}
+	atomic.AddInt64(&bw.waiters, -1)
+	bw.lock.Unlock()
+}
+
+func (bw *blockingWait) Signal() {
+	if atomic.LoadInt64(&bw.waiters) == 0 {
+		return
+	}
+
+	bw.lock.Lock()
+	bw.cond.Broadcast()
+	bw.lock.Unlock()
+}
+
+// await pauses with the wait strategy until ready returns true.  ErrDisposed
+// is returned if the buffer is disposed first, and ErrTimeout if a positive
+// timeout is reached first.
+func await(wait WaitStrategy, disposed *uint64, timeout time.Duration, ready func() bool) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+		// make sure a blocked thread notices the timeout
+		timer := time.AfterFunc(timeout, wait.Signal)
+		defer timer.Stop()
+	}
+
+	expired := func() bool {
+		return !deadline.IsZero() && !time.Now().Before(deadline)
+	}
+	done := func() bool {
+		return ready() || atomic.LoadUint64(disposed) == 1 || expired()
+	}
+
+	for attempt := 0; !ready(); attempt++ {
+		if atomic.LoadUint64(disposed) == 1 {
+			return ErrDisposed
+		}
+		if expired() {
+			return ErrTimeout
+		}
+
+		wait.Wait(attempt, done)
+	}
+
+	return nil
+}
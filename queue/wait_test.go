@@ -0,0 +1,79 @@
+/*
+Copyright 2014 Workiva, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var waitStrategies = map[string]func() WaitStrategy{
+	"BusySpin": NewBusySpinWait,
+	"Yielding": NewYieldingWait,
+	"Sleeping": func() WaitStrategy {
+		return NewSleepingWait(10, 10, time.Millisecond)
+	},
+	"Blocking": NewBlockingWait,
+}
+
+func TestAwait(t *testing.T) {
+	for name, strategy := range waitStrategies {
+		wait := strategy()
+		var disposed, ready uint64
+		isReady := func() bool {
+			return atomic.LoadUint64(&ready) == 1
+		}
+
+		go func() {
+			time.Sleep(time.Millisecond)
+			atomic.StoreUint64(&ready, 1)
+			wait.Signal()
+		}()
+		assert.Nil(t, await(wait, &disposed, 0, isReady), name)
+
+		atomic.StoreUint64(&ready, 0)
+		before := time.Now()
+		assert.Equal(t, ErrTimeout, await(wait, &disposed, 5*time.Millisecond, isReady), name)
+		assert.InDelta(t, 5, time.Since(before).Seconds()*1000, 10, name)
+
+		go func() {
+			time.Sleep(time.Millisecond)
+			atomic.StoreUint64(&disposed, 1)
+			wait.Signal()
+		}()
+		assert.Equal(t, ErrDisposed, await(wait, &disposed, 0, isReady), name)
+	}
+}
+
+func TestSleepingWaitBackoff(t *testing.T) {
+	wait := NewSleepingWait(1, 1, 100*time.Microsecond)
+
+	before := time.Now()
+	wait.Wait(0, nil)
+	wait.Wait(1, nil)
+	spun := time.Since(before)
+
+	before = time.Now()
+	wait.Wait(100, nil)
+	slept := time.Since(before)
+
+	assert.True(t, slept >= 100*time.Microsecond)
+	assert.True(t, spun < slept)
+}